@@ -16,14 +16,22 @@
 package main
 
 import (
+	"fmt"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/admin"
 	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
 	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/events"
 	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/proxy"
 	"log"
 	"net/http"
 )
 
 func main() {
+	if err := config.LoadGatewayConfig(); err != nil {
+		log.Fatal("Error loading gateway config: ", err)
+		return
+	}
+
 	configLoader, err := config.NewConfigLoader()
 
 	if err != nil {
@@ -39,30 +47,47 @@ func main() {
 	}
 
 	// Defina um manipulador padrão para "/"
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/", proxy.WithRequestID(func(w http.ResponseWriter, r *http.Request) {
 		routeConfig, exists := config.GetHostStore().GetRoute(r.Host, r.URL.Path)
 
 		if !exists {
-			w.WriteHeader(http.StatusNotFound)
+			proxy.WriteError(w, r, r.Host, http.StatusNotFound, config.ErrorNotFound, "No route matches the request.")
 			return
 		}
 
+		events.Publish(events.Event{
+			Type:      events.RouteMatched,
+			Container: routeConfig.Backend.ContainerKey(),
+			Host:      r.Host,
+			Route:     routeConfig.Path,
+			Reason:    r.Method + " " + r.URL.Path,
+		})
+
 		corsConfig, exists := config.GetHostStore().GetCORS(r.Host)
 
 		if exists {
 			isAllowed := config.ResolveCors(w, r, corsConfig)
 
 			if !isAllowed {
-				w.WriteHeader(http.StatusUnauthorized)
+				proxy.WriteCORSRejection(w, r, r.Host)
 				return
 			}
 		}
 
 		proxy.HandleRequest(routeConfig)(w, r)
+	}))
+
+	events.StartWebhooks(config.GetGatewayConfig().Webhooks)
+	events.Publish(events.Event{
+		Type:   events.ConfigLoaded,
+		Reason: fmt.Sprintf("%d hosts loaded", len(config.GetHostStore().ListHosts())),
 	})
 
 	go docker.CheckContainersActive()
 	go docker.CheckContainersToStop()
+	go docker.CheckContainersStats()
+	go docker.CheckCluster()
+	go admin.Start()
 
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
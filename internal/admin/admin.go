@@ -0,0 +1,115 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package admin
+
+import (
+	"crypto/subtle"
+	"embed"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/events"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/metrics"
+)
+
+// defaultAddr only accepts local connections; ADMIN_ADDR must be set to reach the listener
+// from other hosts.
+const defaultAddr = "127.0.0.1:8081"
+
+// dashboardFiles holds the assets of the status dashboard, served at the root of the listener.
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// Start serves the administration endpoints on the address defined by ADMIN_ADDR. Requests
+// changing containers must carry the token defined by ADMIN_TOKEN; without it they are refused.
+func Start() {
+	addr := os.Getenv("ADMIN_ADDR")
+	if addr == "" {
+		addr = defaultAddr
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", metrics.Handler())
+	mux.HandleFunc("/api/containers", handleContainers)
+	mux.HandleFunc("/api/containers/pin", handlePin)
+	mux.HandleFunc("/api/containers/wake", handleWake)
+	mux.HandleFunc("/api/containers/stop", handleStop)
+	mux.HandleFunc("/api/routes", handleRoutes)
+	mux.HandleFunc("/api/events", events.StreamHandler())
+
+	mux.Handle("/", dashboardHandler())
+
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" {
+		log.Printf("ADMIN_TOKEN is not set: the admin API is read-only")
+	}
+
+	log.Printf("Admin server listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, protect(token, mux)))
+}
+
+// protect rejects cross-origin requests, so pages of other sites cannot drive the admin API
+// from a browser, and requires the admin token on every request other than GET and HEAD.
+func protect(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if crossOrigin(r) {
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if token == "" {
+				http.Error(w, "the admin API is read-only: set ADMIN_TOKEN to change containers", http.StatusForbidden)
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				http.Error(w, "invalid admin token", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// crossOrigin reports whether a browser sent the request on behalf of a page of another origin.
+// Requests without an Origin header, such as those of curl, are not cross-origin.
+func crossOrigin(r *http.Request) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return true
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	parsed, err := url.Parse(origin)
+	return err != nil || parsed.Host != r.Host
+}
+
+// dashboardHandler serves the embedded dashboard assets.
+func dashboardHandler() http.Handler {
+	assets, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		log.Fatalf("Error loading the dashboard assets: %v", err)
+	}
+	return http.FileServer(http.FS(assets))
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+func TestDashboardIsEmbedded(t *testing.T) {
+	for path, want := range map[string]string{
+		"/":             `<script src="dashboard.js">`,
+		"/dashboard.js": "api/containers",
+	} {
+		recorder := httptest.NewRecorder()
+		dashboardHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+		body, _ := io.ReadAll(recorder.Body)
+		if recorder.Code != http.StatusOK || !strings.Contains(string(body), want) {
+			t.Errorf("GET %s = %d, want 200 containing %q", path, recorder.Code, want)
+		}
+	}
+}
+
+func TestHandleRoutesListsHostsAndRoutes(t *testing.T) {
+	config.GetHostStore().AddHost(config.HostConfig{
+		Host: "example.com",
+		Routes: []config.RouteConfig{
+			{Path: "/web", Backend: config.Backend{Protocol: "http", Host: "web", Port: 80}},
+			{Path: "/api", Backend: config.Backend{Protocol: "http", Host: "localhost", Port: 8080, ContainerName: "api"}},
+		},
+	})
+
+	recorder := httptest.NewRecorder()
+	handleRoutes(recorder, httptest.NewRequest(http.MethodGet, "/api/routes", nil))
+
+	var views []routeView
+	if err := json.NewDecoder(recorder.Body).Decode(&views); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	want := []routeView{
+		{Host: "example.com", Path: "/api", Container: "api", Backend: "http://localhost:8080"},
+		{Host: "example.com", Path: "/web", Backend: "http://web:80"},
+	}
+	if len(views) != len(want) {
+		t.Fatalf("routes = %+v, want %+v", views, want)
+	}
+	for i := range want {
+		if views[i] != want[i] {
+			t.Errorf("route %d = %+v, want %+v", i, views[i], want[i])
+		}
+	}
+}
+
+func TestProtectRequiresTokenAndSameOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		method  string
+		headers map[string]string
+		want    int
+	}{
+		{name: "read without token", method: http.MethodGet, want: http.StatusOK},
+		{name: "change without configured token", method: http.MethodPost,
+			headers: map[string]string{"Authorization": "Bearer "}, want: http.StatusForbidden},
+		{name: "change without token", token: "s3cret", method: http.MethodPost, want: http.StatusUnauthorized},
+		{name: "change with wrong token", token: "s3cret", method: http.MethodPost,
+			headers: map[string]string{"Authorization": "Bearer other"}, want: http.StatusUnauthorized},
+		{name: "change with token", token: "s3cret", method: http.MethodPost,
+			headers: map[string]string{"Authorization": "Bearer s3cret"}, want: http.StatusOK},
+		{name: "change from same origin", token: "s3cret", method: http.MethodDelete,
+			headers: map[string]string{"Authorization": "Bearer s3cret", "Origin": "http://localhost:8081"}, want: http.StatusOK},
+		{name: "change from other origin", token: "s3cret", method: http.MethodPost,
+			headers: map[string]string{"Authorization": "Bearer s3cret", "Origin": "http://evil.example"}, want: http.StatusForbidden},
+		{name: "read from other site", method: http.MethodGet,
+			headers: map[string]string{"Sec-Fetch-Site": "cross-site"}, want: http.StatusForbidden},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, "http://localhost:8081/api/containers/stop?container=app", nil)
+			for name, value := range test.headers {
+				request.Header.Set(name, value)
+			}
+
+			recorder := httptest.NewRecorder()
+			protect(test.token, next).ServeHTTP(recorder, request)
+			if recorder.Code != test.want {
+				t.Errorf("status = %d, want %d", recorder.Code, test.want)
+			}
+		})
+	}
+}
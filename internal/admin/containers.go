@@ -0,0 +1,193 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+// containerView is the admin representation of a container managed by the gateway.
+type containerView struct {
+	Key            string     `json:"key"`
+	ContainerName  string     `json:"containerName"`
+	Endpoint       string     `json:"endpoint,omitempty"`
+	Service        bool       `json:"service,omitempty"`
+	Replicas       int        `json:"replicas,omitempty"`
+	ID             string     `json:"id,omitempty"`
+	State          string     `json:"state,omitempty"`
+	IsActive       bool       `json:"isActive"`
+	LastAccess     *time.Time `json:"lastAccess,omitempty"`
+	TTL            int        `json:"ttl"`
+	IdleAction     string     `json:"idleAction"`
+	KeepWarm       bool       `json:"keepWarm"`
+	InWarmWindow   bool       `json:"inWarmWindow"`
+	NextWarmWindow *time.Time `json:"nextWarmWindow,omitempty"`
+	WarmError      string     `json:"warmError,omitempty"`
+	StartedBy      string     `json:"startedBy,omitempty"`
+	Pinned         bool       `json:"pinned"`
+	CooldownUntil  *time.Time `json:"cooldownUntil,omitempty"`
+
+	Stats          *docker.ContainerStats `json:"stats,omitempty"`
+	ColdStarts     []docker.ColdStart     `json:"coldStarts,omitempty"`
+	StartErrorRate float64                `json:"startErrorRate"` // Share of the recent start attempts that failed
+}
+
+// handleContainers lists every container referenced by a route with its lifecycle state.
+func handleContainers(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	policies := config.GetHostStore().ListContainerPolicies()
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Key() < policies[j].Key()
+	})
+
+	views := make([]containerView, 0, len(policies))
+	for _, policy := range policies {
+		views = append(views, newContainerView(policy, now))
+	}
+
+	writeJSON(w, views)
+}
+
+// newContainerView builds the admin view of a container from its policy and stored state.
+func newContainerView(policy config.ContainerPolicy, now time.Time) containerView {
+	view := containerView{
+		Key:           policy.Key(),
+		ContainerName: policy.ContainerName,
+		Endpoint:      policy.Endpoint,
+		Service:       policy.Service,
+		Replicas:      policy.Replicas,
+		TTL:           policy.TTL,
+		IdleAction:    policy.IdleAction,
+		KeepWarm:      policy.KeepWarm,
+		InWarmWindow:  policy.InWarmWindow(now),
+	}
+
+	if reason, exists := docker.GetWarmError(policy.Key()); exists {
+		view.WarmError = reason
+	}
+
+	if next, ok := policy.NextWarmWindow(now); ok {
+		view.NextWarmWindow = &next
+	}
+
+	if current, exists := docker.GetContainerStats(policy.Key()); exists {
+		view.Stats = &current
+	}
+
+	view.ColdStarts = docker.GetColdStarts(policy.Key())
+	if len(view.ColdStarts) > 0 {
+		failed := 0
+		for _, coldStart := range view.ColdStarts {
+			if coldStart.Error != "" {
+				failed++
+			}
+		}
+		view.StartErrorRate = float64(failed) / float64(len(view.ColdStarts))
+	}
+
+	if stored, exists := container_store.GetByContainerName(policy.Key()); exists {
+		view.ID = stored.ID
+		view.State = stored.State
+		view.IsActive = stored.IsActive
+		view.LastAccess = &stored.LastAccess
+		view.StartedBy = stored.StartedBy
+		view.Pinned = stored.Pinned
+		if stored.CooldownUntil.After(now) {
+			view.CooldownUntil = &stored.CooldownUntil
+		}
+	}
+
+	return view
+}
+
+// handlePin pins, with POST, or unpins, with DELETE, the container given in the container query
+// parameter as listed by /api/containers (endpoint/name for containers of named endpoints).
+func handlePin(w http.ResponseWriter, r *http.Request) {
+	var pinned bool
+	switch r.Method {
+	case http.MethodPost:
+		pinned = true
+	case http.MethodDelete:
+		pinned = false
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !docker.PinContainer(r.URL.Query().Get("container"), pinned) {
+		http.Error(w, "container not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleWake starts, with POST, the container given in the container query parameter. The start
+// runs in the background; its progress shows in /api/containers.
+func handleWake(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !docker.WakeContainer(r.URL.Query().Get("container")) {
+		http.Error(w, "container not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleStop applies, with POST, the idle action of the container given in the container query
+// parameter, as if its TTL had expired. On a follower of a cluster the stop is carried out by the
+// leader shortly after the answer.
+func handleStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	found, err := docker.ApplyIdleAction(r.URL.Query().Get("container"))
+	if !found {
+		http.Error(w, "container not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "the stop could not be forwarded to the cluster leader", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON writes a value as an indented JSON response.
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,201 @@
+"use strict";
+
+// Refreshes the routes and containers every few seconds, and at once when the gateway reports a
+// container event. TTL countdowns are updated every second in between.
+
+const refreshInterval = 5000;
+
+let routes = [];
+let containers = new Map();
+let refreshing = false;
+
+async function fetchJSON(url) {
+  const response = await fetch(url);
+  if (!response.ok) {
+    throw new Error(url + " answered " + response.status);
+  }
+  return response.json();
+}
+
+async function refresh() {
+  if (refreshing) {
+    return;
+  }
+  refreshing = true;
+
+  try {
+    const [routeList, containerList] = await Promise.all([
+      fetchJSON("api/routes"),
+      fetchJSON("api/containers"),
+    ]);
+    routes = routeList;
+    containers = new Map(containerList.map((container) => [container.key, container]));
+    setStatus("updated " + new Date().toLocaleTimeString(), false);
+    render();
+  } catch (error) {
+    setStatus(error.message, true);
+  } finally {
+    refreshing = false;
+  }
+}
+
+function setStatus(text, isError) {
+  const status = document.getElementById("status");
+  status.textContent = text;
+  status.classList.toggle("error", isError);
+}
+
+function cell(row, content, className) {
+  const td = row.insertCell();
+  if (content instanceof Node) {
+    td.appendChild(content);
+  } else {
+    td.textContent = content === undefined || content === null ? "" : content;
+  }
+  if (className) {
+    td.className = className;
+  }
+  return td;
+}
+
+function formatDuration(milliseconds) {
+  if (milliseconds < 1000) {
+    return milliseconds + " ms";
+  }
+  const seconds = Math.round(milliseconds / 1000);
+  if (seconds < 120) {
+    return seconds + " s";
+  }
+  const minutes = Math.floor(seconds / 60);
+  if (minutes < 120) {
+    return minutes + " min " + (seconds % 60) + " s";
+  }
+  return Math.floor(minutes / 60) + " h " + (minutes % 60) + " min";
+}
+
+function idleIn(container) {
+  if (!container.isActive) {
+    return "—";
+  }
+  if (container.keepWarm || container.inWarmWindow) {
+    return "kept warm";
+  }
+  if (container.pinned) {
+    return "pinned";
+  }
+  if (!container.lastAccess) {
+    return "";
+  }
+
+  const deadline = Date.parse(container.lastAccess) + container.ttl * 1000;
+  const remaining = deadline - Date.now();
+  return remaining > 0 ? formatDuration(remaining) : "due";
+}
+
+function coldStartSummary(container) {
+  const starts = container.coldStarts || [];
+  const succeeded = starts.filter((start) => !start.error);
+  if (succeeded.length === 0) {
+    return "—";
+  }
+
+  const last = succeeded[succeeded.length - 1].durationMs;
+  const average = succeeded.reduce((total, start) => total + start.durationMs, 0) / succeeded.length;
+  return "last " + formatDuration(last) + ", avg " + formatDuration(Math.round(average));
+}
+
+function button(label, onClick, active) {
+  const element = document.createElement("button");
+  element.textContent = label;
+  element.classList.toggle("active", Boolean(active));
+  element.addEventListener("click", onClick);
+  return element;
+}
+
+// The admin token is asked once, when the gateway first refuses an action, and kept for the
+// session of the tab.
+async function send(method, url) {
+  const token = sessionStorage.getItem("adminToken");
+  const headers = token ? { Authorization: "Bearer " + token } : {};
+  return fetch(url, { method, headers });
+}
+
+async function act(method, action, container) {
+  try {
+    const url = "api/containers/" + action + "?container=" + encodeURIComponent(container);
+    let response = await send(method, url);
+    if (response.status === 401) {
+      const token = window.prompt("Admin token (ADMIN_TOKEN)");
+      if (token) {
+        sessionStorage.setItem("adminToken", token);
+        response = await send(method, url);
+      }
+    }
+    if (!response.ok) {
+      throw new Error(action + " " + container + ": " + (await response.text()).trim());
+    }
+    setStatus(action + " " + container + " requested", false);
+  } catch (error) {
+    setStatus(error.message, true);
+  }
+  refresh();
+}
+
+function actions(container) {
+  const wrapper = document.createElement("div");
+  wrapper.className = "actions";
+  wrapper.appendChild(button("Wake", () => act("POST", "wake", container.key)));
+  wrapper.appendChild(button("Stop", () => act("POST", "stop", container.key)));
+  wrapper.appendChild(button(container.pinned ? "Unpin" : "Pin",
+    () => act(container.pinned ? "DELETE" : "POST", "pin", container.key), container.pinned));
+  return wrapper;
+}
+
+function render() {
+  const body = document.getElementById("routes");
+  body.replaceChildren();
+  document.getElementById("empty").hidden = routes.length > 0;
+
+  for (const route of routes) {
+    const row = body.insertRow();
+    cell(row, route.host);
+    cell(row, route.path);
+
+    const container = containers.get(route.container);
+    if (!container) {
+      cell(row, route.container || route.backend, "muted");
+      for (let i = 0; i < 6; i++) {
+        cell(row, "");
+      }
+      continue;
+    }
+
+    cell(row, container.key);
+    cell(row, container.state || "unknown", "state " + (container.state || ""));
+    cell(row, container.lastAccess ? new Date(container.lastAccess).toLocaleString() : "");
+    cell(row, idleIn(container), "countdown");
+    cell(row, coldStartSummary(container));
+
+    const starts = (container.coldStarts || []).length;
+    cell(row, starts ? Math.round(container.startErrorRate * 100) + "% of " + starts : "—",
+      container.startErrorRate > 0 ? "rate error" : "rate");
+    cell(row, actions(container));
+  }
+}
+
+function watchEvents() {
+  if (!window.EventSource) {
+    return;
+  }
+  const source = new EventSource("api/events?format=sse&types=container.*,store.*");
+  const onEvent = () => refresh();
+  for (const type of ["container.started", "container.start_failed", "container.stopped",
+    "container.state_changed", "store.added", "store.updated", "store.removed"]) {
+    source.addEventListener(type, onEvent);
+  }
+}
+
+refresh();
+watchEvents();
+setInterval(refresh, refreshInterval);
+setInterval(render, 1000);
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package admin
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+// routeView is the admin representation of a route.
+type routeView struct {
+	Host      string `json:"host"`
+	Path      string `json:"path"`
+	Container string `json:"container,omitempty"` // Key of the managed container, as listed by /api/containers
+	Backend   string `json:"backend,omitempty"`
+}
+
+// handleRoutes lists every route of every host, ordered by host and path.
+func handleRoutes(w http.ResponseWriter, r *http.Request) {
+	hostStore := config.GetHostStore()
+
+	hosts := hostStore.ListHosts()
+	sort.Strings(hosts)
+
+	views := make([]routeView, 0)
+	for _, host := range hosts {
+		routes, _ := hostStore.GetAllRoutes(host)
+		sort.Slice(routes, func(i, j int) bool {
+			return routes[i].Path < routes[j].Path
+		})
+
+		for _, route := range routes {
+			view := routeView{Host: host, Path: route.Path}
+			if route.Backend.Managed() {
+				view.Container = route.Backend.ContainerKey()
+			}
+			if route.Backend.Protocol != "" {
+				view.Backend = route.Backend.Protocol + "://" + route.Backend.Address()
+			}
+			views = append(views, view)
+		}
+	}
+
+	writeJSON(w, views)
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"os"
+	"strings"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+// loadAPIKeys reads the API keys of a file and an environment variable. Keys are kept as SHA-256
+// digests, so looking one up takes the same time whatever its content.
+func loadAPIKeys(settings config.APIKeysConfig) (map[[32]byte]bool, error) {
+	keys := make(map[[32]byte]bool)
+
+	if settings.File != "" {
+		file, err := os.Open(settings.File)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				keys[sha256.Sum256([]byte(line))] = true
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if settings.Env != "" {
+		for _, key := range strings.Split(os.Getenv(settings.Env), ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				keys[sha256.Sum256([]byte(key))] = true
+			}
+		}
+	}
+
+	return keys, nil
+}
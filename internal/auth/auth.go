@@ -0,0 +1,179 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+// Methods a request can be authenticated with.
+const (
+	MethodAPIKey = "apiKey"
+	MethodBasic  = "basic"
+	MethodJWT    = "jwt"
+)
+
+// ErrUnauthenticated is wrapped by the errors of requests no configured method authenticates.
+var ErrUnauthenticated = errors.New("request is not authenticated")
+
+// Identity is the authenticated client of a request.
+type Identity struct {
+	Method  string
+	Subject string            // User name, sub claim, or digest prefix of the API key
+	Headers map[string]string // Request headers sent to the backend, from the claims of a JWT
+}
+
+// Authenticator checks the credentials of requests against the authentication settings of a host
+// or route.
+type Authenticator struct {
+	settings config.AuthConfig
+	realm    string
+	apiKeys  map[[32]byte]bool
+	users    map[string]string
+	jwt      *jwtVerifier
+}
+
+// New creates the authenticator of the settings of a host, reading its API keys and htpasswd
+// file. The JWKS is read when the first token is verified.
+func New(settings config.AuthConfig, host string) (*Authenticator, error) {
+	authenticator := &Authenticator{settings: settings, realm: settings.Basic.Realm}
+	if authenticator.realm == "" {
+		authenticator.realm = host
+	}
+
+	if settings.APIKeys.Enabled() {
+		keys, err := loadAPIKeys(settings.APIKeys)
+		if err != nil {
+			return nil, fmt.Errorf("API keys: %v", err)
+		}
+		authenticator.apiKeys = keys
+	}
+
+	if settings.Basic.Enabled() {
+		users, err := loadHtpasswd(settings.Basic.Htpasswd)
+		if err != nil {
+			return nil, fmt.Errorf("htpasswd %s: %v", settings.Basic.Htpasswd, err)
+		}
+		authenticator.users = users
+	}
+
+	if settings.JWT.Enabled() {
+		authenticator.jwt = newJWTVerifier(settings.JWT)
+	}
+
+	return authenticator, nil
+}
+
+// Authenticate returns the identity of the client of a request. API keys are taken from their
+// header or a bearer token, Basic credentials and JWTs from the Authorization header.
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, error) {
+	scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	credentials = strings.TrimSpace(credentials)
+	bearer := ""
+	if strings.EqualFold(scheme, "Bearer") {
+		bearer = credentials
+	}
+
+	reason := "no credentials"
+
+	if a.apiKeys != nil {
+		for _, key := range []string{r.Header.Get(a.settings.APIKeys.Header), bearer} {
+			if key == "" {
+				continue
+			}
+			digest := sha256.Sum256([]byte(key))
+			if a.apiKeys[digest] {
+				return Identity{Method: MethodAPIKey, Subject: hex.EncodeToString(digest[:4])}, nil
+			}
+			reason = "unknown API key"
+		}
+	}
+
+	if a.users != nil {
+		if user, password, ok := r.BasicAuth(); ok {
+			if hash, exists := a.users[user]; exists && verifyPassword(hash, password) {
+				return Identity{Method: MethodBasic, Subject: user}, nil
+			}
+			reason = "invalid password of user " + user
+		}
+	}
+
+	if a.jwt != nil && bearer != "" {
+		claims, err := a.jwt.verify(bearer, time.Now())
+		if err == nil {
+			identity := Identity{Method: MethodJWT, Headers: make(map[string]string)}
+			identity.Subject, _ = claimValue(claims, "sub")
+			for claim, header := range a.settings.JWT.ClaimsToHeaders {
+				if value, exists := claimValue(claims, claim); exists {
+					identity.Headers[header] = value
+				}
+			}
+			return identity, nil
+		}
+		reason = err.Error()
+	}
+
+	return Identity{}, fmt.Errorf("%w: %s", ErrUnauthenticated, reason)
+}
+
+// APIKey returns the digest of the valid API key carried by a request, in its header or as a
+// bearer token. It reports false when the request carries none, without checking other credentials.
+func (a *Authenticator) APIKey(r *http.Request) (string, bool) {
+	if a.apiKeys == nil {
+		return "", false
+	}
+
+	bearer := ""
+	if scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " "); strings.EqualFold(scheme, "Bearer") {
+		bearer = strings.TrimSpace(credentials)
+	}
+
+	for _, key := range []string{r.Header.Get(a.settings.APIKeys.Header), bearer} {
+		if key == "" {
+			continue
+		}
+		if digest := sha256.Sum256([]byte(key)); a.apiKeys[digest] {
+			return hex.EncodeToString(digest[:]), true
+		}
+	}
+	return "", false
+}
+
+// Challenge returns the WWW-Authenticate header of the responses to unauthenticated requests.
+func (a *Authenticator) Challenge() string {
+	if a.users != nil {
+		return fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", a.realm)
+	}
+	return fmt.Sprintf("Bearer realm=%q", a.realm)
+}
+
+// ClaimHeaders returns the request headers set from the claims of a JWT. Clients cannot send them
+// themselves.
+func (a *Authenticator) ClaimHeaders() []string {
+	headers := make([]string, 0, len(a.settings.JWT.ClaimsToHeaders))
+	for _, header := range a.settings.JWT.ClaimsToHeaders {
+		headers = append(headers, header)
+	}
+	return headers
+}
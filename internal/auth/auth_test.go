@@ -0,0 +1,187 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+func TestAPR1MatchesHtpasswd(t *testing.T) {
+	// Generated with: openssl passwd -apr1 -salt abcdefgh secret
+	const hash = "$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/"
+
+	if !verifyPassword(hash, "secret") || verifyPassword(hash, "wrong") {
+		t.Errorf("APR1 hash %s was not verified against its password", hash)
+	}
+}
+
+func TestBasicAuthAndAPIKeys(t *testing.T) {
+	dir := t.TempDir()
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("alice-pw"), bcrypt.MinCost)
+	htpasswd := filepath.Join(dir, "htpasswd")
+	os.WriteFile(htpasswd, []byte("alice:"+string(bcryptHash)+"\nbob:{SHA}bOWgjgJew8XNjPXTyFghAc+ha1M=\n"), 0o644)
+	keys := filepath.Join(dir, "keys")
+	os.WriteFile(keys, []byte("# deploy keys\nkey-1\n"), 0o644)
+	t.Setenv("TEST_API_KEYS", "key-2, key-3")
+
+	authenticator, err := New(config.AuthConfig{
+		APIKeys: config.APIKeysConfig{File: keys, Env: "TEST_API_KEYS", Header: "X-API-Key"},
+		Basic:   config.BasicAuthConfig{Htpasswd: htpasswd},
+	}, "example.com")
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	request := func(configure func(r *http.Request)) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		configure(r)
+		return r
+	}
+
+	accepted := map[string]*http.Request{
+		"bcrypt user": request(func(r *http.Request) { r.SetBasicAuth("alice", "alice-pw") }),
+		"SHA user":    request(func(r *http.Request) { r.SetBasicAuth("bob", "bob-pw") }),
+		"key of file": request(func(r *http.Request) { r.Header.Set("X-API-Key", "key-1") }),
+		"key of env":  request(func(r *http.Request) { r.Header.Set("Authorization", "Bearer key-3") }),
+	}
+	for name, r := range accepted {
+		if _, err := authenticator.Authenticate(r); err != nil {
+			t.Errorf("%s was refused: %v", name, err)
+		}
+	}
+
+	refused := map[string]*http.Request{
+		"anonymous":      request(func(r *http.Request) {}),
+		"wrong password": request(func(r *http.Request) { r.SetBasicAuth("alice", "bob-pw") }),
+		"unknown key":    request(func(r *http.Request) { r.Header.Set("X-API-Key", "key-4") }),
+	}
+	for name, r := range refused {
+		if _, err := authenticator.Authenticate(r); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("%s was not refused: %v", name, err)
+		}
+	}
+
+	if challenge := authenticator.Challenge(); challenge != `Basic realm="example.com", charset="UTF-8"` {
+		t.Errorf("Challenge() = %q", challenge)
+	}
+}
+
+func TestJWTAgainstJWKSURL(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []jwk{{
+		Kty: "RSA",
+		Kid: "k1",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(jwks)
+	}))
+	defer server.Close()
+
+	authenticator, _ := New(config.AuthConfig{JWT: config.JWTConfig{
+		JWKSURL:         server.URL,
+		RefreshSeconds:  600,
+		Issuer:          "https://issuer.example.com",
+		Audience:        "gateway",
+		ClaimsToHeaders: map[string]string{"sub": "X-User", "realm_access.roles": "X-Roles"},
+	}}, "example.com")
+
+	sign := func(claims map[string]interface{}) *http.Request {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+		payload, _ := json.Marshal(claims)
+		signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		digest := sha256.Sum256([]byte(signed))
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		r.Header.Set("Authorization", "Bearer "+signed+"."+base64.RawURLEncoding.EncodeToString(signature))
+		return r
+	}
+
+	valid := map[string]interface{}{
+		"iss":          "https://issuer.example.com",
+		"aud":          []string{"other", "gateway"},
+		"sub":          "user-42",
+		"exp":          time.Now().Add(time.Hour).Unix(),
+		"realm_access": map[string]interface{}{"roles": []string{"admin", "ops"}},
+	}
+	identity, err := authenticator.Authenticate(sign(valid))
+	if err != nil {
+		t.Fatalf("valid token was refused: %v", err)
+	}
+	if identity.Subject != "user-42" || identity.Headers["X-User"] != "user-42" || identity.Headers["X-Roles"] != "admin,ops" {
+		t.Errorf("identity = %+v, want user-42 with roles admin,ops", identity)
+	}
+
+	for name, change := range map[string]func(claims map[string]interface{}){
+		"expired":        func(claims map[string]interface{}) { claims["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"without exp":    func(claims map[string]interface{}) { delete(claims, "exp") },
+		"wrong audience": func(claims map[string]interface{}) { claims["aud"] = "other" },
+		"wrong issuer":   func(claims map[string]interface{}) { claims["iss"] = "https://evil.example.com" },
+	} {
+		claims := make(map[string]interface{})
+		for claim, value := range valid {
+			claims[claim] = value
+		}
+		change(claims)
+		if _, err := authenticator.Authenticate(sign(claims)); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("%s token was not refused: %v", name, err)
+		}
+	}
+}
+
+func TestCheckClaimsExp(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name            string
+		claims          map[string]interface{}
+		allowMissingExp bool
+		wantErr         bool
+	}{
+		{name: "valid", claims: map[string]interface{}{"exp": float64(now.Add(time.Hour).Unix())}},
+		{name: "expired", claims: map[string]interface{}{"exp": float64(now.Add(-time.Hour).Unix())}, wantErr: true},
+		{name: "missing", claims: map[string]interface{}{}, wantErr: true},
+		{name: "missing allowed", claims: map[string]interface{}{}, allowMissingExp: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verifier := &jwtVerifier{settings: config.JWTConfig{AllowMissingExp: test.allowMissingExp}}
+			if err := verifier.checkClaims(test.claims, now); (err != nil) != test.wantErr {
+				t.Errorf("checkClaims() error = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
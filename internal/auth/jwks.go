@@ -0,0 +1,219 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// minRefetchInterval bounds how often a JWKS is fetched again for a key ID it does not have.
+var minRefetchInterval = time.Minute
+
+// jwk is a JSON Web Key of a JWKS (RFC 7517).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// signingKey is a public key of a JWKS.
+type signingKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// keySet is the JWKS of a JWT validation, read from a file or fetched from a URL. It is read again
+// after the refresh interval, and sooner when a token is signed by an unknown key.
+type keySet struct {
+	file    string
+	url     string
+	refresh time.Duration
+
+	mutex   sync.Mutex
+	keys    []signingKey
+	fetched time.Time
+}
+
+// find returns the key with a key ID, or the only key of the set when kid is empty.
+func (ks *keySet) find(kid string) (signingKey, error) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	now := time.Now()
+	stale := now.Sub(ks.fetched) > ks.refresh
+	if stale || (!ks.has(kid) && now.Sub(ks.fetched) > minRefetchInterval) {
+		keys, err := ks.load()
+		if err != nil {
+			log.Printf("Error reading JWKS %s%s: %v", ks.file, ks.url, err)
+		} else {
+			ks.keys = keys
+		}
+		ks.fetched = now
+	}
+
+	for _, key := range ks.keys {
+		if key.kid == kid || (kid == "" && len(ks.keys) == 1) {
+			return key, nil
+		}
+	}
+	return signingKey{}, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// has reports whether the set has the key with a key ID.
+func (ks *keySet) has(kid string) bool {
+	for _, key := range ks.keys {
+		if key.kid == kid {
+			return true
+		}
+	}
+	return false
+}
+
+// load reads the JWKS and parses its signing keys. Keys that are not for signatures or have an
+// unsupported type are skipped.
+func (ks *keySet) load() ([]signingKey, error) {
+	var content []byte
+	var err error
+	if ks.file != "" {
+		content, err = os.ReadFile(ks.file)
+	} else {
+		content, err = fetch(ks.url)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, err
+	}
+
+	var keys []signingKey
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		publicKey, err := key.publicKey()
+		if err != nil {
+			log.Printf("Skipping key %q of JWKS %s%s: %v", key.Kid, ks.file, ks.url, err)
+			continue
+		}
+		keys = append(keys, signingKey{kid: key.Kid, alg: key.Alg, key: publicKey})
+	}
+	return keys, nil
+}
+
+// fetch downloads a JWKS.
+func fetch(url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// publicKey decodes the public key of a JWK: RSA, EC (P-256, P-384, P-521) or OKP (Ed25519).
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeBigInt decodes a base64url-encoded unsigned integer.
+func decodeBigInt(value string) (*big.Int, error) {
+	content, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(content), nil
+}
@@ -0,0 +1,228 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+// clockSkew is the leeway allowed on the exp and nbf claims.
+const clockSkew = 30 * time.Second
+
+// jwtVerifier validates JWTs against a JWKS and the required claims.
+type jwtVerifier struct {
+	settings config.JWTConfig
+	keys     *keySet
+}
+
+// newJWTVerifier creates the verifier of a JWT configuration. The JWKS is read on first use.
+func newJWTVerifier(settings config.JWTConfig) *jwtVerifier {
+	return &jwtVerifier{
+		settings: settings,
+		keys: &keySet{
+			file:    settings.JWKSFile,
+			url:     settings.JWKSURL,
+			refresh: time.Duration(settings.RefreshSeconds) * time.Second,
+		},
+	}
+}
+
+// verify checks the signature and the claims of a token and returns its claims.
+func (v *jwtVerifier) verify(token string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %v", err)
+	}
+
+	key, err := v.keys.find(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return nil, fmt.Errorf("algorithm %s does not match the key", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	if err := verifySignature(header.Alg, key.key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %v", err)
+	}
+	return claims, v.checkClaims(claims, now)
+}
+
+// checkClaims checks the validity period, issuer and audience of a token. Tokens without exp are
+// refused unless allowMissingExp is set.
+func (v *jwtVerifier) checkClaims(claims map[string]interface{}, now time.Time) error {
+	exp, exists := claims["exp"].(float64)
+	if !exists && !v.settings.AllowMissingExp {
+		return errors.New("token without exp claim")
+	}
+	if exists && now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return errors.New("token expired")
+	}
+	if nbf, exists := claims["nbf"].(float64); exists && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token not valid yet")
+	}
+
+	if v.settings.Issuer != "" && claims["iss"] != v.settings.Issuer {
+		return fmt.Errorf("unexpected issuer %v", claims["iss"])
+	}
+
+	if v.settings.Audience != "" {
+		switch audience := claims["aud"].(type) {
+		case string:
+			if audience == v.settings.Audience {
+				return nil
+			}
+		case []interface{}:
+			for _, entry := range audience {
+				if entry == v.settings.Audience {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("unexpected audience %v", claims["aud"])
+	}
+	return nil
+}
+
+// verifySignature checks the signature of a token signed with one of the RS, PS, ES or EdDSA
+// algorithms. Symmetric algorithms and "none" are refused.
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	if alg == "EdDSA" {
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(publicKey, signed, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	digest := hash.New()
+	digest.Write(signed)
+	sum := digest.Sum(nil)
+
+	var valid bool
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			valid = rsa.VerifyPKCS1v15(publicKey, hash, sum, signature) == nil
+		case "PS":
+			valid = rsa.VerifyPSS(publicKey, hash, sum, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		if alg[:2] == "ES" && len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			valid = ecdsa.Verify(publicKey, sum, r, s)
+		}
+	}
+
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url-encoded JSON segment of a token.
+func decodeSegment(segment string, target interface{}) error {
+	content, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, target)
+}
+
+// claimValue returns a claim as a header value. Dotted names reach into nested claims, and lists
+// are joined with commas.
+func claimValue(claims map[string]interface{}, name string) (string, bool) {
+	var value interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if value, ok = object[part]; !ok {
+			return "", false
+		}
+	}
+
+	switch typed := value.(type) {
+	case string:
+		return typed, true
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(typed), true
+	case []interface{}:
+		entries := make([]string, 0, len(typed))
+		for _, entry := range typed {
+			entries = append(entries, fmt.Sprint(entry))
+		}
+		return strings.Join(entries, ","), true
+	case nil:
+		return "", false
+	default:
+		content, _ := json.Marshal(typed)
+		return string(content), true
+	}
+}
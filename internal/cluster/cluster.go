@@ -0,0 +1,85 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Cluster is the membership of a gateway instance in a group of instances sharing a Store.
+// One instance at a time holds the leader lease.
+type Cluster struct {
+	store    Store
+	instance string
+	leaseTTL time.Duration
+	leader   atomic.Bool
+}
+
+// New creates the membership of instance in the cluster kept in store. The leader lease lasts
+// leaseTTL and is renewed three times within it.
+func New(store Store, instance string, leaseTTL time.Duration) *Cluster {
+	return &Cluster{store: store, instance: instance, leaseTTL: leaseTTL}
+}
+
+// Store returns the state shared by the cluster.
+func (c *Cluster) Store() Store {
+	return c.store
+}
+
+// Instance returns the name of this instance.
+func (c *Cluster) Instance() string {
+	return c.instance
+}
+
+// LeaseTTL returns how long the leader lease, and any published state, lasts.
+func (c *Cluster) LeaseTTL() time.Duration {
+	return c.leaseTTL
+}
+
+// IsLeader reports whether this instance held the leader lease at the last election.
+func (c *Cluster) IsLeader() bool {
+	return c.leader.Load()
+}
+
+// Run keeps taking part in the leader election.
+func (c *Cluster) Run() {
+	for {
+		c.Elect(context.Background())
+		time.Sleep(c.leaseTTL / 3)
+	}
+}
+
+// Elect takes or renews the leader lease and reports whether this instance is the leader.
+// An instance that cannot reach the store gives up leadership, since another one may take it.
+func (c *Cluster) Elect(ctx context.Context) bool {
+	leader, err := c.store.AcquireLeadership(ctx, c.instance, c.leaseTTL)
+	if err != nil {
+		log.Printf("Error renewing the leader lease of instance %s: %v", c.instance, err)
+		leader = false
+	}
+
+	if c.leader.Swap(leader) != leader {
+		if leader {
+			log.Printf("Instance %s is now the cluster leader", c.instance)
+		} else {
+			log.Printf("Instance %s is no longer the cluster leader", c.instance)
+		}
+	}
+	return leader
+}
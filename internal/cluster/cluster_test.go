@@ -0,0 +1,303 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/ratelimit"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+		"file":   func(t *testing.T) Store { return NewFileStore(filepath.Join(t.TempDir(), "cluster.json")) },
+		"redis":  func(t *testing.T) Store { return NewRedisStore(startFakeRedis(t), "") },
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, newStore(t))
+		})
+	}
+}
+
+func TestSharedRateLimitStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) ratelimit.Store{
+		"memory": func(t *testing.T) ratelimit.Store { return NewMemoryStore() },
+		"redis":  func(t *testing.T) ratelimit.Store { return NewRedisStore(startFakeRedis(t), "") },
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store, ctx := newStore(t), context.Background()
+
+			limit := ratelimit.Limit{Rate: 0.1, Burst: 2}
+			for i, want := range []bool{true, true, false} {
+				result, err := store.Take(ctx, "ip:10.0.0.1", limit)
+				if err != nil || result.Allowed != want {
+					t.Errorf("Take() %d = %+v, %v, want allowed %v", i, result, err, want)
+				}
+				if !want && result.RetryAfter <= 0 {
+					t.Errorf("refused Take() has no retry delay: %+v", result)
+				}
+			}
+			if result, err := store.Take(ctx, "ip:10.0.0.2", limit); err != nil || !result.Allowed {
+				t.Errorf("Take() of another client = %+v, %v, want allowed", result, err)
+			}
+		})
+	}
+}
+
+func TestClusterElectsSingleLeader(t *testing.T) {
+	store := NewMemoryStore()
+	first := New(store, "gw-1", 50*time.Millisecond)
+	second := New(store, "gw-2", 50*time.Millisecond)
+
+	if !first.Elect(context.Background()) || second.Elect(context.Background()) {
+		t.Fatalf("leaders = %v, %v, want only gw-1", first.IsLeader(), second.IsLeader())
+	}
+
+	time.Sleep(80 * time.Millisecond)
+	if !second.Elect(context.Background()) {
+		t.Errorf("gw-2 did not take over the expired lease")
+	}
+	if first.Elect(context.Background()) {
+		t.Errorf("gw-1 kept the leadership after gw-2 took it")
+	}
+}
+
+// testStore checks the behaviour every Store backend must share.
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	if leader, err := store.AcquireLeadership(ctx, "gw-1", time.Minute); err != nil || !leader {
+		t.Fatalf("AcquireLeadership(gw-1) = %v, %v, want leader", leader, err)
+	}
+	if leader, err := store.AcquireLeadership(ctx, "gw-2", time.Minute); err != nil || leader {
+		t.Errorf("AcquireLeadership(gw-2) = %v, %v, want follower", leader, err)
+	}
+	if leader, err := store.AcquireLeadership(ctx, "gw-1", time.Minute); err != nil || !leader {
+		t.Errorf("renewing gw-1 = %v, %v, want leader", leader, err)
+	}
+
+	accessed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	state := InstanceState{
+		Instance:   "gw-2",
+		LastAccess: map[string]time.Time{"app": accessed},
+		InFlight:   map[string]int{"app": 2},
+		Pins:       map[string]PinChange{"app": {Pinned: true, ChangedAt: accessed}},
+	}
+	if err := store.PublishState(ctx, state, time.Minute); err != nil {
+		t.Fatalf("PublishState: %v", err)
+	}
+	if err := store.PublishState(ctx, InstanceState{Instance: "gw-3"}, time.Millisecond); err != nil {
+		t.Fatalf("PublishState: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	states, err := store.States(ctx)
+	if err != nil || len(states) != 1 || states[0].Instance != "gw-2" {
+		t.Fatalf("States() = %+v, %v, want only gw-2", states, err)
+	}
+	if !states[0].LastAccess["app"].Equal(accessed) || states[0].InFlight["app"] != 2 || !states[0].Pins["app"].Pinned {
+		t.Errorf("gw-2 state = %+v, want %+v", states[0], state)
+	}
+
+	store.PushWake(ctx, "app")
+	store.PushWake(ctx, "vm2/db")
+	wakes, err := store.PopWakes(ctx)
+	if err != nil || strings.Join(wakes, ",") != "app,vm2/db" {
+		t.Errorf("PopWakes() = %v, %v, want [app vm2/db]", wakes, err)
+	}
+	if wakes, _ := store.PopWakes(ctx); len(wakes) != 0 {
+		t.Errorf("PopWakes() = %v after draining the queue", wakes)
+	}
+
+	store.PushStop(ctx, "app")
+	if stops, err := store.PopStops(ctx); err != nil || strings.Join(stops, ",") != "app" {
+		t.Errorf("PopStops() = %v, %v, want [app]", stops, err)
+	}
+	if stops, _ := store.PopStops(ctx); len(stops) != 0 {
+		t.Errorf("PopStops() = %v after draining the queue", stops)
+	}
+}
+
+// startFakeRedis serves the few Redis commands the RedisStore uses and returns its address.
+func startFakeRedis(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeRedis{
+		values:  make(map[string]string),
+		expires: make(map[string]time.Time),
+		sets:    make(map[string]map[string]bool),
+		lists:   make(map[string][]string),
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+type fakeRedis struct {
+	mutex   sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+	sets    map[string]map[string]bool
+	lists   map[string][]string
+}
+
+func (fr *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		command, err := readReply(reader)
+		if err != nil {
+			return
+		}
+
+		var args []string
+		for _, arg := range command.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+
+		fr.mutex.Lock()
+		reply := fr.execute(args)
+		fr.mutex.Unlock()
+
+		conn.Write([]byte(reply))
+	}
+}
+
+func (fr *fakeRedis) execute(args []string) string {
+	const nilReply = "$-1\r\n"
+	now := time.Now()
+
+	for key, expiry := range fr.expires {
+		if now.After(expiry) {
+			delete(fr.values, key)
+			delete(fr.expires, key)
+		}
+	}
+
+	bulk := func(value string) string {
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		key, value := args[1], args[2]
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				if _, exists := fr.values[key]; exists {
+					return nilReply
+				}
+			case "PX":
+				millis, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(millis) * time.Millisecond
+				i++
+			}
+		}
+		fr.values[key] = value
+		delete(fr.expires, key)
+		if ttl > 0 {
+			fr.expires[key] = now.Add(ttl)
+		}
+		return "+OK\r\n"
+	case "GET":
+		value, exists := fr.values[args[1]]
+		if !exists {
+			return nilReply
+		}
+		return bulk(value)
+	case "PEXPIRE":
+		if _, exists := fr.values[args[1]]; !exists {
+			return ":0\r\n"
+		}
+		millis, _ := strconv.Atoi(args[2])
+		fr.expires[args[1]] = now.Add(time.Duration(millis) * time.Millisecond)
+		return ":1\r\n"
+	case "SADD":
+		if fr.sets[args[1]] == nil {
+			fr.sets[args[1]] = make(map[string]bool)
+		}
+		fr.sets[args[1]][args[2]] = true
+		return ":1\r\n"
+	case "SREM":
+		delete(fr.sets[args[1]], args[2])
+		return ":1\r\n"
+	case "SMEMBERS":
+		reply := fmt.Sprintf("*%d\r\n", len(fr.sets[args[1]]))
+		for member := range fr.sets[args[1]] {
+			reply += bulk(member)
+		}
+		return reply
+	case "RPUSH":
+		fr.lists[args[1]] = append(fr.lists[args[1]], args[2:]...)
+		return fmt.Sprintf(":%d\r\n", len(fr.lists[args[1]]))
+	case "EVAL":
+		// Carries out the rate limit script, the only one the RedisStore runs.
+		var bucket ratelimit.Bucket
+		json.Unmarshal([]byte(fr.values[args[3]]), &bucket)
+		rate, _ := strconv.ParseFloat(args[4], 64)
+		burst, _ := strconv.Atoi(args[5])
+		millis, _ := strconv.ParseInt(args[6], 10, 64)
+
+		result := bucket.Take(ratelimit.Limit{Rate: rate, Burst: burst}, time.UnixMilli(millis))
+		content, _ := json.Marshal(bucket)
+		fr.values[args[3]] = string(content)
+
+		allowed := 0
+		if result.Allowed {
+			allowed = 1
+		}
+		return fmt.Sprintf("*2\r\n:%d\r\n", allowed) + bulk(strconv.FormatFloat(bucket.Tokens, 'f', -1, 64))
+	case "LPOP":
+		list := fr.lists[args[1]]
+		if len(list) == 0 {
+			return nilReply
+		}
+		fr.lists[args[1]] = list[1:]
+		return bulk(list[0])
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
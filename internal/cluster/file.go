@@ -0,0 +1,136 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+var _ Store = (*FileStore)(nil)
+
+// FileStore is a Store kept in a JSON file, typically on a volume shared by the instances.
+// Every operation holds an exclusive lock on a companion ".lock" file.
+type FileStore struct {
+	path string
+}
+
+// fileState is the content of the FileStore file.
+type fileState struct {
+	Leader      string                   `json:"leader"`
+	LeaseExpiry time.Time                `json:"leaseExpiry"`
+	States      map[string]expiringState `json:"states"`
+	Wakes       []string                 `json:"wakes"`
+	Stops       []string                 `json:"stops"`
+}
+
+// NewFileStore creates a FileStore kept at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (fs *FileStore) AcquireLeadership(ctx context.Context, instance string, ttl time.Duration) (bool, error) {
+	leader := false
+	err := fs.update(func(state *fileState) {
+		now := time.Now()
+		if state.Leader == instance || now.After(state.LeaseExpiry) {
+			state.Leader, state.LeaseExpiry = instance, now.Add(ttl)
+			leader = true
+		}
+	})
+	return leader, err
+}
+
+func (fs *FileStore) PublishState(ctx context.Context, instanceState InstanceState, ttl time.Duration) error {
+	return fs.update(func(state *fileState) {
+		state.States[instanceState.Instance] = expiringState{InstanceState: instanceState, ExpiresAt: time.Now().Add(ttl)}
+	})
+}
+
+func (fs *FileStore) States(ctx context.Context) ([]InstanceState, error) {
+	var live []InstanceState
+	err := fs.update(func(state *fileState) {
+		live = liveStates(state.States, time.Now())
+	})
+	return live, err
+}
+
+func (fs *FileStore) PushWake(ctx context.Context, containerKey string) error {
+	return fs.update(func(state *fileState) {
+		state.Wakes = append(state.Wakes, containerKey)
+	})
+}
+
+func (fs *FileStore) PopWakes(ctx context.Context) ([]string, error) {
+	var wakes []string
+	err := fs.update(func(state *fileState) {
+		wakes, state.Wakes = state.Wakes, nil
+	})
+	return wakes, err
+}
+
+func (fs *FileStore) PushStop(ctx context.Context, containerKey string) error {
+	return fs.update(func(state *fileState) {
+		state.Stops = append(state.Stops, containerKey)
+	})
+}
+
+func (fs *FileStore) PopStops(ctx context.Context) ([]string, error) {
+	var stops []string
+	err := fs.update(func(state *fileState) {
+		stops, state.Stops = state.Stops, nil
+	})
+	return stops, err
+}
+
+// update reads the file, applies change and writes it back while holding the lock.
+func (fs *FileStore) update(change func(state *fileState)) error {
+	lock, err := os.OpenFile(fs.path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	if err := lockFile(lock); err != nil {
+		return err
+	}
+	defer unlockFile(lock)
+
+	state := fileState{States: make(map[string]expiringState)}
+	content, err := ioutil.ReadFile(fs.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, &state); err != nil {
+			return err
+		}
+		if state.States == nil {
+			state.States = make(map[string]expiringState)
+		}
+	}
+
+	change(&state)
+
+	content, err = json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fs.path, content, 0o644)
+}
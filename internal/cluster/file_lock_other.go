@@ -0,0 +1,37 @@
+//go:build !unix
+
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"errors"
+	"os"
+)
+
+// errFileLockUnsupported is returned by the file backend on platforms without flock.
+var errFileLockUnsupported = errors.New("the file cluster backend is not supported on this platform")
+
+// lockFile fails: without a lock, instances could overwrite each other's changes.
+func lockFile(file *os.File) error {
+	return errFileLockUnsupported
+}
+
+// unlockFile does nothing, as lockFile never takes a lock.
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/ratelimit"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore is a Store held in memory. It lets instances of one process, typically tests, share
+// state, and is the local stand-in for the other backends.
+type MemoryStore struct {
+	*ratelimit.MemoryStore
+
+	mutex       sync.Mutex
+	leader      string
+	leaseExpiry time.Time
+	states      map[string]expiringState
+	wakes       []string
+	stops       []string
+}
+
+// expiringState is an instance state with the time it expires at.
+type expiringState struct {
+	InstanceState
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{MemoryStore: ratelimit.NewMemoryStore(), states: make(map[string]expiringState)}
+}
+
+func (ms *MemoryStore) AcquireLeadership(ctx context.Context, instance string, ttl time.Duration) (bool, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	now := time.Now()
+	if ms.leader == instance || now.After(ms.leaseExpiry) {
+		ms.leader, ms.leaseExpiry = instance, now.Add(ttl)
+		return true, nil
+	}
+	return false, nil
+}
+
+func (ms *MemoryStore) PublishState(ctx context.Context, state InstanceState, ttl time.Duration) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.states[state.Instance] = expiringState{InstanceState: state, ExpiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (ms *MemoryStore) States(ctx context.Context) ([]InstanceState, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	return liveStates(ms.states, time.Now()), nil
+}
+
+func (ms *MemoryStore) PushWake(ctx context.Context, containerKey string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.wakes = append(ms.wakes, containerKey)
+	return nil
+}
+
+func (ms *MemoryStore) PopWakes(ctx context.Context) ([]string, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	wakes := ms.wakes
+	ms.wakes = nil
+	return wakes, nil
+}
+
+func (ms *MemoryStore) PushStop(ctx context.Context, containerKey string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.stops = append(ms.stops, containerKey)
+	return nil
+}
+
+func (ms *MemoryStore) PopStops(ctx context.Context) ([]string, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	stops := ms.stops
+	ms.stops = nil
+	return stops, nil
+}
+
+// liveStates returns the states that have not expired, ordered by instance, dropping the others.
+func liveStates(states map[string]expiringState, now time.Time) []InstanceState {
+	var live []InstanceState
+	for instance, state := range states {
+		if now.After(state.ExpiresAt) {
+			delete(states, instance)
+			continue
+		}
+		live = append(live, state.InstanceState)
+	}
+
+	sort.Slice(live, func(i, j int) bool {
+		return live[i].Instance < live[j].Instance
+	})
+	return live
+}
@@ -0,0 +1,341 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/ratelimit"
+)
+
+const (
+	redisLeaderKey    = "gateway:leader"
+	redisInstancesKey = "gateway:instances"
+	redisStatePrefix  = "gateway:instance:"
+	redisWakesKey     = "gateway:wakes"
+	redisStopsKey     = "gateway:stops"
+	redisBucketPrefix = "gateway:ratelimit:"
+)
+
+// redisTakeScript takes a token from the bucket at KEYS[1], refilled at ARGV[1] tokens per
+// second up to ARGV[2], at time ARGV[3] in milliseconds. It returns whether the token was taken
+// and the tokens left, and expires the bucket once it is full again.
+const redisTakeScript = `
+local rate, burst, now = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1])
+if tokens == nil then
+  tokens = burst
+else
+  tokens = math.min(burst, tokens + math.max(0, now - tonumber(bucket[2])) / 1000 * rate)
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1)
+return {allowed, tostring(tokens)}
+`
+
+var (
+	_ Store           = (*RedisStore)(nil)
+	_ ratelimit.Store = (*RedisStore)(nil)
+)
+
+// RedisStore is a Store kept in a Redis-compatible server. It speaks the RESP protocol over a
+// single connection, opened on first use and again after any error.
+type RedisStore struct {
+	address  string
+	password string
+
+	mutex  sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// errNil is the reply of a command to a missing key.
+var errNil = errors.New("redis: nil")
+
+// NewRedisStore creates a RedisStore for the server at address ("host:port").
+func NewRedisStore(address, password string) *RedisStore {
+	return &RedisStore{address: address, password: password}
+}
+
+func (rs *RedisStore) AcquireLeadership(ctx context.Context, instance string, ttl time.Duration) (bool, error) {
+	ttlMillis := strconv.FormatInt(ttl.Milliseconds(), 10)
+
+	if _, err := rs.do(ctx, "SET", redisLeaderKey, instance, "NX", "PX", ttlMillis); err == nil {
+		return true, nil
+	} else if err != errNil {
+		return false, err
+	}
+
+	leader, err := rs.do(ctx, "GET", redisLeaderKey)
+	if err == errNil {
+		return false, nil
+	}
+	if err != nil || leader != instance {
+		return false, err
+	}
+
+	_, err = rs.do(ctx, "PEXPIRE", redisLeaderKey, ttlMillis)
+	return err == nil, err
+}
+
+func (rs *RedisStore) PublishState(ctx context.Context, state InstanceState, ttl time.Duration) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	ttlMillis := strconv.FormatInt(ttl.Milliseconds(), 10)
+	if _, err := rs.do(ctx, "SET", redisStatePrefix+state.Instance, string(content), "PX", ttlMillis); err != nil {
+		return err
+	}
+	_, err = rs.do(ctx, "SADD", redisInstancesKey, state.Instance)
+	return err
+}
+
+func (rs *RedisStore) States(ctx context.Context) ([]InstanceState, error) {
+	instances, err := rs.do(ctx, "SMEMBERS", redisInstancesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []InstanceState
+	for _, instance := range instances.([]interface{}) {
+		name, _ := instance.(string)
+
+		content, err := rs.do(ctx, "GET", redisStatePrefix+name)
+		if err == errNil {
+			if _, err := rs.do(ctx, "SREM", redisInstancesKey, name); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var state InstanceState
+		if err := json.Unmarshal([]byte(content.(string)), &state); err != nil {
+			return nil, fmt.Errorf("invalid state of instance %s: %v", name, err)
+		}
+		states = append(states, state)
+	}
+
+	return states, nil
+}
+
+func (rs *RedisStore) PushWake(ctx context.Context, containerKey string) error {
+	_, err := rs.do(ctx, "RPUSH", redisWakesKey, containerKey)
+	return err
+}
+
+func (rs *RedisStore) PopWakes(ctx context.Context) ([]string, error) {
+	return rs.popAll(ctx, redisWakesKey)
+}
+
+func (rs *RedisStore) PushStop(ctx context.Context, containerKey string) error {
+	_, err := rs.do(ctx, "RPUSH", redisStopsKey, containerKey)
+	return err
+}
+
+func (rs *RedisStore) PopStops(ctx context.Context) ([]string, error) {
+	return rs.popAll(ctx, redisStopsKey)
+}
+
+// popAll takes every item of a list.
+func (rs *RedisStore) popAll(ctx context.Context, key string) ([]string, error) {
+	var items []string
+	for {
+		item, err := rs.do(ctx, "LPOP", key)
+		if err == errNil {
+			return items, nil
+		}
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item.(string))
+	}
+}
+
+func (rs *RedisStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	reply, err := rs.do(ctx, "EVAL", redisTakeScript, "1", redisBucketPrefix+key,
+		strconv.FormatFloat(limit.Rate, 'f', -1, 64),
+		strconv.Itoa(limit.Burst),
+		strconv.FormatInt(time.Now().UnixMilli(), 10))
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	values, _ := reply.([]interface{})
+	if len(values) != 2 {
+		return ratelimit.Result{}, fmt.Errorf("unexpected reply %v to the rate limit script", reply)
+	}
+	allowed, _ := values[0].(int64)
+	tokens, err := strconv.ParseFloat(fmt.Sprint(values[1]), 64)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	return limit.Result(allowed == 1, tokens), nil
+}
+
+// do sends a command and returns its reply: a string, an int64 or a []interface{}.
+// A nil reply is returned as errNil.
+func (rs *RedisStore) do(ctx context.Context, args ...string) (interface{}, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if rs.conn == nil {
+		if err := rs.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := rs.roundTrip(ctx, args)
+	if err != nil && err != errNil {
+		if _, isReplyError := err.(redisError); !isReplyError {
+			rs.conn.Close()
+			rs.conn = nil
+		}
+	}
+	return reply, err
+}
+
+// connect opens the connection and authenticates it, when a password is set.
+func (rs *RedisStore) connect(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", rs.address)
+	if err != nil {
+		return err
+	}
+	rs.conn, rs.reader = conn, bufio.NewReader(conn)
+
+	if rs.password != "" {
+		if _, err := rs.roundTrip(ctx, []string{"AUTH", rs.password}); err != nil {
+			rs.conn.Close()
+			rs.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// roundTrip writes a command and reads its reply.
+func (rs *RedisStore) roundTrip(ctx context.Context, args []string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	rs.conn.SetDeadline(deadline)
+
+	if _, err := rs.conn.Write(encodeCommand(args)); err != nil {
+		return nil, err
+	}
+	return readReply(rs.reader)
+}
+
+// redisError is an error reply of the server.
+type redisError string
+
+func (re redisError) Error() string {
+	return "redis: " + string(re)
+}
+
+// encodeCommand encodes a command as a RESP array of bulk strings.
+func encodeCommand(args []string) []byte {
+	command := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		command += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return []byte(command)
+}
+
+// readReply reads one RESP reply.
+func readReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, errNil
+		}
+		content := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, content); err != nil {
+			return nil, err
+		}
+		return string(content[:length]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, errNil
+		}
+		items := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			item, err := readReply(reader)
+			if err != nil && err != errNil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+// readLine reads a CRLF-terminated line, without the terminator.
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: malformed line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+	"context"
+	"time"
+)
+
+// Store is the state shared by the gateway instances of a cluster.
+type Store interface {
+	// AcquireLeadership takes the leader lease for an instance, or renews it when the instance
+	// already holds it. It reports whether the instance is the leader.
+	AcquireLeadership(ctx context.Context, instance string, ttl time.Duration) (bool, error)
+	// PublishState replaces the state shared by an instance. It expires after ttl unless published again.
+	PublishState(ctx context.Context, state InstanceState, ttl time.Duration) error
+	// States returns the state published by every live instance.
+	States(ctx context.Context) ([]InstanceState, error)
+	// PushWake queues the intent to wake a container up, for the leader to carry out.
+	PushWake(ctx context.Context, containerKey string) error
+	// PopWakes takes every queued wake-up intent.
+	PopWakes(ctx context.Context) ([]string, error)
+	// PushStop queues the intent to apply the idle action of a container at once, for the leader
+	// to carry out.
+	PushStop(ctx context.Context, containerKey string) error
+	// PopStops takes every queued stop intent.
+	PopStops(ctx context.Context) ([]string, error)
+}
+
+// InstanceState is the container state an instance shares with the others.
+type InstanceState struct {
+	Instance   string               `json:"instance"`
+	LastAccess map[string]time.Time `json:"lastAccess"` // Last request served by the instance, per container
+	InFlight   map[string]int       `json:"inFlight"`   // Requests being served by the instance, per container
+	Pins       map[string]PinChange `json:"pins"`       // Latest pin change known to the instance, per container
+}
+
+// PinChange is a container being pinned or unpinned. The latest change of every instance wins.
+type PinChange struct {
+	Pinned    bool      `json:"pinned"`
+	ChangedAt time.Time `json:"changedAt"`
+}
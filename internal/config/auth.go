@@ -0,0 +1,157 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// AuthConfig represents the authentication required by a host or route. When several methods are
+// configured, a request passing any of them is authenticated.
+type AuthConfig struct {
+	APIKeys  APIKeysConfig   `yaml:"apiKeys"`  // Static API keys
+	Basic    BasicAuthConfig `yaml:"basic"`    // Basic authentication against an htpasswd file
+	JWT      JWTConfig       `yaml:"jwt"`      // Bearer JWTs signed by a key of a JWKS
+	Disabled bool            `yaml:"disabled"` // Makes a route public even when its host requires authentication
+}
+
+// APIKeysConfig represents the static API keys accepted by a host or route.
+type APIKeysConfig struct {
+	File   string `yaml:"file"`   // File with one API key per line; empty lines and lines starting with # are ignored
+	Env    string `yaml:"env"`    // Environment variable with comma-separated API keys
+	Header string `yaml:"header"` // Header carrying the key (default X-API-Key); bearer tokens are accepted as well
+}
+
+// BasicAuthConfig represents Basic authentication against an htpasswd file.
+type BasicAuthConfig struct {
+	Htpasswd string `yaml:"htpasswd"` // htpasswd file with bcrypt, APR1-MD5 or SHA-1 entries
+	Realm    string `yaml:"realm"`    // Realm sent in the challenge (default the host)
+}
+
+// JWTConfig represents the validation of bearer JWTs.
+type JWTConfig struct {
+	JWKSFile        string            `yaml:"jwksFile"`        // Local JWKS file with the signing keys
+	JWKSURL         string            `yaml:"jwksUrl"`         // URL of the JWKS with the signing keys
+	RefreshSeconds  int               `yaml:"refreshSeconds"`  // How often the JWKS is fetched again (default 600)
+	Issuer          string            `yaml:"issuer"`          // Required iss claim, when set
+	Audience        string            `yaml:"audience"`        // Required aud claim, when set
+	ClaimsToHeaders map[string]string `yaml:"claimsToHeaders"` // Claims sent to the backend, by request header
+	AllowMissingExp bool              `yaml:"allowMissingExp"` // Accepts tokens without an exp claim, which never expire
+}
+
+// Enabled reports whether any authentication method is configured.
+func (ac AuthConfig) Enabled() bool {
+	return ac.APIKeys.Enabled() || ac.Basic.Enabled() || ac.JWT.Enabled()
+}
+
+// Enabled reports whether API keys are configured.
+func (kc APIKeysConfig) Enabled() bool {
+	return kc.File != "" || kc.Env != ""
+}
+
+// Enabled reports whether Basic authentication is configured.
+func (bc BasicAuthConfig) Enabled() bool {
+	return bc.Htpasswd != ""
+}
+
+// Enabled reports whether JWT validation is configured.
+func (jc JWTConfig) Enabled() bool {
+	return jc.JWKSFile != "" || jc.JWKSURL != ""
+}
+
+// normalizeAuth applies the defaults of the authentication settings and checks their files exist.
+// The API keys and htpasswd files must also hold at least one entry, so a broken file is reported
+// when the configuration is loaded rather than by the first request.
+func normalizeAuth(auth *AuthConfig) error {
+	if auth.APIKeys.Header == "" {
+		auth.APIKeys.Header = "X-API-Key"
+	}
+	if auth.JWT.RefreshSeconds <= 0 {
+		auth.JWT.RefreshSeconds = 600
+	}
+
+	if auth.JWT.JWKSFile != "" && auth.JWT.JWKSURL != "" {
+		return fmt.Errorf("auth.jwt: jwksFile and jwksUrl cannot be set together")
+	}
+	if auth.JWT.JWKSURL != "" {
+		if parsed, err := url.Parse(auth.JWT.JWKSURL); err != nil || parsed.Host == "" {
+			return fmt.Errorf("auth.jwt: invalid jwksUrl %q", auth.JWT.JWKSURL)
+		}
+	}
+
+	for name, path := range map[string]string{
+		"apiKeys.file":   auth.APIKeys.File,
+		"basic.htpasswd": auth.Basic.Htpasswd,
+		"jwt.jwksFile":   auth.JWT.JWKSFile,
+	} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("auth.%s: %v", name, err)
+		}
+	}
+
+	if auth.APIKeys.File != "" {
+		if err := checkEntries(auth.APIKeys.File, nil); err != nil {
+			return fmt.Errorf("auth.apiKeys.file: %v", err)
+		}
+	}
+	if auth.Basic.Htpasswd != "" {
+		err := checkEntries(auth.Basic.Htpasswd, func(line string) bool {
+			user, hash, found := strings.Cut(line, ":")
+			return found && user != "" && hash != ""
+		})
+		if err != nil {
+			return fmt.Errorf("auth.basic.htpasswd: %v", err)
+		}
+	}
+	return nil
+}
+
+// checkEntries reads a file of one entry per line, where empty lines and lines starting with #
+// are ignored, and checks it holds at least one entry and that valid accepts every entry.
+func checkEntries(path string, valid func(line string) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entries := 0
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if valid != nil && !valid(line) {
+			return fmt.Errorf("%s: invalid entry on line %d", path, number)
+		}
+		entries++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if entries == 0 {
+		return fmt.Errorf("%s holds no entry", path)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// Shared state backends of the high-availability mode.
+const (
+	ClusterFile  = "file"
+	ClusterRedis = "redis"
+)
+
+// ClusterConfig represents the high-availability mode, where several gateway instances share
+// their container state and elect a leader to stop idle containers.
+type ClusterConfig struct {
+	Backend       string `yaml:"backend"`       // Shared state backend (file or redis); high availability is disabled when empty
+	File          string `yaml:"file"`          // State file of the file backend, on a volume shared by the instances
+	RedisAddress  string `yaml:"redisAddress"`  // host:port of the Redis-compatible server of the redis backend
+	RedisPassword string `yaml:"redisPassword"` // Password of the Redis-compatible server
+	Instance      string `yaml:"instance"`      // Name of this instance; the hostname when empty
+	LeaseSeconds  int    `yaml:"leaseSeconds"`  // Seconds the leader lease lasts without being renewed
+}
+
+// Enabled reports whether the high-availability mode is configured.
+func (cc ClusterConfig) Enabled() bool {
+	return cc.Backend != ""
+}
+
+// loadClusterConfig applies the environment and the defaults to the cluster settings.
+func loadClusterConfig(cluster ClusterConfig) (ClusterConfig, error) {
+	cluster.Backend = envString("CLUSTER_BACKEND", cluster.Backend)
+	cluster.File = envString("CLUSTER_FILE", cluster.File)
+	cluster.RedisAddress = envString("CLUSTER_REDIS_ADDRESS", cluster.RedisAddress)
+	cluster.RedisPassword = envString("CLUSTER_REDIS_PASSWORD", cluster.RedisPassword)
+	cluster.Instance = envString("CLUSTER_INSTANCE", cluster.Instance)
+	cluster.LeaseSeconds = envInt("CLUSTER_LEASE_SECONDS", cluster.LeaseSeconds)
+
+	if cluster.Instance == "" {
+		cluster.Instance, _ = os.Hostname()
+	}
+	if cluster.LeaseSeconds <= 0 {
+		cluster.LeaseSeconds = 15
+	}
+
+	switch cluster.Backend {
+	case "":
+	case ClusterFile:
+		if cluster.File == "" {
+			return cluster, fmt.Errorf("cluster backend file requires a file")
+		}
+	case ClusterRedis:
+		if cluster.RedisAddress == "" {
+			return cluster, fmt.Errorf("cluster backend redis requires a redisAddress")
+		}
+	default:
+		return cluster, fmt.Errorf("unsupported cluster backend %q", cluster.Backend)
+	}
+
+	return cluster, nil
+}
@@ -54,9 +54,19 @@ func (cl *ConfigLoader) LoadConfigs() error {
 		return errors.New("no config files found")
 	}
 
+	if err := normalizeConfigs(configs); err != nil {
+		return err
+	}
+
+	policies, err := buildContainerPolicies(configs)
+	if err != nil {
+		return err
+	}
+
 	for _, config := range configs {
 		GetHostStore().AddHost(config)
 	}
+	GetHostStore().SetContainerPolicies(policies)
 
 	return nil
 }
@@ -81,13 +91,22 @@ func determineConfigDir() (string, error) {
 }
 
 // getConfigFiles retrieves all YAML configuration files from the configuration directory.
+// The gateway configuration file is skipped when it is kept in the same directory.
 func (cl *ConfigLoader) getConfigFiles() ([]string, error) {
 	var files []string
 
+	gatewayFile := os.Getenv("GATEWAY_CONFIG")
+	if gatewayFile != "" {
+		gatewayFile, _ = filepath.Abs(gatewayFile)
+	}
+
 	err := filepath.Walk(cl.configDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if absolute, _ := filepath.Abs(path); gatewayFile != "" && absolute == gatewayFile {
+			return nil
+		}
 		if !info.IsDir() && filepath.Ext(info.Name()) == ".yaml" {
 			files = append(files, path)
 		}
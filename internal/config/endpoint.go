@@ -0,0 +1,95 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// EndpointConfig represents a Docker daemon the gateway manages containers on.
+type EndpointConfig struct {
+	Name    string      `yaml:"name"`    // Name referenced by route backends
+	Host    string      `yaml:"host"`    // Daemon address: unix:///path, tcp://host:port or ssh://user@host
+	TLS     EndpointTLS `yaml:"tls"`     // Client certificates for TCP endpoints
+	Address string      `yaml:"address"` // Address backends are reached at; the host of the daemon address when empty
+}
+
+// EndpointTLS represents the TLS files used to connect to a TCP endpoint.
+type EndpointTLS struct {
+	CACert string `yaml:"caCert"` // CA certificate verifying the daemon
+	Cert   string `yaml:"cert"`   // Client certificate
+	Key    string `yaml:"key"`    // Client private key
+}
+
+// Enabled reports whether TLS files were configured.
+func (et EndpointTLS) Enabled() bool {
+	return et.CACert != "" || et.Cert != "" || et.Key != ""
+}
+
+// BackendAddress returns the address containers of the endpoint are reached at.
+func (ec EndpointConfig) BackendAddress() string {
+	if ec.Address != "" {
+		return ec.Address
+	}
+
+	parsed, err := url.Parse(ec.Host)
+	if err != nil || parsed.Scheme == "unix" || parsed.Hostname() == "" {
+		return "localhost"
+	}
+	return parsed.Hostname()
+}
+
+// validateEndpoints rejects endpoints without a name or with an unsupported address.
+func validateEndpoints(endpoints []EndpointConfig) error {
+	seen := make(map[string]bool)
+
+	for _, endpoint := range endpoints {
+		if endpoint.Name == "" {
+			return fmt.Errorf("endpoint %s has no name", endpoint.Host)
+		}
+		if seen[endpoint.Name] {
+			return fmt.Errorf("endpoint %s declared more than once", endpoint.Name)
+		}
+		seen[endpoint.Name] = true
+
+		parsed, err := url.Parse(endpoint.Host)
+		if err != nil {
+			return fmt.Errorf("endpoint %s: invalid host %q", endpoint.Name, endpoint.Host)
+		}
+
+		switch parsed.Scheme {
+		case "unix", "ssh":
+			if endpoint.TLS.Enabled() {
+				return fmt.Errorf("endpoint %s: tls is only supported over tcp", endpoint.Name)
+			}
+		case "tcp":
+		default:
+			return fmt.Errorf("endpoint %s: unsupported host %q", endpoint.Name, endpoint.Host)
+		}
+	}
+
+	return nil
+}
+
+// ContainerKey identifies a container across endpoints. Containers of the default endpoint are
+// identified by their name alone.
+func ContainerKey(endpoint, containerName string) string {
+	if endpoint == "" {
+		return containerName
+	}
+	return endpoint + "/" + containerName
+}
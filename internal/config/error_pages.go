@@ -0,0 +1,72 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// Kinds of error answered by the gateway itself, used as keys of the error templates.
+const (
+	ErrorNotFound     = "notFound"     // No route matches the request
+	ErrorBackendDown  = "backendDown"  // The backend could not be started or reached
+	ErrorStartTimeout = "startTimeout" // The container did not become healthy in time
+	ErrorStarting     = "starting"     // The container is starting and the client should retry
+	ErrorRateLimited  = "rateLimited"  // The client exceeded its rate limit
+	ErrorUnauthorized = "unauthorized" // The request is not authenticated
+	ErrorUpstream     = "upstream"     // The backend answered with a 5xx status
+)
+
+// Formats of the error responses of a host.
+const (
+	ErrorFormatAuto = "auto" // problem+json unless the client accepts HTML
+	ErrorFormatHTML = "html"
+	ErrorFormatJSON = "json"
+)
+
+// ErrorPagesConfig represents how the errors of a host are answered.
+type ErrorPagesConfig struct {
+	Format   string            `yaml:"format"`   // Response format: auto (default), html or json
+	HTML     map[string]string `yaml:"html"`     // html/template file per error kind; the built-in page when missing
+	JSON     map[string]string `yaml:"json"`     // text/template file of the problem+json body per error kind
+	Upstream bool              `yaml:"upstream"` // Replaces the body of 5xx responses of the backend with the upstream error
+}
+
+// normalizeErrorPages applies the defaults of the error pages and checks their templates exist.
+func normalizeErrorPages(errorPages *ErrorPagesConfig) error {
+	switch errorPages.Format {
+	case "":
+		errorPages.Format = ErrorFormatAuto
+	case ErrorFormatAuto, ErrorFormatHTML, ErrorFormatJSON:
+	default:
+		return fmt.Errorf("invalid errorPages.format %q", errorPages.Format)
+	}
+
+	for _, templates := range []map[string]string{errorPages.HTML, errorPages.JSON} {
+		for kind, path := range templates {
+			switch kind {
+			case ErrorNotFound, ErrorBackendDown, ErrorStartTimeout, ErrorStarting, ErrorRateLimited, ErrorUnauthorized, ErrorUpstream:
+			default:
+				return fmt.Errorf("unknown error kind %q in errorPages", kind)
+			}
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("errorPages.%s: %v", kind, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ForwardAuthConfig represents the delegation of the authorization of a route's requests to an
+// external service.
+type ForwardAuthConfig struct {
+	URL                 string   `yaml:"url"`                 // Authorization service; disabled when empty
+	AuthResponseHeaders []string `yaml:"authResponseHeaders"` // Headers of an accepting response copied onto the upstream request
+	TimeoutSeconds      int      `yaml:"timeoutSeconds"`      // Time allowed for the answer, including a cold start of the service (default 60)
+}
+
+// Enabled reports whether forward authentication is configured.
+func (fc ForwardAuthConfig) Enabled() bool {
+	return fc.URL != ""
+}
+
+// normalizeForwardAuth applies the defaults of forward authentication and validates its URL.
+func normalizeForwardAuth(forwardAuth *ForwardAuthConfig) error {
+	if !forwardAuth.Enabled() {
+		return nil
+	}
+	if parsed, err := url.Parse(forwardAuth.URL); err != nil || parsed.Host == "" ||
+		(parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("invalid forwardAuth.url %q", forwardAuth.URL)
+	}
+	if forwardAuth.TimeoutSeconds <= 0 {
+		forwardAuth.TimeoutSeconds = 60
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// GatewayConfig represents the settings of the gateway itself, shared by every host.
+type GatewayConfig struct {
+	Runtime                   string           `yaml:"runtime"`                   // Container runtime backend (docker, podman or containerd)
+	RuntimeHost               string           `yaml:"runtimeHost"`               // Address of the runtime daemon; the backend default when empty
+	ContainerdNamespace       string           `yaml:"containerdNamespace"`       // containerd namespace holding the containers
+	MemoryBudgetMB            int              `yaml:"memoryBudgetMB"`            // Memory the managed containers may use together; 0 disables the budget
+	MinAvailableMemoryPercent int              `yaml:"minAvailableMemoryPercent"` // Host available memory below which idle containers are evicted; 0 disables it
+	Endpoints                 []EndpointConfig `yaml:"endpoints"`                 // Named Docker endpoints referenced by route backends
+	StateFile                 string           `yaml:"stateFile"`                 // JSON file keeping the container state across restarts; disabled when empty
+	StartFailureCooldown      int              `yaml:"startFailureCooldown"`      // Seconds start attempts are refused after a failed start; 0 disables it
+	Cluster                   ClusterConfig    `yaml:"cluster"`                   // High-availability mode shared by several instances
+	Webhooks                  []WebhookConfig  `yaml:"webhooks"`                  // Endpoints notified of container lifecycle events
+}
+
+// Container runtime backends supported by the gateway.
+const (
+	RuntimeDocker     = "docker"
+	RuntimePodman     = "podman"
+	RuntimeContainerd = "containerd"
+)
+
+var (
+	gatewayOnce   sync.Once
+	gatewayConfig GatewayConfig
+	gatewayErr    error
+	gatewayGuard  = &sync.Mutex{}
+)
+
+// GetGatewayConfig returns the gateway settings, read once from the file in GATEWAY_CONFIG and
+// the environment. Environment variables take precedence over the file.
+func GetGatewayConfig() GatewayConfig {
+	gatewayOnce.Do(func() {
+		gateway, err := loadGatewayConfig(os.Getenv("GATEWAY_CONFIG"))
+		if err != nil {
+			log.Printf("Error loading gateway config: %v", err)
+		}
+
+		gatewayGuard.Lock()
+		gatewayConfig, gatewayErr = gateway, err
+		gatewayGuard.Unlock()
+	})
+
+	gatewayGuard.Lock()
+	defer gatewayGuard.Unlock()
+
+	return gatewayConfig
+}
+
+// SetGatewayConfig replaces the gateway settings, typically in tests.
+func SetGatewayConfig(gateway GatewayConfig) {
+	gatewayOnce.Do(func() {})
+
+	gatewayGuard.Lock()
+	defer gatewayGuard.Unlock()
+
+	gatewayConfig, gatewayErr = gateway, nil
+}
+
+// LoadGatewayConfig reads the gateway settings and reports whether they are valid.
+func LoadGatewayConfig() error {
+	GetGatewayConfig()
+
+	gatewayGuard.Lock()
+	defer gatewayGuard.Unlock()
+
+	return gatewayErr
+}
+
+// GetEndpoint returns the named Docker endpoint.
+func (gc GatewayConfig) GetEndpoint(name string) (EndpointConfig, bool) {
+	for _, endpoint := range gc.Endpoints {
+		if endpoint.Name == name {
+			return endpoint, true
+		}
+	}
+	return EndpointConfig{}, false
+}
+
+// loadGatewayConfig reads the gateway settings from file, when set, and applies the environment on top.
+func loadGatewayConfig(file string) (GatewayConfig, error) {
+	var gateway GatewayConfig
+
+	if file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return gateway, fmt.Errorf("error reading file %s: %s", file, err.Error())
+		}
+		if err := yaml.Unmarshal(content, &gateway); err != nil {
+			return gateway, fmt.Errorf("error when deserializing the file %s: %s", file, err.Error())
+		}
+	}
+
+	gateway.Runtime = envString("CONTAINER_RUNTIME", defaultString(gateway.Runtime, RuntimeDocker))
+	gateway.RuntimeHost = envString("CONTAINER_RUNTIME_HOST", gateway.RuntimeHost)
+	gateway.ContainerdNamespace = envString("CONTAINERD_NAMESPACE", defaultString(gateway.ContainerdNamespace, "default"))
+	gateway.MemoryBudgetMB = envInt("MEMORY_BUDGET_MB", gateway.MemoryBudgetMB)
+	gateway.MinAvailableMemoryPercent = envInt("MIN_AVAILABLE_MEMORY_PERCENT", gateway.MinAvailableMemoryPercent)
+	gateway.StateFile = envString("STATE_FILE", gateway.StateFile)
+	gateway.StartFailureCooldown = envInt("START_FAILURE_COOLDOWN", gateway.StartFailureCooldown)
+
+	cluster, err := loadClusterConfig(gateway.Cluster)
+	if err != nil {
+		return gateway, err
+	}
+	gateway.Cluster = cluster
+
+	if err := normalizeWebhooks(gateway.Webhooks); err != nil {
+		return gateway, err
+	}
+
+	return gateway, validateEndpoints(gateway.Endpoints)
+}
+
+// defaultString returns value, or fallback when value is empty.
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// envString reads a string environment variable, returning fallback when it is unset.
+func envString(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envInt reads an integer environment variable, returning fallback when it is unset or invalid.
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Ignoring invalid value %q for %s", value, name)
+		return fallback
+	}
+	return parsed
+}
@@ -0,0 +1,264 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ContainerPolicy is the lifecycle policy of a container, merged from every route that uses it.
+type ContainerPolicy struct {
+	ContainerName    string               // Container, or Swarm service, the policy applies to
+	Service          bool                 // Whether ContainerName is a Swarm service
+	Replicas         int                  // Largest replica count of the Swarm service among the routes
+	Endpoint         string               // Docker endpoint running the container and its dependencies
+	TTL              int                  // Largest TTL among the routes
+	IdleAction       string               // Idle action shared by the routes
+	KeepWarm         bool                 // Whether any route requires the container to be kept running
+	DependsOn        []Dependency         // Dependencies shared by the routes, in start order
+	WarmWindows      []WarmWindow         // Warm windows of all the routes
+	Activity         ActivityConfig       // Lowest activity thresholds among the routes
+	Priority         int                  // Highest eviction priority among the routes
+	MemoryEstimateMB int                  // Largest memory estimate among the routes
+	ColdStartLimit   ColdStartLimitConfig // Strictest cold start limit among the routes
+	Routes           []RouteConfig        // Routes backed by the container
+}
+
+// NewRoutePolicy creates the policy of a container used by a single route.
+func NewRoutePolicy(route RouteConfig) ContainerPolicy {
+	return ContainerPolicy{
+		ContainerName:    route.Backend.ManagedName(),
+		Service:          route.Backend.Service != "",
+		Replicas:         route.Backend.Replicas,
+		Endpoint:         route.Backend.Endpoint,
+		TTL:              route.TTL,
+		IdleAction:       route.IdleAction,
+		KeepWarm:         route.KeepWarm,
+		DependsOn:        route.Backend.DependsOn,
+		WarmWindows:      append([]WarmWindow(nil), route.WarmWindows...),
+		Activity:         route.Activity,
+		Priority:         route.Priority,
+		MemoryEstimateMB: route.MemoryEstimateMB,
+		ColdStartLimit:   route.ColdStartLimit,
+		Routes:           []RouteConfig{route},
+	}
+}
+
+// Key identifies the container across endpoints.
+func (cp ContainerPolicy) Key() string {
+	return ContainerKey(cp.Endpoint, cp.ContainerName)
+}
+
+// DependencyKey identifies a dependency of the container, which runs on the same endpoint.
+func (cp ContainerPolicy) DependencyKey(dependency Dependency) string {
+	return ContainerKey(cp.Endpoint, dependency.ContainerName)
+}
+
+// InWarmWindow reports whether t is inside any warm window of the container.
+func (cp ContainerPolicy) InWarmWindow(t time.Time) bool {
+	for _, window := range cp.WarmWindows {
+		if window.IsActive(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextWarmWindow returns the next time, after t, at which a warm window of the container opens.
+func (cp ContainerPolicy) NextWarmWindow(t time.Time) (time.Time, bool) {
+	var next time.Time
+	for _, window := range cp.WarmWindows {
+		if start, ok := window.NextStart(t); ok && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// Probes returns one route per distinct liveness probe of the container, so each
+// health check is performed only once when the container starts.
+func (cp ContainerPolicy) Probes() []RouteConfig {
+	seen := make(map[string]bool)
+
+	var probes []RouteConfig
+	for _, route := range cp.Routes {
+		key := fmt.Sprintf("%s://%s/%s", route.Backend.Protocol, route.Backend.Address(), route.LivenessProbe.Path)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		probes = append(probes, route)
+	}
+	return probes
+}
+
+// buildContainerPolicies merges the routes of every host into one policy per container, keyed
+// by ContainerKey. Routes that disagree on settings which cannot be merged are rejected.
+func buildContainerPolicies(configs []HostConfig) (map[string]ContainerPolicy, error) {
+	policies := make(map[string]ContainerPolicy)
+
+	for _, hostConfig := range configs {
+		for _, route := range hostConfig.Routes {
+			if !route.Backend.Managed() {
+				continue
+			}
+			name := route.Backend.ContainerKey()
+
+			policy, exists := policies[name]
+			if !exists {
+				policies[name] = NewRoutePolicy(route)
+				continue
+			}
+
+			if policy.IdleAction != route.IdleAction {
+				return nil, fmt.Errorf("container %s: conflicting idleAction %q and %q (host %s, route %s)",
+					name, policy.IdleAction, route.IdleAction, hostConfig.Host, route.Path)
+			}
+
+			if dependencyNames(policy.DependsOn) != dependencyNames(route.Backend.DependsOn) {
+				return nil, fmt.Errorf("container %s: conflicting dependsOn [%s] and [%s] (host %s, route %s)",
+					name, dependencyNames(policy.DependsOn), dependencyNames(route.Backend.DependsOn), hostConfig.Host, route.Path)
+			}
+			if dependency := conflictingDependency(policy.DependsOn, route.Backend.DependsOn); dependency != "" {
+				return nil, fmt.Errorf("container %s: conflicting settings of dependency %s (host %s, route %s)",
+					name, dependency, hostConfig.Host, route.Path)
+			}
+
+			if policy.Service != (route.Backend.Service != "") {
+				return nil, fmt.Errorf("container %s: used both as a container and as a Swarm service (host %s, route %s)",
+					name, hostConfig.Host, route.Path)
+			}
+
+			if route.TTL > policy.TTL {
+				policy.TTL = route.TTL
+			}
+			if route.Backend.Replicas > policy.Replicas {
+				policy.Replicas = route.Backend.Replicas
+			}
+			if route.Priority > policy.Priority {
+				policy.Priority = route.Priority
+			}
+			if route.MemoryEstimateMB > policy.MemoryEstimateMB {
+				policy.MemoryEstimateMB = route.MemoryEstimateMB
+			}
+			if route.ColdStartLimit.Enabled() && (!policy.ColdStartLimit.Enabled() || route.ColdStartLimit.Rate() < policy.ColdStartLimit.Rate()) {
+				policy.ColdStartLimit = route.ColdStartLimit
+			}
+			policy.KeepWarm = policy.KeepWarm || route.KeepWarm
+			policy.Activity.CPUPercent = lowestThreshold(policy.Activity.CPUPercent, route.Activity.CPUPercent)
+			policy.Activity.NetworkBytesPerSecond = lowestThreshold(policy.Activity.NetworkBytesPerSecond, route.Activity.NetworkBytesPerSecond)
+			policy.WarmWindows = append(policy.WarmWindows, route.WarmWindows...)
+			policy.Routes = append(policy.Routes, route)
+
+			policies[name] = policy
+		}
+	}
+
+	if err := checkDependencyCycles(policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// checkDependencyCycles rejects cycles in the dependencies of all the containers together. Each
+// route's dependsOn is already free of cycles, but a backend may depend, through a dependency, on
+// a container whose own route depends on it.
+func checkDependencyCycles(policies map[string]ContainerPolicy) error {
+	edges := make(map[string][]string)
+	for _, policy := range policies {
+		for _, dependency := range policy.DependsOn {
+			key := policy.DependencyKey(dependency)
+			edges[policy.Key()] = append(edges[policy.Key()], key)
+			for _, required := range dependency.DependsOn {
+				edges[key] = append(edges[key], ContainerKey(policy.Endpoint, required))
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(edges))
+
+	var visit func(key string, path []string) error
+	visit = func(key string, path []string) error {
+		switch state[key] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, key), " -> "))
+		case visited:
+			return nil
+		}
+
+		state[key] = visiting
+		for _, next := range edges[key] {
+			if err := visit(next, append(path, key)); err != nil {
+				return err
+			}
+		}
+		state[key] = visited
+		return nil
+	}
+
+	keys := make([]string, 0, len(edges))
+	for key := range edges {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := visit(key, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lowestThreshold returns the lowest of two thresholds, ignoring unset ones.
+func lowestThreshold(a, b float64) float64 {
+	if a <= 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// conflictingDependency returns the name of the first dependency whose own dependencies or probe
+// differ between two lists of the same dependencies in start order, or an empty string when they
+// match.
+func conflictingDependency(dependencies, others []Dependency) string {
+	for i, dependency := range dependencies {
+		required, otherRequired := slices.Sorted(slices.Values(dependency.DependsOn)), slices.Sorted(slices.Values(others[i].DependsOn))
+		if dependency.Probe != others[i].Probe || !slices.Equal(required, otherRequired) {
+			return dependency.ContainerName
+		}
+	}
+	return ""
+}
+
+// dependencyNames returns the names of the dependencies, in start order, as a single string.
+func dependencyNames(dependencies []Dependency) string {
+	names := make([]string, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		names = append(names, dependency.ContainerName)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// containerRoute creates a route of a host to a container.
+func containerRoute(path, containerName string) RouteConfig {
+	return RouteConfig{
+		Path:          "/" + path,
+		IdleAction:    IdleActionStop,
+		Backend:       Backend{Protocol: "http", Host: "127.0.0.1", Port: 8080, ContainerName: containerName},
+		LivenessProbe: LivenessProbeConfig{Path: "health"},
+	}
+}
+
+func TestBuildContainerPoliciesRejectsConflicts(t *testing.T) {
+	// withDependencies gives a route the dependencies db and cache, cache starting after db.
+	withDependencies := func(route *RouteConfig) {
+		route.Backend.DependsOn = []Dependency{
+			{ContainerName: "db", Probe: ProbeConfig{Protocol: "tcp", Port: 5432, Attempts: 10, Period: 1}},
+			{ContainerName: "cache", DependsOn: []string{"db"}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		shared func(route *RouteConfig) // Applied to both routes
+		modify func(route *RouteConfig)
+		want   string
+	}{
+		{
+			name:   "idleAction",
+			modify: func(route *RouteConfig) { route.IdleAction = IdleActionPause },
+			want:   `conflicting idleAction "stop" and "pause"`,
+		},
+		{
+			name:   "dependsOn",
+			modify: func(route *RouteConfig) { route.Backend.DependsOn = []Dependency{{ContainerName: "db"}} },
+			want:   "conflicting dependsOn [] and [db]",
+		},
+		{
+			name:   "dependency probe",
+			shared: withDependencies,
+			modify: func(route *RouteConfig) { route.Backend.DependsOn[0].Probe.Attempts = 30 },
+			want:   "conflicting settings of dependency db",
+		},
+		{
+			name:   "dependency order",
+			shared: withDependencies,
+			modify: func(route *RouteConfig) { route.Backend.DependsOn[1].DependsOn = nil },
+			want:   "conflicting settings of dependency cache",
+		},
+		{
+			name: "service",
+			modify: func(route *RouteConfig) {
+				route.Backend.ContainerName = ""
+				route.Backend.Service = "app"
+			},
+			want: "used both as a container and as a Swarm service",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			first, second := containerRoute("first", "app"), containerRoute("second", "app")
+			if test.shared != nil {
+				test.shared(&first)
+				test.shared(&second)
+			}
+			test.modify(&second)
+
+			hosts := []HostConfig{
+				{Host: "a.example.com", Routes: []RouteConfig{first}},
+				{Host: "b.example.com", Routes: []RouteConfig{second}},
+			}
+
+			_, err := buildContainerPolicies(hosts)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("buildContainerPolicies error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestBuildContainerPoliciesMergesRoutes(t *testing.T) {
+	short := containerRoute("short", "app")
+	short.TTL = 30
+	short.Activity = ActivityConfig{CPUPercent: 20}
+	short.Priority = 1
+
+	long := containerRoute("long", "app")
+	long.TTL = 600
+	long.KeepWarm = true
+	long.Activity = ActivityConfig{CPUPercent: 5, NetworkBytesPerSecond: 1000}
+	long.MemoryEstimateMB = 256
+	long.LivenessProbe.Path = "ready"
+
+	same := containerRoute("same", "app")
+	same.TTL = 60
+
+	hosts := []HostConfig{
+		{Host: "a.example.com", Routes: []RouteConfig{short, long}},
+		{Host: "b.example.com", Routes: []RouteConfig{same, containerRoute("other", "worker")}},
+	}
+
+	policies, err := buildContainerPolicies(hosts)
+	if err != nil {
+		t.Fatalf("buildContainerPolicies returned error: %v", err)
+	}
+	if len(policies) != 2 {
+		t.Fatalf("got %d policies, want one per container", len(policies))
+	}
+
+	policy := policies["app"]
+	if policy.TTL != 600 {
+		t.Errorf("TTL = %d, want the largest one, 600", policy.TTL)
+	}
+	if !policy.KeepWarm {
+		t.Errorf("KeepWarm = false, want true when any route keeps the container warm")
+	}
+	if policy.Activity.CPUPercent != 5 || policy.Activity.NetworkBytesPerSecond != 1000 {
+		t.Errorf("Activity = %+v, want the lowest threshold of each kind", policy.Activity)
+	}
+	if policy.Priority != 1 || policy.MemoryEstimateMB != 256 {
+		t.Errorf("Priority, MemoryEstimateMB = %d, %d; want the largest ones, 1 and 256", policy.Priority, policy.MemoryEstimateMB)
+	}
+	if len(policy.Routes) != 3 {
+		t.Errorf("policy has %d routes, want 3", len(policy.Routes))
+	}
+	if probes := policy.Probes(); len(probes) != 2 {
+		t.Errorf("Probes returned %d routes, want one per distinct probe, 2", len(probes))
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Keys identifying the client of a rate limit.
+const (
+	RateLimitByIP     = "ip"     // Client IP address
+	RateLimitByHeader = "header" // Value of a request header
+	RateLimitByAPIKey = "apiKey" // API key of the request accepted by the authentication of the route
+)
+
+// RateLimitConfig represents a token bucket rate limit applied to each client of a host or route.
+type RateLimitConfig struct {
+	Requests     int    `yaml:"requests"`     // Requests allowed per period; no limit when 0
+	Period       int    `yaml:"period"`       // Period, in seconds, over which the requests are allowed (default 1)
+	Burst        int    `yaml:"burst"`        // Requests allowed at once (default requests)
+	Key          string `yaml:"key"`          // What identifies the client: ip (default), header or apiKey
+	Header       string `yaml:"header"`       // Header identifying the client, with key header; trusted only from the trusted proxies
+	ForwardedFor bool   `yaml:"forwardedFor"` // Client IP taken from X-Forwarded-For, as appended by the trusted proxies
+
+	TrustedProxies []string `yaml:"trustedProxies"` // Addresses or CIDR ranges of the proxies allowed to set X-Forwarded-For and the header
+}
+
+// Enabled reports whether the rate limit is configured.
+func (rl RateLimitConfig) Enabled() bool {
+	return rl.Requests > 0
+}
+
+// Rate returns the requests allowed per second.
+func (rl RateLimitConfig) Rate() float64 {
+	return float64(rl.Requests) / float64(rl.Period)
+}
+
+// TrustsProxy reports whether addr belongs to one of the trusted proxies.
+func (rl RateLimitConfig) TrustsProxy(addr netip.Addr) bool {
+	for _, proxy := range rl.TrustedProxies {
+		prefix, err := parseProxy(proxy)
+		if err == nil && prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProxy parses a trusted proxy given as an address or a CIDR range.
+func parseProxy(proxy string) (netip.Prefix, error) {
+	if strings.Contains(proxy, "/") {
+		prefix, err := netip.ParsePrefix(proxy)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(proxy)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+// ColdStartLimitConfig represents a cap on the cold starts of a container in a time window.
+type ColdStartLimitConfig struct {
+	Starts int `yaml:"starts"` // Cold starts allowed per period; no limit when 0
+	Period int `yaml:"period"` // Period, in seconds, over which the starts are allowed (default 60)
+}
+
+// Enabled reports whether the cold start limit is configured.
+func (cl ColdStartLimitConfig) Enabled() bool {
+	return cl.Starts > 0
+}
+
+// Rate returns the cold starts allowed per second.
+func (cl ColdStartLimitConfig) Rate() float64 {
+	return float64(cl.Starts) / float64(cl.Period)
+}
+
+// normalizeRateLimit applies the defaults of a rate limit and validates it.
+func normalizeRateLimit(rateLimit *RateLimitConfig) error {
+	if !rateLimit.Enabled() {
+		return nil
+	}
+	if rateLimit.Period <= 0 {
+		rateLimit.Period = 1
+	}
+	if rateLimit.Burst <= 0 {
+		rateLimit.Burst = rateLimit.Requests
+	}
+
+	switch rateLimit.Key {
+	case "":
+		rateLimit.Key = RateLimitByIP
+	case RateLimitByIP, RateLimitByAPIKey:
+	case RateLimitByHeader:
+		if rateLimit.Header == "" {
+			return fmt.Errorf("rateLimit.header is required with key header")
+		}
+		if len(rateLimit.TrustedProxies) == 0 {
+			return fmt.Errorf("rateLimit.key header requires trustedProxies")
+		}
+	default:
+		return fmt.Errorf("invalid rateLimit.key %q", rateLimit.Key)
+	}
+
+	if rateLimit.ForwardedFor && len(rateLimit.TrustedProxies) == 0 {
+		return fmt.Errorf("rateLimit.forwardedFor requires trustedProxies")
+	}
+	for _, proxy := range rateLimit.TrustedProxies {
+		if _, err := parseProxy(proxy); err != nil {
+			return fmt.Errorf("invalid rateLimit.trustedProxies entry %q", proxy)
+		}
+	}
+	return nil
+}
+
+// normalizeColdStartLimit applies the defaults of a cold start limit.
+func normalizeColdStartLimit(coldStartLimit *ColdStartLimitConfig) {
+	if coldStartLimit.Enabled() && coldStartLimit.Period <= 0 {
+		coldStartLimit.Period = 60
+	}
+}
@@ -15,29 +15,141 @@
  */
 package config
 
+import (
+	"net"
+	"strconv"
+)
+
 // HostConfig represents the configuration of a specific host.
 type HostConfig struct {
-	Host   string        `yaml:"host"`   // Host for which routes will be configured
-	CORS   CORSConfig    `yaml:"cors"`   // CORS configuration specific to this host
-	Routes []RouteConfig `yaml:"routes"` // List of routes for the host
+	Host       string           `yaml:"host"`       // Host for which routes will be configured
+	CORS       CORSConfig       `yaml:"cors"`       // CORS configuration specific to this host
+	WakePage   WakePageConfig   `yaml:"wakePage"`   // Answer to requests while a sleeping container starts
+	ErrorPages ErrorPagesConfig `yaml:"errorPages"` // Templates and format of the errors answered by the gateway
+	RateLimit  RateLimitConfig  `yaml:"rateLimit"`  // Rate limit of each client across the routes of the host
+	Auth       AuthConfig       `yaml:"auth"`       // Authentication required by the routes of the host
+	Routes     []RouteConfig    `yaml:"routes"`     // List of routes for the host
 }
 
 // RouteConfig represents the configuration of a specific route.
 type RouteConfig struct {
-	Path          string              `yaml:"path"`          // Route path
-	StripPath     bool                `yaml:"stripPath"`     // Indicates if the path should be removed
-	TTL           int                 `yaml:"ttl"`           // Grace period for termination
-	Backend       Backend             `yaml:"backend"`       // Backend configuration
-	Retry         RetryConfig         `yaml:"retry"`         // Retry configuration
-	LivenessProbe LivenessProbeConfig `yaml:"livenessProbe"` // Health check configuration
+	Host             string               `yaml:"-"`                // Host the route belongs to, set when loading
+	Path             string               `yaml:"path"`             // Route path
+	StripPath        bool                 `yaml:"stripPath"`        // Indicates if the path should be removed
+	TTL              int                  `yaml:"ttl"`              // Grace period for termination
+	IdleAction       string               `yaml:"idleAction"`       // Action applied once the TTL expires (stop, pause, remove or checkpoint)
+	KeepWarm         bool                 `yaml:"keepWarm"`         // Keeps the container running; it is never scaled to zero
+	WarmWindows      []WarmWindow         `yaml:"warmWindows"`      // Scheduled periods in which the container is kept running
+	Activity         ActivityConfig       `yaml:"activity"`         // Resource usage that keeps the container alive without requests
+	Priority         int                  `yaml:"priority"`         // Eviction priority; lower priorities are evicted first under memory pressure
+	MemoryEstimateMB int                  `yaml:"memoryEstimateMB"` // Expected memory usage of the container, in megabytes
+	Backend          Backend              `yaml:"backend"`          // Backend configuration
+	Retry            RetryConfig          `yaml:"retry"`            // Retry configuration
+	RateLimit        RateLimitConfig      `yaml:"rateLimit"`        // Rate limit of each client on the route
+	ColdStartLimit   ColdStartLimitConfig `yaml:"coldStartLimit"`   // Cap on the cold starts of the backend container
+	Auth             AuthConfig           `yaml:"auth"`             // Authentication required by the route, instead of the host's
+	ForwardAuth      ForwardAuthConfig    `yaml:"forwardAuth"`      // External service authorizing the requests of the route
+	LivenessProbe    LivenessProbeConfig  `yaml:"livenessProbe"`    // Health check configuration
+}
+
+// Idle actions applied to a route's container once its TTL expires.
+const (
+	IdleActionStop       = "stop"       // Stop the container; the next request starts it again
+	IdleActionPause      = "pause"      // Freeze the container's cgroup; the next request unpauses it
+	IdleActionRemove     = "remove"     // Remove the container; the next request recreates it
+	IdleActionCheckpoint = "checkpoint" // Checkpoint the container with CRIU; the next request restores it
+)
+
+// ActivityConfig represents the resource usage thresholds above which a container is not idle.
+type ActivityConfig struct {
+	CPUPercent            float64 `yaml:"cpuPercent"`            // CPU usage, in percent of one core
+	NetworkBytesPerSecond float64 `yaml:"networkBytesPerSecond"` // Network I/O, received plus transmitted
+}
+
+// Enabled reports whether any activity threshold is configured.
+func (ac ActivityConfig) Enabled() bool {
+	return ac.CPUPercent > 0 || ac.NetworkBytesPerSecond > 0
 }
 
 // Backend represents the backend configuration of a route.
 type Backend struct {
-	Protocol      string `yaml:"protocol"`      // Protocol (http or https)
-	Host          string `yaml:"host"`          // Backend host
-	Port          int    `yaml:"port"`          // Backend port
-	ContainerName string `yaml:"containerName"` // Corresponding container name
+	Protocol       string       `yaml:"protocol"`       // Protocol (http or https)
+	Host           string       `yaml:"host"`           // Backend host; resolved from the endpoint when empty
+	Port           int          `yaml:"port"`           // Backend port; the lowest port exposed by the container when empty and a network is set
+	Network        string       `yaml:"network"`        // Docker network whose container IP is used instead of host
+	Endpoint       string       `yaml:"endpoint"`       // Named Docker endpoint running the containers; the default runtime when empty
+	ContainerName  string       `yaml:"containerName"`  // Corresponding container name
+	ComposeProject string       `yaml:"composeProject"` // Compose project of the backend containers
+	ComposeService string       `yaml:"composeService"` // Compose service whose containers are handled as a group
+	Service        string       `yaml:"service"`        // Swarm service scaled instead of a container
+	Replicas       int          `yaml:"replicas"`       // Tasks of the Swarm service while awake (default 1)
+	DependsOn      []Dependency `yaml:"dependsOn"`      // Containers started before the backend, in dependency order
+}
+
+// ComposeGroupPrefix starts the name of the container groups formed by compose services.
+const ComposeGroupPrefix = "compose:"
+
+// ComposeGroupName returns the name under which the containers of a compose service are handled
+// as a group. Container names cannot contain a colon, so it never matches a real container.
+func ComposeGroupName(project, service string) string {
+	return ComposeGroupPrefix + project + "/" + service
+}
+
+// Managed reports whether the gateway manages a container, a compose service or a Swarm service
+// for the backend.
+func (b Backend) Managed() bool {
+	return b.ManagedName() != ""
+}
+
+// ManagedName returns the name of the container, compose group or Swarm service of the backend.
+func (b Backend) ManagedName() string {
+	switch {
+	case b.Service != "":
+		return b.Service
+	case b.ComposeService != "":
+		return ComposeGroupName(b.ComposeProject, b.ComposeService)
+	default:
+		return b.ContainerName
+	}
+}
+
+// Address returns the host and port the backend is reached at. When no host is set, Swarm
+// services are reached at their virtual IP, through the service name, and containers at the
+// address of the backend endpoint.
+func (b Backend) Address() string {
+	host := b.Host
+	if host == "" && b.Service != "" {
+		host = b.Service
+	}
+	if host == "" && b.Endpoint != "" {
+		if endpoint, exists := GetGatewayConfig().GetEndpoint(b.Endpoint); exists {
+			host = endpoint.BackendAddress()
+		}
+	}
+	return net.JoinHostPort(host, strconv.Itoa(b.Port))
+}
+
+// ContainerKey identifies the backend container or Swarm service across endpoints.
+func (b Backend) ContainerKey() string {
+	return ContainerKey(b.Endpoint, b.ManagedName())
+}
+
+// Dependency represents a container that must be running and healthy before the backend starts.
+type Dependency struct {
+	ContainerName string      `yaml:"containerName"` // Dependency container name
+	DependsOn     []string    `yaml:"dependsOn"`     // Other dependencies of the route that must start first
+	Probe         ProbeConfig `yaml:"probe"`         // Health check of the dependency
+}
+
+// ProbeConfig represents a TCP or HTTP health check of a dependency.
+type ProbeConfig struct {
+	Protocol            string `yaml:"protocol"`            // Protocol (tcp, http or https)
+	Host                string `yaml:"host"`                // Host to probe
+	Port                int    `yaml:"port"`                // Port to probe; the probe is skipped when empty
+	Path                string `yaml:"path"`                // Health check path for http and https
+	InitialDelaySeconds int    `yaml:"initialDelaySeconds"` // Initial delay before the health check
+	Attempts            int    `yaml:"attempts"`            // Number of attempts
+	Period              int    `yaml:"period"`              // Interval between attempts in seconds
 }
 
 // RetryConfig represents the retry configuration for a route.
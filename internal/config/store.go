@@ -23,13 +23,18 @@ import (
 
 // HostStore is the main storage for hosts and routes.
 type HostStore struct {
-	store map[string]HostData
+	store    map[string]HostData
+	policies map[string]ContainerPolicy
 }
 
 // HostData stores the routes and CORS configuration for each host.
 type HostData struct {
-	CORS   CORSConfig             // CORS configuration specific to the host
-	Routes map[string]RouteConfig // Mapping of routes by path
+	CORS       CORSConfig             // CORS configuration specific to the host
+	WakePage   WakePageConfig         // Answer to requests while a sleeping container starts
+	ErrorPages ErrorPagesConfig       // Templates and format of the errors answered by the gateway
+	RateLimit  RateLimitConfig        // Rate limit of each client across the routes of the host
+	Auth       AuthConfig             // Authentication required by the routes of the host
+	Routes     map[string]RouteConfig // Mapping of routes by path
 }
 
 var (
@@ -41,7 +46,8 @@ var (
 func GetHostStore() *HostStore {
 	once.Do(func() {
 		instance = &HostStore{
-			store: make(map[string]HostData),
+			store:    make(map[string]HostData),
+			policies: make(map[string]ContainerPolicy),
 		}
 	})
 	return instance
@@ -57,11 +63,40 @@ func (hs *HostStore) AddHost(hostConfig HostConfig) {
 
 	// Add the host with its routes and CORS configuration.
 	hs.store[hostConfig.Host] = HostData{
-		CORS:   hostConfig.CORS,
-		Routes: routeMap,
+		CORS:       hostConfig.CORS,
+		WakePage:   hostConfig.WakePage,
+		ErrorPages: hostConfig.ErrorPages,
+		RateLimit:  hostConfig.RateLimit,
+		Auth:       hostConfig.Auth,
+		Routes:     routeMap,
 	}
 }
 
+// RemoveHost removes a host and its routes from the HostStore.
+func (hs *HostStore) RemoveHost(host string) {
+	delete(hs.store, host)
+}
+
+// SetContainerPolicies replaces the lifecycle policies of the containers.
+func (hs *HostStore) SetContainerPolicies(policies map[string]ContainerPolicy) {
+	hs.policies = policies
+}
+
+// GetContainerPolicy retrieves the lifecycle policy of a container by its ContainerKey.
+func (hs *HostStore) GetContainerPolicy(containerKey string) (ContainerPolicy, bool) {
+	policy, ok := hs.policies[containerKey]
+	return policy, ok
+}
+
+// ListContainerPolicies returns the lifecycle policies of every container used by a route.
+func (hs *HostStore) ListContainerPolicies() []ContainerPolicy {
+	policies := make([]ContainerPolicy, 0, len(hs.policies))
+	for _, policy := range hs.policies {
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
 // GetRoute retrieves a specific route of a host by its path.
 func (hs *HostStore) GetRoute(host, path string) (RouteConfig, bool) {
 	hostData, ok := hs.store[host]
@@ -100,6 +135,42 @@ func (hs *HostStore) GetCORS(host string) (CORSConfig, bool) {
 	return hostData.CORS, true
 }
 
+// GetWakePage retrieves the holding page configuration of a host.
+func (hs *HostStore) GetWakePage(host string) (WakePageConfig, bool) {
+	hostData, ok := hs.store[host]
+	if !ok {
+		return WakePageConfig{}, false
+	}
+	return hostData.WakePage, true
+}
+
+// GetErrorPages retrieves the error pages configuration of a host.
+func (hs *HostStore) GetErrorPages(host string) (ErrorPagesConfig, bool) {
+	hostData, ok := hs.store[host]
+	if !ok {
+		return ErrorPagesConfig{}, false
+	}
+	return hostData.ErrorPages, true
+}
+
+// GetRateLimit retrieves the rate limit applied across the routes of a host.
+func (hs *HostStore) GetRateLimit(host string) (RateLimitConfig, bool) {
+	hostData, ok := hs.store[host]
+	if !ok {
+		return RateLimitConfig{}, false
+	}
+	return hostData.RateLimit, true
+}
+
+// GetAuth retrieves the authentication required by the routes of a host.
+func (hs *HostStore) GetAuth(host string) (AuthConfig, bool) {
+	hostData, ok := hs.store[host]
+	if !ok {
+		return AuthConfig{}, false
+	}
+	return hostData.Auth, true
+}
+
 // ListHosts returns all stored hosts.
 func (hs *HostStore) ListHosts() []string {
 	hosts := make([]string, 0, len(hs.store))
@@ -0,0 +1,215 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// normalizeConfigs applies default values to the loaded host configurations and
+// rejects settings the gateway cannot handle.
+func normalizeConfigs(configs []HostConfig) error {
+	for i := range configs {
+		if err := normalizeWakePage(&configs[i].WakePage); err != nil {
+			return fmt.Errorf("host %s: %s", configs[i].Host, err.Error())
+		}
+		if err := normalizeErrorPages(&configs[i].ErrorPages); err != nil {
+			return fmt.Errorf("host %s: %s", configs[i].Host, err.Error())
+		}
+		if err := normalizeRateLimit(&configs[i].RateLimit); err != nil {
+			return fmt.Errorf("host %s: %s", configs[i].Host, err.Error())
+		}
+		if err := normalizeAuth(&configs[i].Auth); err != nil {
+			return fmt.Errorf("host %s: %s", configs[i].Host, err.Error())
+		}
+
+		for j := range configs[i].Routes {
+			configs[i].Routes[j].Host = configs[i].Host
+			if err := normalizeRoute(&configs[i].Routes[j]); err != nil {
+				return fmt.Errorf("host %s, route %s: %s", configs[i].Host, configs[i].Routes[j].Path, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// normalizeRoute applies default values to a single route and validates it.
+func normalizeRoute(route *RouteConfig) error {
+	switch route.IdleAction {
+	case "":
+		route.IdleAction = IdleActionStop
+	case IdleActionStop, IdleActionPause, IdleActionRemove, IdleActionCheckpoint:
+	default:
+		return fmt.Errorf("invalid idleAction %q", route.IdleAction)
+	}
+
+	if err := normalizeRateLimit(&route.RateLimit); err != nil {
+		return err
+	}
+	normalizeColdStartLimit(&route.ColdStartLimit)
+	if err := normalizeAuth(&route.Auth); err != nil {
+		return err
+	}
+	if err := normalizeForwardAuth(&route.ForwardAuth); err != nil {
+		return err
+	}
+
+	for i := range route.WarmWindows {
+		if err := normalizeWarmWindow(&route.WarmWindows[i]); err != nil {
+			return err
+		}
+	}
+
+	if route.Backend.ComposeProject != "" || route.Backend.ComposeService != "" {
+		if route.Backend.ComposeProject == "" || route.Backend.ComposeService == "" {
+			return fmt.Errorf("composeProject and composeService must be set together")
+		}
+		if route.Backend.ContainerName != "" || route.Backend.Service != "" {
+			return fmt.Errorf("backend sets compose and containerName or service")
+		}
+	}
+
+	if route.Backend.Network != "" && (!route.Backend.Managed() || route.Backend.Service != "") {
+		return fmt.Errorf("network requires a containerName or compose service backend")
+	}
+	if route.Backend.Network == "" && route.Backend.Port == 0 && route.Backend.Protocol != "" {
+		return fmt.Errorf("backend port is required without a network")
+	}
+
+	if route.Backend.Service != "" {
+		if err := normalizeService(route); err != nil {
+			return err
+		}
+	}
+
+	if route.Backend.Endpoint != "" {
+		endpoint, exists := GetGatewayConfig().GetEndpoint(route.Backend.Endpoint)
+		if !exists {
+			return fmt.Errorf("unknown endpoint %q", route.Backend.Endpoint)
+		}
+		for i := range route.Backend.DependsOn {
+			if route.Backend.DependsOn[i].Probe.Host == "" {
+				route.Backend.DependsOn[i].Probe.Host = endpoint.BackendAddress()
+			}
+		}
+	}
+
+	for i := range route.Backend.DependsOn {
+		normalizeProbe(&route.Backend.DependsOn[i].Probe)
+	}
+
+	dependencies, err := sortDependencies(route.Backend.ManagedName(), route.Backend.DependsOn)
+	if err != nil {
+		return err
+	}
+	route.Backend.DependsOn = dependencies
+
+	return nil
+}
+
+// normalizeService applies default values to a Swarm service backend. Services are only scaled
+// down to zero replicas, so the other idle actions are rejected.
+func normalizeService(route *RouteConfig) error {
+	if route.Backend.ContainerName != "" {
+		return fmt.Errorf("backend sets both containerName and service")
+	}
+	if route.IdleAction != IdleActionStop {
+		return fmt.Errorf("idleAction %q is not supported by Swarm services", route.IdleAction)
+	}
+	if route.Backend.Replicas < 0 {
+		return fmt.Errorf("invalid replicas %d", route.Backend.Replicas)
+	}
+	if route.Backend.Replicas == 0 {
+		route.Backend.Replicas = 1
+	}
+	return nil
+}
+
+// normalizeProbe applies default values to a dependency probe.
+func normalizeProbe(probe *ProbeConfig) {
+	if probe.Protocol == "" {
+		probe.Protocol = "tcp"
+	}
+	if probe.Attempts <= 0 {
+		probe.Attempts = 10
+	}
+	if probe.Period <= 0 {
+		probe.Period = 1
+	}
+}
+
+// sortDependencies orders the dependencies of a backend so that every container comes after
+// the ones it depends on. Unknown references and cycles are rejected.
+func sortDependencies(backend string, dependencies []Dependency) ([]Dependency, error) {
+	byName := make(map[string]Dependency, len(dependencies))
+	for _, dependency := range dependencies {
+		if dependency.ContainerName == "" {
+			return nil, fmt.Errorf("dependency without containerName")
+		}
+		if dependency.ContainerName == backend {
+			return nil, fmt.Errorf("container %s depends on itself", backend)
+		}
+		if _, exists := byName[dependency.ContainerName]; exists {
+			return nil, fmt.Errorf("dependency %s declared more than once", dependency.ContainerName)
+		}
+		byName[dependency.ContainerName] = dependency
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(dependencies))
+	sorted := make([]Dependency, 0, len(dependencies))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		dependency, exists := byName[name]
+		if !exists {
+			return fmt.Errorf("dependency %s is not declared in dependsOn", name)
+		}
+
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		for _, next := range dependency.DependsOn {
+			if err := visit(next, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+
+		sorted = append(sorted, dependency)
+		return nil
+	}
+
+	for _, dependency := range dependencies {
+		if err := visit(dependency.ContainerName, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
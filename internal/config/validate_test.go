@@ -0,0 +1,160 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dependency creates a dependency on a container started after the given ones.
+func dependency(name string, dependsOn ...string) Dependency {
+	return Dependency{ContainerName: name, DependsOn: dependsOn}
+}
+
+func TestSortDependencies(t *testing.T) {
+	tests := []struct {
+		name         string
+		dependencies []Dependency
+		want         string // Start order, or the expected error
+	}{
+		{
+			name:         "independent",
+			dependencies: []Dependency{dependency("db"), dependency("cache")},
+			want:         "db, cache",
+		},
+		{
+			name:         "declared before their dependencies",
+			dependencies: []Dependency{dependency("worker", "queue", "db"), dependency("queue", "db"), dependency("db")},
+			want:         "db, queue, worker",
+		},
+		{
+			name:         "cycle",
+			dependencies: []Dependency{dependency("a", "b"), dependency("b", "c"), dependency("c", "a")},
+			want:         "dependency cycle: a -> b -> c -> a",
+		},
+		{
+			name:         "unknown",
+			dependencies: []Dependency{dependency("db", "volume")},
+			want:         "dependency volume is not declared in dependsOn",
+		},
+		{
+			name:         "self",
+			dependencies: []Dependency{dependency("api")},
+			want:         "container api depends on itself",
+		},
+		{
+			name:         "duplicate",
+			dependencies: []Dependency{dependency("db"), dependency("db")},
+			want:         "dependency db declared more than once",
+		},
+		{
+			name:         "unnamed",
+			dependencies: []Dependency{dependency("")},
+			want:         "dependency without containerName",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sorted, err := sortDependencies("api", test.dependencies)
+
+			got := dependencyNames(sorted)
+			if err != nil {
+				got = err.Error()
+			}
+			if got != test.want {
+				t.Errorf("sortDependencies = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBuildContainerPoliciesRejectsCycleAcrossRoutes(t *testing.T) {
+	hosts := []HostConfig{{
+		Host: "example.com",
+		Routes: []RouteConfig{
+			{Path: "/api", Backend: Backend{ContainerName: "api", DependsOn: []Dependency{dependency("db")}}},
+			{Path: "/db", Backend: Backend{ContainerName: "db", DependsOn: []Dependency{dependency("api")}}},
+		},
+	}}
+
+	_, err := buildContainerPolicies(hosts)
+	if err == nil || !strings.Contains(err.Error(), "dependency cycle: api -> db -> api") {
+		t.Errorf("buildContainerPolicies error = %v, want a cycle through api and db", err)
+	}
+}
+
+func TestNormalizeRateLimitTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name      string
+		rateLimit RateLimitConfig
+		wantErr   bool
+	}{
+		{name: "remote address", rateLimit: RateLimitConfig{Requests: 1}},
+		{name: "forwardedFor without proxies", rateLimit: RateLimitConfig{Requests: 1, ForwardedFor: true}, wantErr: true},
+		{name: "forwardedFor with proxies",
+			rateLimit: RateLimitConfig{Requests: 1, ForwardedFor: true, TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1", "::1"}}},
+		{name: "invalid proxy",
+			rateLimit: RateLimitConfig{Requests: 1, ForwardedFor: true, TrustedProxies: []string{"proxy.local"}}, wantErr: true},
+		{name: "header without proxies",
+			rateLimit: RateLimitConfig{Requests: 1, Key: RateLimitByHeader, Header: "X-User"}, wantErr: true},
+		{name: "header with proxies",
+			rateLimit: RateLimitConfig{Requests: 1, Key: RateLimitByHeader, Header: "X-User", TrustedProxies: []string{"10.0.0.0/8"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := normalizeRateLimit(&test.rateLimit); (err != nil) != test.wantErr {
+				t.Errorf("normalizeRateLimit() error = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeAuthChecksFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		auth    AuthConfig
+		wantErr bool
+	}{
+		{name: "API keys", auth: AuthConfig{APIKeys: APIKeysConfig{File: write("keys", "# keys\nkey-1\n")}}},
+		{name: "empty API keys", auth: AuthConfig{APIKeys: APIKeysConfig{File: write("empty-keys", "# none\n\n")}}, wantErr: true},
+		{name: "missing API keys", auth: AuthConfig{APIKeys: APIKeysConfig{File: filepath.Join(dir, "missing")}}, wantErr: true},
+		{name: "htpasswd", auth: AuthConfig{Basic: BasicAuthConfig{Htpasswd: write("htpasswd", "alice:{SHA}abc\n")}}},
+		{name: "malformed htpasswd", auth: AuthConfig{Basic: BasicAuthConfig{Htpasswd: write("bad-htpasswd", "alice\n")}}, wantErr: true},
+		{name: "API keys directory", auth: AuthConfig{APIKeys: APIKeysConfig{File: dir}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := normalizeAuth(&test.auth); (err != nil) != test.wantErr {
+				t.Errorf("normalizeAuth() error = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
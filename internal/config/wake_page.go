@@ -0,0 +1,47 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// WakePageConfig represents how requests to a sleeping container of a host are answered while it
+// starts, instead of waiting for the start to complete.
+type WakePageConfig struct {
+	Enabled        bool   `yaml:"enabled"`        // Browsers get a holding page while the container starts in the background
+	Template       string `yaml:"template"`       // html/template file of the holding page; the built-in page when empty
+	RefreshSeconds int    `yaml:"refreshSeconds"` // Seconds after which the holding page reloads itself
+	RetryAfter     int    `yaml:"retryAfter"`     // Seconds sent in Retry-After of the 503 answered to API clients; 0 keeps them waiting
+}
+
+// normalizeWakePage applies the defaults of the holding page and checks its template exists.
+func normalizeWakePage(wakePage *WakePageConfig) error {
+	if wakePage.RefreshSeconds <= 0 {
+		wakePage.RefreshSeconds = 3
+	}
+	if wakePage.RetryAfter < 0 {
+		return fmt.Errorf("wakePage.retryAfter must not be negative")
+	}
+
+	if wakePage.Template != "" {
+		if _, err := os.Stat(wakePage.Template); err != nil {
+			return fmt.Errorf("wakePage.template: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/schedule"
+)
+
+// maxWindowLookup bounds how long a warm window lasts and how far ahead NextStart reports one.
+const maxWindowLookup = 8 * 24 * time.Hour
+
+// WarmWindow represents a period in which the container is kept running whatever its TTL says.
+type WarmWindow struct {
+	Schedule        string `yaml:"schedule"`        // Cron expression of the window start (minute hour day month weekday)
+	DurationSeconds int    `yaml:"durationSeconds"` // How long the window stays open
+	PrewarmSeconds  int    `yaml:"prewarmSeconds"`  // How long before the window opens the container is started
+	Timezone        string `yaml:"timezone"`        // IANA time zone of the schedule; local time when empty
+
+	cron     *schedule.Schedule
+	location *time.Location
+}
+
+// normalizeWarmWindow parses the schedule and time zone of a warm window.
+func normalizeWarmWindow(window *WarmWindow) error {
+	cron, err := schedule.Parse(window.Schedule)
+	if err != nil {
+		return err
+	}
+
+	location := time.Local
+	if window.Timezone != "" {
+		if location, err = time.LoadLocation(window.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q: %s", window.Timezone, err.Error())
+		}
+	}
+
+	if window.DurationSeconds <= 0 {
+		return fmt.Errorf("warm window %q must have a positive durationSeconds", window.Schedule)
+	}
+	if time.Duration(window.DurationSeconds+window.PrewarmSeconds)*time.Second > maxWindowLookup {
+		return fmt.Errorf("warm window %q is longer than %s", window.Schedule, maxWindowLookup)
+	}
+
+	window.cron = cron
+	window.location = location
+	return nil
+}
+
+// IsActive reports whether t is inside the window, including its prewarm period.
+func (w WarmWindow) IsActive(t time.Time) bool {
+	if w.cron == nil {
+		return false
+	}
+
+	duration := time.Duration(w.DurationSeconds) * time.Second
+	prewarm := time.Duration(w.PrewarmSeconds) * time.Second
+
+	// The window is active when it started in (t - duration, t + prewarm].
+	start, ok := w.cron.Previous(t.Add(prewarm).In(w.location))
+	return ok && start.After(t.Add(-duration))
+}
+
+// NextStart returns the next time, after t, at which the window opens.
+func (w WarmWindow) NextStart(t time.Time) (time.Time, bool) {
+	if w.cron == nil {
+		return time.Time{}, false
+	}
+
+	start, ok := w.cron.Next(t.In(w.location))
+	if !ok || start.Sub(t) > maxWindowLookup {
+		return time.Time{}, false
+	}
+	return start.In(t.Location()), true
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// newWarmWindow creates a normalized warm window.
+func newWarmWindow(t *testing.T, window WarmWindow) WarmWindow {
+	t.Helper()
+
+	if err := normalizeWarmWindow(&window); err != nil {
+		t.Fatalf("normalizeWarmWindow returned error: %v", err)
+	}
+	return window
+}
+
+// parseTime parses an RFC 3339 time.
+func parseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestWarmWindowIsActive(t *testing.T) {
+	// Business hours in São Paulo (UTC-3): open from 09:00 to 10:00, started 10 minutes early.
+	window := newWarmWindow(t, WarmWindow{
+		Schedule:        "0 9 * * 1-5",
+		DurationSeconds: 3600,
+		PrewarmSeconds:  600,
+		Timezone:        "America/Sao_Paulo",
+	})
+
+	tests := []struct {
+		time string
+		want bool
+	}{
+		{"2026-10-19T11:49:00Z", false}, // Before the prewarm period
+		{"2026-10-19T11:50:00Z", true},  // Prewarm starts
+		{"2026-10-19T12:00:00Z", true},  // Window opens
+		{"2026-10-19T12:59:59Z", true},
+		{"2026-10-19T13:00:00Z", false}, // Window closed
+		{"2026-10-19T09:00:00Z", false}, // 09:00 UTC is 06:00 in São Paulo
+		{"2026-10-24T12:30:00Z", false}, // Saturday
+	}
+
+	for _, test := range tests {
+		if got := window.IsActive(parseTime(t, test.time)); got != test.want {
+			t.Errorf("IsActive(%s) = %t, want %t", test.time, got, test.want)
+		}
+	}
+}
+
+func TestWarmWindowNextStart(t *testing.T) {
+	window := newWarmWindow(t, WarmWindow{
+		Schedule:        "30 22 * * 5",
+		DurationSeconds: 600,
+		Timezone:        "Asia/Tokyo",
+	})
+
+	tests := []struct {
+		time string
+		want string
+	}{
+		{"2026-10-19T00:00:00Z", "2026-10-23T13:30:00Z"}, // Friday 22:30 in Tokyo (UTC+9)
+		{"2026-10-23T13:30:00Z", "2026-10-30T13:30:00Z"}, // Strictly after t
+		{"2026-10-23T13:29:30Z", "2026-10-23T13:30:00Z"},
+	}
+
+	for _, test := range tests {
+		next, ok := window.NextStart(parseTime(t, test.time))
+		if !ok || !next.Equal(parseTime(t, test.want)) {
+			t.Errorf("NextStart(%s) = %s, %t; want %s", test.time, next.UTC().Format(time.RFC3339), ok, test.want)
+		}
+	}
+}
+
+func TestNormalizeWarmWindowRejectsInvalidWindows(t *testing.T) {
+	tests := []struct {
+		name   string
+		window WarmWindow
+	}{
+		{"schedule", WarmWindow{Schedule: "0 9 * *", DurationSeconds: 60}},
+		{"timezone", WarmWindow{Schedule: "0 9 * * *", DurationSeconds: 60, Timezone: "Mars/Olympus"}},
+		{"duration", WarmWindow{Schedule: "0 9 * * *"}},
+		{"too long", WarmWindow{Schedule: "0 9 * * *", DurationSeconds: 9 * 24 * 3600}},
+	}
+
+	for _, test := range tests {
+		if err := normalizeWarmWindow(&test.window); err == nil {
+			t.Errorf("normalizeWarmWindow accepted an invalid %s", test.name)
+		}
+	}
+}
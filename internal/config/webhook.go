@@ -0,0 +1,76 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Payload formats of a webhook.
+const (
+	WebhookFormatJSON  = "json"
+	WebhookFormatSlack = "slack"
+)
+
+// WebhookConfig represents an HTTP endpoint notified of container lifecycle events.
+type WebhookConfig struct {
+	URL     string   `yaml:"url"`     // Endpoint receiving the events through POST requests
+	Secret  string   `yaml:"secret"`  // Key of the HMAC-SHA256 signature of the payload; unsigned when empty
+	Format  string   `yaml:"format"`  // Payload format (json or slack)
+	Events  []string `yaml:"events"`  // Event types sent; the container lifecycle events when empty
+	Retries int      `yaml:"retries"` // Retries of a failed delivery, with exponential backoff
+}
+
+// Accepts reports whether the webhook is notified of an event type. Without a list of types,
+// only the container lifecycle events, whose type starts with "container.", are sent.
+func (wc WebhookConfig) Accepts(eventType string) bool {
+	if len(wc.Events) == 0 {
+		return strings.HasPrefix(eventType, "container.")
+	}
+	for _, accepted := range wc.Events {
+		if accepted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeWebhooks applies the defaults of the webhooks and rejects invalid ones.
+func normalizeWebhooks(webhooks []WebhookConfig) error {
+	for i := range webhooks {
+		webhook := &webhooks[i]
+
+		parsed, err := url.Parse(webhook.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("webhook %d: invalid url %q", i, webhook.URL)
+		}
+
+		webhook.Format = defaultString(webhook.Format, WebhookFormatJSON)
+		if webhook.Format != WebhookFormatJSON && webhook.Format != WebhookFormatSlack {
+			return fmt.Errorf("webhook %s: unsupported format %q", webhook.URL, webhook.Format)
+		}
+
+		if webhook.Retries < 0 {
+			return fmt.Errorf("webhook %s: retries must not be negative", webhook.URL)
+		}
+		if webhook.Retries == 0 {
+			webhook.Retries = 3
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+// BackendAddress returns the host and port the backend of a route is reached at. Backends with a
+// network are reached at the IP of their container on that network, taken from the runtime inspect
+// data and kept in the store until the container is started again or changes state.
+func BackendAddress(route config.RouteConfig) (string, error) {
+	if route.Backend.Network == "" {
+		return route.Backend.Address(), nil
+	}
+
+	key := route.Backend.ContainerKey()
+	stored, exists := container_store.GetByContainerName(key)
+	if !exists {
+		return "", fmt.Errorf("container %s not found", key)
+	}
+	if stored.Address != "" {
+		return stored.Address, nil
+	}
+
+	rt, err := getRuntime(route.Backend.Endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	inspect, err := rt.Inspect(context.Background(), stored.ID)
+	if err != nil {
+		return "", err
+	}
+
+	ip, attached := inspect.Networks[route.Backend.Network]
+	if !attached {
+		return "", fmt.Errorf("container %s has no address on network %s", key, route.Backend.Network)
+	}
+
+	port := route.Backend.Port
+	if port == 0 {
+		if len(inspect.ExposedPorts) == 0 {
+			return "", fmt.Errorf("container %s exposes no port", key)
+		}
+		port = inspect.ExposedPorts[0]
+	}
+
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+	container_store.UpdateAddress(stored.ID, address)
+	log.Printf("Resolved address of container %s on network %s: %s", key, route.Backend.Network, address)
+
+	return address, nil
+}
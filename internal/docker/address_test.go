@@ -0,0 +1,54 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestBackendAddressResolvesContainerNetwork(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	host, port := startHealthServer(t, http.StatusOK)
+
+	id := fake.AddContainer("app", "exited")
+	fake.SetNetwork(id, "backend", host, port)
+	syncContainersState()
+
+	route := newTestRoute("app", "", 0)
+	route.Backend.Network = "backend"
+	registerPolicies(route)
+
+	if _, err := StartContainer(route); err != nil {
+		t.Fatalf("StartContainer returned error: %v", err)
+	}
+
+	want := net.JoinHostPort(host, strconv.Itoa(port))
+	if address, err := BackendAddress(route); err != nil || address != want {
+		t.Errorf("BackendAddress = %q, %v; want %q", address, err, want)
+	}
+
+	// The container comes back with another IP after a restart.
+	fake.Crash(id)
+	fake.SetNetwork(id, "backend", "10.0.0.7", 9000)
+	syncContainersState()
+
+	if address, err := BackendAddress(route); err != nil || address != "10.0.0.7:9000" {
+		t.Errorf("BackendAddress after restart = %q, %v; want 10.0.0.7:9000", address, err)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
+)
+
+// CheckpointContainer saves the memory state of a container through CRIU and stops it. When the
+// runtime cannot checkpoint, or the checkpoint fails, the container is simply stopped. It returns
+// why the container could be neither checkpointed nor stopped.
+func CheckpointContainer(containerID string) error {
+	ctx := context.Background()
+	rt, err := getContainerRuntime(containerID)
+	if err != nil {
+		log.Printf("Error creating container runtime: %v", err)
+		return err
+	}
+
+	service := getServiceForContainer(containerID)
+	if service == "" {
+		log.Printf("Error finding the service associated with the container: %s", containerID)
+		return fmt.Errorf("no service is associated with container %s", containerID)
+	}
+
+	serviceMutex := getMutexForService(service)
+	serviceMutex.Lock()
+	defer serviceMutex.Unlock()
+
+	checkpointer, supported := rt.(container_runtime.Checkpointer)
+	if supported {
+		log.Printf("Checkpointing container: %s of service: %s", containerID, service)
+		if err = checkpointer.Checkpoint(ctx, containerID); err == nil {
+			log.Printf("Container %s checkpointed successfully.", containerID)
+			return nil
+		}
+	} else {
+		err = container_runtime.ErrNotSupported
+	}
+
+	log.Printf("Error checkpointing container %s, stopping it instead: %v", containerID, err)
+	return stopLockedContainer(ctx, rt, containerID, service)
+}
+
+// restoreContainer starts a container from its latest checkpoint. It returns false when there
+// is no checkpoint or the restore fails, so the caller can fall back to a cold start.
+func restoreContainer(ctx context.Context, rt container_runtime.Runtime, containerID string) bool {
+	checkpointer, supported := rt.(container_runtime.Checkpointer)
+	if !supported {
+		return false
+	}
+
+	log.Printf("Restoring container %s from its latest checkpoint", containerID)
+	if err := checkpointer.Restore(ctx, containerID); err != nil {
+		log.Printf("Error restoring container %s: %v", containerID, err)
+		return false
+	}
+
+	return true
+}
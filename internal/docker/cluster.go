@@ -0,0 +1,288 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/cluster"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/ratelimit"
+)
+
+var (
+	gatewayCluster atomic.Pointer[cluster.Cluster]
+	clusterOnce    sync.Once
+
+	localInFlight  = make(map[string]int)
+	remoteInFlight = make(map[string]int)
+	inFlightGuard  = &sync.Mutex{}
+
+	pinChanges      = make(map[string]cluster.PinChange) // Latest pin change of each container, local or merged
+	pinChangesGuard = &sync.Mutex{}
+
+	// wakeTimeout bounds how long a follower waits for the leader to start a container.
+	wakeTimeout      = 2 * time.Minute
+	wakePollInterval = 500 * time.Millisecond
+)
+
+// getCluster returns the membership of this instance in the high-availability cluster, or nil
+// when the gateway runs alone.
+func getCluster() *cluster.Cluster {
+	clusterOnce.Do(func() {
+		settings := config.GetGatewayConfig().Cluster
+		if !settings.Enabled() {
+			return
+		}
+
+		var store cluster.Store
+		switch settings.Backend {
+		case config.ClusterRedis:
+			redisStore := cluster.NewRedisStore(settings.RedisAddress, settings.RedisPassword)
+			// The rate limits of the instances are shared through Redis. The file backend keeps
+			// them in memory, as locking the file on every request would serialize the instances.
+			ratelimit.SetStore(redisStore)
+			store = redisStore
+		default:
+			store = cluster.NewFileStore(settings.File)
+		}
+
+		c := cluster.New(store, settings.Instance, time.Duration(settings.LeaseSeconds)*time.Second)
+		c.Elect(context.Background())
+		gatewayCluster.Store(c)
+	})
+	return gatewayCluster.Load()
+}
+
+// SetCluster replaces the cluster membership of the instance; nil runs the gateway alone.
+// It is meant for tests.
+func SetCluster(c *cluster.Cluster) {
+	clusterOnce.Do(func() {})
+	gatewayCluster.Store(c)
+}
+
+// isLeader reports whether this instance stops idle containers and carries out wake-ups.
+// A gateway running alone always does.
+func isLeader() bool {
+	c := getCluster()
+	return c == nil || c.IsLeader()
+}
+
+// CheckCluster starts the continuous process of taking part in the high-availability cluster:
+// electing the leader, sharing the container state and, on the leader, waking containers up on
+// behalf of the followers. It returns at once when the gateway runs alone.
+func CheckCluster() {
+	c := getCluster()
+	if c == nil {
+		return
+	}
+
+	go c.Run()
+	for {
+		syncClusterState(c)
+		if c.IsLeader() {
+			handleWakeRequests(c)
+			handleStopRequests(c)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// syncClusterState publishes the last accesses, in-flight requests and pin changes of this
+// instance and merges those of the other instances.
+func syncClusterState(c *cluster.Cluster) {
+	ctx := context.Background()
+
+	state := cluster.InstanceState{
+		Instance:   c.Instance(),
+		LastAccess: make(map[string]time.Time),
+		InFlight:   make(map[string]int),
+		Pins:       make(map[string]cluster.PinChange),
+	}
+	for _, stored := range container_store.GetAll() {
+		if !stored.LastAccess.IsZero() {
+			state.LastAccess[stored.Key()] = stored.LastAccess
+		}
+	}
+
+	inFlightGuard.Lock()
+	for key, requests := range localInFlight {
+		state.InFlight[key] = requests
+	}
+	inFlightGuard.Unlock()
+
+	pinChangesGuard.Lock()
+	for key, change := range pinChanges {
+		state.Pins[key] = change
+	}
+	pinChangesGuard.Unlock()
+
+	if err := c.Store().PublishState(ctx, state, c.LeaseTTL()); err != nil {
+		log.Printf("Error publishing the state of instance %s: %v", c.Instance(), err)
+		return
+	}
+
+	states, err := c.Store().States(ctx)
+	if err != nil {
+		log.Printf("Error reading the state of the cluster: %v", err)
+		return
+	}
+	mergeClusterState(c.Instance(), states)
+}
+
+// mergeClusterState applies the state published by the other instances to the local store.
+func mergeClusterState(instance string, states []cluster.InstanceState) {
+	inFlight := make(map[string]int)
+
+	for _, state := range states {
+		if state.Instance == instance {
+			continue
+		}
+
+		for key, lastAccess := range state.LastAccess {
+			if stored, exists := container_store.GetByContainerName(key); exists {
+				container_store.UpdateLastAccess(stored.ID, lastAccess)
+			}
+		}
+		for key, requests := range state.InFlight {
+			inFlight[key] += requests
+		}
+		for key, change := range state.Pins {
+			mergePinChange(key, change)
+		}
+	}
+
+	inFlightGuard.Lock()
+	remoteInFlight = inFlight
+	inFlightGuard.Unlock()
+}
+
+// recordPinChange records a pin change made on this instance, for the other instances to apply.
+func recordPinChange(containerKey string, pinned bool) {
+	pinChangesGuard.Lock()
+	defer pinChangesGuard.Unlock()
+
+	pinChanges[containerKey] = cluster.PinChange{Pinned: pinned, ChangedAt: time.Now()}
+}
+
+// mergePinChange applies a pin change made on another instance when it is the latest one known.
+func mergePinChange(containerKey string, change cluster.PinChange) {
+	pinChangesGuard.Lock()
+	defer pinChangesGuard.Unlock()
+
+	if current, exists := pinChanges[containerKey]; exists && !change.ChangedAt.After(current.ChangedAt) {
+		return
+	}
+	pinChanges[containerKey] = change
+
+	if stored, exists := container_store.GetByContainerName(containerKey); exists && stored.Pinned != change.Pinned {
+		container_store.UpdatePinned(stored.ID, change.Pinned)
+		log.Printf("Container %s pinned by another instance: %v", containerKey, change.Pinned)
+	}
+}
+
+// handleWakeRequests starts, in the background, the containers the followers asked for.
+func handleWakeRequests(c *cluster.Cluster) {
+	keys, err := c.Store().PopWakes(context.Background())
+	if err != nil {
+		log.Printf("Error reading the wake-up requests of the cluster: %v", err)
+	}
+
+	for _, key := range keys {
+		if !WakeContainer(key) {
+			log.Printf("Ignoring wake-up request for unknown container %s", key)
+		}
+	}
+}
+
+// handleStopRequests applies at once the idle action of the containers the followers asked to stop.
+func handleStopRequests(c *cluster.Cluster) {
+	keys, err := c.Store().PopStops(context.Background())
+	if err != nil {
+		log.Printf("Error reading the stop requests of the cluster: %v", err)
+	}
+
+	for _, key := range keys {
+		if !applyIdleAction(key) {
+			log.Printf("Ignoring stop request for unknown container %s", key)
+		}
+	}
+}
+
+// forwardWake asks the leader to start the container of a route and waits until it is running
+// and healthy.
+func forwardWake(route config.RouteConfig) (bool, error) {
+	key := route.Backend.ContainerKey()
+
+	log.Printf("Forwarding the start of container %s to the cluster leader", key)
+	if err := getCluster().Store().PushWake(context.Background(), key); err != nil {
+		log.Printf("Error forwarding the start of container %s: %v", key, err)
+		return false, err
+	}
+
+	policy, exists := config.GetHostStore().GetContainerPolicy(key)
+	if !exists {
+		policy = config.NewRoutePolicy(route)
+	}
+
+	deadline := time.Now().Add(wakeTimeout)
+	for time.Now().Before(deadline) {
+		if stored, exists := container_store.GetByContainerName(key); exists && stored.IsActive {
+			if err := checkPolicyHealth(policy); err != nil {
+				return false, fmt.Errorf("Healthcheck failed for container %s: %w", key, err)
+			}
+			return true, nil
+		}
+		time.Sleep(wakePollInterval)
+	}
+
+	return false, fmt.Errorf("%w waiting for the cluster leader to start container %s", ErrStartTimeout, key)
+}
+
+// BeginRequest records a request being proxied to a container, identified by its
+// config.ContainerKey. A container serving requests is never considered idle.
+func BeginRequest(containerKey string) {
+	inFlightGuard.Lock()
+	defer inFlightGuard.Unlock()
+
+	localInFlight[containerKey]++
+}
+
+// EndRequest records the end of a request recorded by BeginRequest.
+func EndRequest(containerKey string) {
+	inFlightGuard.Lock()
+	defer inFlightGuard.Unlock()
+
+	if localInFlight[containerKey] <= 1 {
+		delete(localInFlight, containerKey)
+		return
+	}
+	localInFlight[containerKey]--
+}
+
+// inFlightRequests returns the number of requests every instance is proxying to a container.
+func inFlightRequests(containerKey string) int {
+	inFlightGuard.Lock()
+	defer inFlightGuard.Unlock()
+
+	return localInFlight[containerKey] + remoteInFlight[containerKey]
+}
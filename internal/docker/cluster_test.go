@@ -0,0 +1,191 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/cluster"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+// setupFollower makes this instance a follower of another instance holding the leader lease.
+func setupFollower(t *testing.T) *cluster.MemoryStore {
+	t.Helper()
+
+	store := cluster.NewMemoryStore()
+	if leader, _ := store.AcquireLeadership(context.Background(), "gw-1", time.Minute); !leader {
+		t.Fatalf("gw-1 could not take the leader lease")
+	}
+
+	follower := cluster.New(store, "gw-2", time.Minute)
+	follower.Elect(context.Background())
+	SetCluster(follower)
+	interval := wakePollInterval
+	wakePollInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		SetCluster(nil)
+		wakePollInterval = interval
+	})
+
+	return store
+}
+
+func TestFollowerForwardsStartToLeader(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	store := setupFollower(t)
+	host, port := startHealthServer(t, http.StatusOK)
+
+	id := fake.AddContainer("app", "exited")
+	syncContainersState()
+
+	route := newTestRoute("app", host, port)
+	registerPolicies(route)
+
+	// The leader picks the wake-up request and starts the container. It is stopped and waited
+	// for before the next test resets the store and the policies.
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+		<-stopped
+	})
+
+	go func() {
+		defer close(stopped)
+		for {
+			if wakes, _ := store.PopWakes(context.Background()); len(wakes) > 0 {
+				fake.Start(context.Background(), id)
+				syncContainersState()
+				return
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+
+	if _, err := StartContainer(route); err != nil {
+		t.Fatalf("StartContainer returned error: %v", err)
+	}
+
+	stored, _ := container_store.GetByID(id)
+	if !stored.IsActive {
+		t.Errorf("stored container is not active after the leader started it")
+	}
+}
+
+func TestRemoteRequestsKeepContainerRunning(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	id := fake.AddContainer("app", "running")
+	syncContainersState()
+
+	route := newTestRoute("app", "127.0.0.1", 1)
+	route.TTL = 10
+	registerPolicies(route)
+	setLastAccess(t, id, time.Now().Add(-time.Hour))
+
+	mergeClusterState("gw-1", []cluster.InstanceState{{
+		Instance: "gw-2",
+		InFlight: map[string]int{"app": 1},
+	}})
+	t.Cleanup(func() { mergeClusterState("gw-1", nil) })
+
+	monitorAndStopContainers()
+	if state := fake.State(id); state != "running" {
+		t.Fatalf("runtime state = %q, want running while another instance serves a request", state)
+	}
+
+	accessed := time.Now().Truncate(time.Second)
+	mergeClusterState("gw-1", []cluster.InstanceState{{
+		Instance:   "gw-2",
+		LastAccess: map[string]time.Time{"app": accessed},
+	}})
+
+	stored, _ := container_store.GetByID(id)
+	if !stored.LastAccess.Equal(accessed) {
+		t.Errorf("last access = %s, want the access %s served by another instance", stored.LastAccess, accessed)
+	}
+}
+
+func TestFollowerForwardsStopToLeader(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	store := setupFollower(t)
+
+	id := fake.AddContainer("app", "running")
+	syncContainersState()
+	registerPolicies(newTestRoute("app", "127.0.0.1", 1))
+
+	if found, err := ApplyIdleAction("app"); !found || err != nil {
+		t.Fatalf("ApplyIdleAction(app) = %t, %v on a follower", found, err)
+	}
+	if state := fake.State(id); state != "running" {
+		t.Errorf("runtime state = %q, want running until the leader stops it", state)
+	}
+
+	stops, err := store.PopStops(context.Background())
+	if err != nil {
+		t.Fatalf("PopStops returned error: %v", err)
+	}
+	if len(stops) != 1 || stops[0] != "app" {
+		t.Errorf("stop requests = %v, want [app]", stops)
+	}
+}
+
+func TestPinChangesOfOtherInstancesApplied(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	t.Cleanup(func() {
+		pinChangesGuard.Lock()
+		pinChanges = make(map[string]cluster.PinChange)
+		pinChangesGuard.Unlock()
+	})
+
+	id := fake.AddContainer("app", "running")
+	syncContainersState()
+	registerPolicies(newTestRoute("app", "127.0.0.1", 1))
+
+	pinned := time.Now()
+	tests := []struct {
+		name   string
+		change cluster.PinChange
+		want   bool
+	}{
+		{name: "pinned", change: cluster.PinChange{Pinned: true, ChangedAt: pinned}, want: true},
+		{name: "older unpin ignored", change: cluster.PinChange{Pinned: false, ChangedAt: pinned.Add(-time.Minute)}, want: true},
+		{name: "newer unpin", change: cluster.PinChange{Pinned: false, ChangedAt: pinned.Add(time.Minute)}, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mergeClusterState("gw-1", []cluster.InstanceState{{
+				Instance: "gw-2",
+				Pins:     map[string]cluster.PinChange{"app": test.change},
+			}})
+
+			stored, _ := container_store.GetByID(id)
+			if stored.Pinned != test.want {
+				t.Errorf("pinned = %t, want %t", stored.Pinned, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+// addComposeContainer adds a container labelled as part of a compose service.
+func addComposeContainer(fake *container_runtime.FakeRuntime, name, state, project, service string) string {
+	id := fake.AddContainer(name, state)
+	fake.SetLabels(id, map[string]string{
+		"com.docker.compose.project": project,
+		"com.docker.compose.service": service,
+	})
+	return id
+}
+
+// newComposeRoute creates a route to the containers of a compose service.
+func newComposeRoute(project, service, host string, port int) config.RouteConfig {
+	route := newTestRoute(service, host, port)
+	route.Backend.ContainerName = ""
+	route.Backend.ComposeProject = project
+	route.Backend.ComposeService = service
+	return route
+}
+
+func TestStartContainerStartsComposeGroup(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	host, port := startHealthServer(t, http.StatusOK)
+
+	first := addComposeContainer(fake, "shop-web-1", "exited", "shop", "web")
+	second := addComposeContainer(fake, "shop-web-2", "exited", "shop", "web")
+	other := addComposeContainer(fake, "shop-db-1", "exited", "shop", "db")
+	syncContainersState()
+
+	route := newComposeRoute("shop", "web", host, port)
+	registerPolicies(route)
+
+	if _, err := StartContainer(route); err != nil {
+		t.Fatalf("StartContainer returned error: %v", err)
+	}
+
+	for _, id := range []string{first, second} {
+		if state := fake.State(id); state != "running" {
+			t.Errorf("state of %s = %q, want running", id, state)
+		}
+	}
+	if state := fake.State(other); state != "exited" {
+		t.Errorf("state of other service = %q, want exited", state)
+	}
+}
+
+func TestComposeGroupSurvivesRecreatedContainers(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	old := addComposeContainer(fake, "shop-web-1", "running", "shop", "web")
+	syncContainersState()
+
+	route := newComposeRoute("shop", "web", "127.0.0.1", 1)
+	route.TTL = 10
+	registerPolicies(route)
+
+	// compose recreates the container with a new ID and name.
+	fake.Remove(context.Background(), old)
+	recreated := addComposeContainer(fake, "shop_web_1", "running", "shop", "web")
+	syncContainersState()
+
+	stored, exists := container_store.GetByContainerName(route.Backend.ContainerKey())
+	if !exists || !stored.IsActive {
+		t.Fatalf("group = %+v, want active group", stored)
+	}
+
+	setLastAccess(t, stored.ID, time.Now().Add(-time.Minute))
+	monitorAndStopContainers()
+
+	if state := fake.State(recreated); state != "exited" {
+		t.Errorf("recreated container state = %q, want exited", state)
+	}
+}
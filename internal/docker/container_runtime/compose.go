@@ -0,0 +1,446 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package container_runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+// Labels set by Docker Compose on the containers it creates.
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+	composeOneoffLabel  = "com.docker.compose.oneoff"
+)
+
+var (
+	_ Runtime          = (*ComposeRuntime)(nil)
+	_ Checkpointer     = (*ComposeRuntime)(nil)
+	_ ServiceScaler    = (*ComposeRuntime)(nil)
+	_ DefinitionKeeper = (*ComposeRuntime)(nil)
+)
+
+// ComposeRuntime wraps a Runtime and exposes every Docker Compose service as one more container,
+// named with config.ComposeGroupName, grouping the containers that carry its compose labels. The
+// operations on a group apply to all its containers, so routes keep working when compose
+// recreates containers with new IDs or names. Other containers are passed through unchanged.
+type ComposeRuntime struct {
+	Runtime
+	endpoint string
+
+	removed      map[string][]string // Names of the containers of each group removed by the gateway
+	removedGuard sync.Mutex
+}
+
+// NewComposeRuntime wraps the runtime of an endpoint.
+func NewComposeRuntime(runtime Runtime, endpoint string) *ComposeRuntime {
+	return &ComposeRuntime{
+		Runtime:  runtime,
+		endpoint: endpoint,
+		removed:  make(map[string][]string),
+	}
+}
+
+func (cr *ComposeRuntime) List(ctx context.Context) ([]Container, error) {
+	containers, err := cr.Runtime.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]Container)
+	for _, container := range containers {
+		if id, ok := cr.groupID(container); ok {
+			groups[id] = append(groups[id], container)
+		}
+	}
+
+	ids := make([]string, 0, len(groups))
+	for id := range groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		containers = append(containers, newGroupContainer(id, groups[id]))
+	}
+	return containers, nil
+}
+
+func (cr *ComposeRuntime) Inspect(ctx context.Context, containerID string) (Container, error) {
+	if !cr.isGroup(containerID) {
+		return cr.Runtime.Inspect(ctx, containerID)
+	}
+
+	members, err := cr.members(ctx, containerID)
+	if err != nil {
+		return Container{}, err
+	}
+
+	group := newGroupContainer(containerID, members)
+	for _, member := range members {
+		if member.State != "running" {
+			continue
+		}
+
+		// The group is reached through its first running container.
+		inspect, err := cr.Runtime.Inspect(ctx, member.ID)
+		if err != nil {
+			return Container{}, err
+		}
+		group.Networks, group.ExposedPorts = inspect.Networks, inspect.ExposedPorts
+		break
+	}
+	return group, nil
+}
+
+func (cr *ComposeRuntime) Start(ctx context.Context, containerID string) error {
+	if !cr.isGroup(containerID) {
+		return cr.Runtime.Start(ctx, containerID)
+	}
+
+	return cr.each(ctx, containerID, func(member Container) error {
+		switch member.State {
+		case "running":
+			return nil
+		case "paused":
+			return cr.Runtime.Unpause(ctx, member.ID)
+		default:
+			return cr.Runtime.Start(ctx, member.ID)
+		}
+	})
+}
+
+func (cr *ComposeRuntime) Stop(ctx context.Context, containerID string) error {
+	if !cr.isGroup(containerID) {
+		return cr.Runtime.Stop(ctx, containerID)
+	}
+
+	return cr.each(ctx, containerID, func(member Container) error {
+		if member.State != "running" && member.State != "paused" {
+			return nil
+		}
+		return cr.Runtime.Stop(ctx, member.ID)
+	})
+}
+
+func (cr *ComposeRuntime) Pause(ctx context.Context, containerID string) error {
+	if !cr.isGroup(containerID) {
+		return cr.Runtime.Pause(ctx, containerID)
+	}
+
+	return cr.each(ctx, containerID, func(member Container) error {
+		if member.State != "running" {
+			return nil
+		}
+		return cr.Runtime.Pause(ctx, member.ID)
+	})
+}
+
+func (cr *ComposeRuntime) Unpause(ctx context.Context, containerID string) error {
+	if !cr.isGroup(containerID) {
+		return cr.Runtime.Unpause(ctx, containerID)
+	}
+
+	return cr.each(ctx, containerID, func(member Container) error {
+		if member.State != "paused" {
+			return nil
+		}
+		return cr.Runtime.Unpause(ctx, member.ID)
+	})
+}
+
+// Remove removes every container of a group, remembering their names so Recreate can create
+// them again.
+func (cr *ComposeRuntime) Remove(ctx context.Context, containerID string) error {
+	if !cr.isGroup(containerID) {
+		return cr.Runtime.Remove(ctx, containerID)
+	}
+
+	var names []string
+	err := cr.each(ctx, containerID, func(member Container) error {
+		if err := cr.Runtime.Remove(ctx, member.ID); err != nil {
+			return err
+		}
+		names = append(names, member.Name)
+		return nil
+	})
+
+	cr.removedGuard.Lock()
+	cr.removed[containerID] = append(cr.removed[containerID], names...)
+	cr.removedGuard.Unlock()
+
+	return err
+}
+
+// Recreate creates the removed containers of a group again. The group keeps its ID.
+func (cr *ComposeRuntime) Recreate(ctx context.Context, containerName string) (string, error) {
+	if !strings.HasPrefix(containerName, config.ComposeGroupPrefix) {
+		return cr.Runtime.Recreate(ctx, containerName)
+	}
+
+	id := config.ContainerKey(cr.endpoint, containerName)
+
+	cr.removedGuard.Lock()
+	names := cr.removed[id]
+	delete(cr.removed, id)
+	cr.removedGuard.Unlock()
+
+	if len(names) == 0 {
+		return "", fmt.Errorf("no definition saved for container %s", containerName)
+	}
+
+	for i, name := range names {
+		if _, err := cr.Runtime.Recreate(ctx, name); err != nil {
+			cr.removedGuard.Lock()
+			cr.removed[id] = append(cr.removed[id], names[i:]...)
+			cr.removedGuard.Unlock()
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// Definition returns the definitions of the removed containers of a group, by container name.
+func (cr *ComposeRuntime) Definition(containerName string) (string, error) {
+	keeper, ok := cr.Runtime.(DefinitionKeeper)
+	if !ok {
+		return "", ErrNotSupported
+	}
+	if !strings.HasPrefix(containerName, config.ComposeGroupPrefix) {
+		return keeper.Definition(containerName)
+	}
+
+	cr.removedGuard.Lock()
+	names := cr.removed[config.ContainerKey(cr.endpoint, containerName)]
+	cr.removedGuard.Unlock()
+
+	if len(names) == 0 {
+		return "", fmt.Errorf("no definition saved for container %s", containerName)
+	}
+
+	members := make(map[string]json.RawMessage, len(names))
+	for _, name := range names {
+		definition, err := keeper.Definition(name)
+		if err != nil {
+			return "", err
+		}
+		members[name] = json.RawMessage(definition)
+	}
+
+	content, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// KeepDefinition restores the definitions returned by Definition, remembering the containers of
+// a group so Recreate can create them again.
+func (cr *ComposeRuntime) KeepDefinition(containerName string, definition string) error {
+	keeper, ok := cr.Runtime.(DefinitionKeeper)
+	if !ok {
+		return ErrNotSupported
+	}
+	if !strings.HasPrefix(containerName, config.ComposeGroupPrefix) {
+		return keeper.KeepDefinition(containerName, definition)
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(definition), &members); err != nil {
+		return fmt.Errorf("invalid definition of container %s: %w", containerName, err)
+	}
+
+	names := make([]string, 0, len(members))
+	for name, member := range members {
+		if err := keeper.KeepDefinition(name, string(member)); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cr.removedGuard.Lock()
+	defer cr.removedGuard.Unlock()
+
+	cr.removed[config.ContainerKey(cr.endpoint, containerName)] = names
+	return nil
+}
+
+// Stats adds up the counters of the containers of a group.
+func (cr *ComposeRuntime) Stats(ctx context.Context, containerID string) (Stats, error) {
+	if !cr.isGroup(containerID) {
+		return cr.Runtime.Stats(ctx, containerID)
+	}
+
+	var total Stats
+	err := cr.each(ctx, containerID, func(member Container) error {
+		if member.State != "running" {
+			return nil
+		}
+
+		stats, err := cr.Runtime.Stats(ctx, member.ID)
+		if err != nil {
+			return err
+		}
+
+		total.CPUTotal += stats.CPUTotal
+		total.NetworkBytes += stats.NetworkBytes
+		total.MemoryBytes += stats.MemoryBytes
+		if stats.SystemTotal > total.SystemTotal {
+			total.SystemTotal = stats.SystemTotal
+			total.OnlineCPUs = stats.OnlineCPUs
+		}
+		return nil
+	})
+	return total, err
+}
+
+func (cr *ComposeRuntime) Checkpoint(ctx context.Context, containerID string) error {
+	checkpointer, ok := cr.Runtime.(Checkpointer)
+	if !ok {
+		return ErrNotSupported
+	}
+	if !cr.isGroup(containerID) {
+		return checkpointer.Checkpoint(ctx, containerID)
+	}
+
+	return cr.each(ctx, containerID, func(member Container) error {
+		if member.State != "running" {
+			return nil
+		}
+		return checkpointer.Checkpoint(ctx, member.ID)
+	})
+}
+
+func (cr *ComposeRuntime) Restore(ctx context.Context, containerID string) error {
+	checkpointer, ok := cr.Runtime.(Checkpointer)
+	if !ok {
+		return ErrNotSupported
+	}
+	if !cr.isGroup(containerID) {
+		return checkpointer.Restore(ctx, containerID)
+	}
+
+	return cr.each(ctx, containerID, func(member Container) error {
+		if member.State == "running" {
+			return nil
+		}
+		return checkpointer.Restore(ctx, member.ID)
+	})
+}
+
+func (cr *ComposeRuntime) ListServices(ctx context.Context) ([]Service, error) {
+	if scaler, ok := cr.Runtime.(ServiceScaler); ok {
+		return scaler.ListServices(ctx)
+	}
+	return nil, ErrNotSupported
+}
+
+func (cr *ComposeRuntime) ScaleService(ctx context.Context, serviceID string, replicas uint64) error {
+	if scaler, ok := cr.Runtime.(ServiceScaler); ok {
+		return scaler.ScaleService(ctx, serviceID, replicas)
+	}
+	return ErrNotSupported
+}
+
+// groupID returns the ID of the group a container belongs to, if it was created by compose.
+func (cr *ComposeRuntime) groupID(container Container) (string, bool) {
+	project, service := container.Labels[composeProjectLabel], container.Labels[composeServiceLabel]
+	if project == "" || service == "" || strings.EqualFold(container.Labels[composeOneoffLabel], "true") {
+		return "", false
+	}
+	return config.ContainerKey(cr.endpoint, config.ComposeGroupName(project, service)), true
+}
+
+// isGroup reports whether an ID is the ID of a compose group.
+func (cr *ComposeRuntime) isGroup(containerID string) bool {
+	return strings.HasPrefix(containerID, config.ContainerKey(cr.endpoint, config.ComposeGroupPrefix))
+}
+
+// members returns the containers of a group.
+func (cr *ComposeRuntime) members(ctx context.Context, groupID string) ([]Container, error) {
+	containers, err := cr.Runtime.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []Container
+	for _, container := range containers {
+		if id, ok := cr.groupID(container); ok && id == groupID {
+			members = append(members, container)
+		}
+	}
+
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no containers found for %s", groupID)
+	}
+	return members, nil
+}
+
+// each applies an operation to every container of a group, returning the errors of all of them.
+func (cr *ComposeRuntime) each(ctx context.Context, groupID string, operation func(member Container) error) error {
+	members, err := cr.members(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, member := range members {
+		if err := operation(member); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", member.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newGroupContainer describes a group as a container. The group runs when all its containers run,
+// and is paused or created when all of them are; any other mix is reported as exited so that
+// starting the group brings every container up.
+func newGroupContainer(groupID string, members []Container) Container {
+	state := members[0].State
+	for _, member := range members[1:] {
+		if member.State != state {
+			state = "exited"
+			break
+		}
+	}
+	if state != "running" && state != "paused" && state != "created" {
+		state = "exited"
+	}
+
+	name := groupID
+	if index := strings.Index(groupID, config.ComposeGroupPrefix); index > 0 {
+		name = groupID[index:]
+	}
+
+	return Container{
+		ID:    groupID,
+		Name:  name,
+		State: state,
+		Labels: map[string]string{
+			composeProjectLabel: members[0].Labels[composeProjectLabel],
+			composeServiceLabel: members[0].Labels[composeServiceLabel],
+		},
+	}
+}
@@ -0,0 +1,480 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package container_runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+
+	cgroup1stats "github.com/containerd/cgroups/v3/cgroup1/stats"
+	cgroup2stats "github.com/containerd/cgroups/v3/cgroup2/stats"
+	containerd "github.com/containerd/containerd/v2/client"
+	"github.com/containerd/containerd/v2/core/containers"
+	"github.com/containerd/containerd/v2/pkg/cio"
+	"github.com/containerd/containerd/v2/pkg/namespaces"
+	"github.com/containerd/errdefs"
+	"github.com/containerd/typeurl/v2"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	defaultContainerdSocket    = "/run/containerd/containerd.sock"
+	defaultContainerdNamespace = "default"
+
+	// containerdNameLabel is the label nerdctl uses to store the name of a container.
+	containerdNameLabel = "nerdctl/name"
+
+	containerdStopTimeout = 10 * time.Second
+)
+
+var (
+	_ Runtime          = (*ContainerdRuntime)(nil)
+	_ DefinitionKeeper = (*ContainerdRuntime)(nil)
+)
+
+// ContainerdRuntime implements Runtime on top of the containerd API. Containers are named by
+// their nerdctl name label when present, or by their containerd ID otherwise. Tasks started by
+// the gateway have no attached IO.
+type ContainerdRuntime struct {
+	client    *containerd.Client
+	namespace string
+
+	removed      map[string]containers.Container
+	removedGuard sync.Mutex
+}
+
+// NewContainerdRuntime creates a runtime connected to the containerd socket at address, working
+// in the given namespace.
+func NewContainerdRuntime(address, namespace string) (*ContainerdRuntime, error) {
+	if address == "" {
+		address = defaultContainerdSocket
+	}
+	if namespace == "" {
+		namespace = defaultContainerdNamespace
+	}
+
+	cli, err := containerd.New(address, containerd.WithDefaultNamespace(namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ContainerdRuntime{
+		client:    cli,
+		namespace: namespace,
+		removed:   make(map[string]containers.Container),
+	}, nil
+}
+
+func (cr *ContainerdRuntime) List(ctx context.Context) ([]Container, error) {
+	ctx = namespaces.WithNamespace(ctx, cr.namespace)
+
+	loaded, err := cr.client.Containers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Container, 0, len(loaded))
+	for _, c := range loaded {
+		current, err := cr.describe(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, current)
+	}
+	return result, nil
+}
+
+func (cr *ContainerdRuntime) Inspect(ctx context.Context, containerID string) (Container, error) {
+	ctx = namespaces.WithNamespace(ctx, cr.namespace)
+
+	c, err := cr.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return Container{}, err
+	}
+	return cr.describe(ctx, c)
+}
+
+// Start creates a new task for the container, deleting the one left by a previous run, and starts it.
+func (cr *ContainerdRuntime) Start(ctx context.Context, containerID string) error {
+	ctx = namespaces.WithNamespace(ctx, cr.namespace)
+
+	c, err := cr.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	if task, err := c.Task(ctx, nil); err == nil {
+		status, err := task.Status(ctx)
+		if err != nil {
+			return err
+		}
+		if status.Status == containerd.Running {
+			return nil
+		}
+		if _, err := task.Delete(ctx); err != nil {
+			return err
+		}
+	} else if !errdefs.IsNotFound(err) {
+		return err
+	}
+
+	task, err := c.NewTask(ctx, cio.NullIO)
+	if err != nil {
+		return err
+	}
+	return task.Start(ctx)
+}
+
+// Stop sends SIGTERM to the task, SIGKILL if it does not exit in time, and deletes it.
+func (cr *ContainerdRuntime) Stop(ctx context.Context, containerID string) error {
+	ctx = namespaces.WithNamespace(ctx, cr.namespace)
+
+	task, err := cr.loadTask(ctx, containerID)
+	if errdefs.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	exited, err := task.Wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := task.Kill(ctx, syscall.SIGTERM); err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(containerdStopTimeout):
+		if err := task.Kill(ctx, syscall.SIGKILL); err != nil && !errdefs.IsNotFound(err) {
+			return err
+		}
+		// A task stuck in the kernel may ignore SIGKILL too; give up rather than block the caller.
+		select {
+		case <-exited:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(containerdStopTimeout):
+			return fmt.Errorf("task of container %s did not exit after SIGKILL", containerID)
+		}
+	}
+
+	_, err = task.Delete(ctx)
+	return err
+}
+
+func (cr *ContainerdRuntime) Pause(ctx context.Context, containerID string) error {
+	ctx = namespaces.WithNamespace(ctx, cr.namespace)
+
+	task, err := cr.loadTask(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	return task.Pause(ctx)
+}
+
+func (cr *ContainerdRuntime) Unpause(ctx context.Context, containerID string) error {
+	ctx = namespaces.WithNamespace(ctx, cr.namespace)
+
+	task, err := cr.loadTask(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	return task.Resume(ctx)
+}
+
+// Remove stops the container and deletes its metadata, keeping the snapshot so Recreate can
+// register the same container again.
+func (cr *ContainerdRuntime) Remove(ctx context.Context, containerID string) error {
+	ctx = namespaces.WithNamespace(ctx, cr.namespace)
+
+	if err := cr.Stop(ctx, containerID); err != nil {
+		return err
+	}
+
+	c, err := cr.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	info, err := c.Info(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Delete(ctx); err != nil {
+		return err
+	}
+
+	cr.removedGuard.Lock()
+	defer cr.removedGuard.Unlock()
+
+	cr.removed[containerName(info)] = info
+	return nil
+}
+
+func (cr *ContainerdRuntime) Recreate(ctx context.Context, name string) (string, error) {
+	ctx = namespaces.WithNamespace(ctx, cr.namespace)
+
+	cr.removedGuard.Lock()
+	info, exists := cr.removed[name]
+	cr.removedGuard.Unlock()
+
+	if !exists {
+		return "", fmt.Errorf("no definition saved for container %s", name)
+	}
+
+	created, err := cr.client.ContainerService().Create(ctx, info)
+	if err != nil {
+		return "", err
+	}
+
+	cr.removedGuard.Lock()
+	delete(cr.removed, name)
+	cr.removedGuard.Unlock()
+
+	return created.ID, nil
+}
+
+// containerdDefinition is the JSON form of the metadata of a removed container.
+type containerdDefinition struct {
+	ID             string
+	Labels         map[string]string
+	Image          string
+	Runtime        string
+	RuntimeOptions *containerdAny
+	Spec           *containerdAny
+	SnapshotKey    string
+	Snapshotter    string
+	Extensions     map[string]*containerdAny
+	SandboxID      string
+}
+
+// containerdAny is the JSON form of a typeurl.Any.
+type containerdAny struct {
+	TypeURL string
+	Value   []byte
+}
+
+func encodeAny(value typeurl.Any) *containerdAny {
+	if value == nil {
+		return nil
+	}
+	return &containerdAny{TypeURL: value.GetTypeUrl(), Value: value.GetValue()}
+}
+
+func decodeAny(value *containerdAny) typeurl.Any {
+	if value == nil {
+		return nil
+	}
+	return &anypb.Any{TypeUrl: value.TypeURL, Value: value.Value}
+}
+
+func (cr *ContainerdRuntime) Definition(containerName string) (string, error) {
+	cr.removedGuard.Lock()
+	info, exists := cr.removed[containerName]
+	cr.removedGuard.Unlock()
+
+	if !exists {
+		return "", fmt.Errorf("no definition saved for container %s", containerName)
+	}
+
+	definition := containerdDefinition{
+		ID:             info.ID,
+		Labels:         info.Labels,
+		Image:          info.Image,
+		Runtime:        info.Runtime.Name,
+		RuntimeOptions: encodeAny(info.Runtime.Options),
+		Spec:           encodeAny(info.Spec),
+		SnapshotKey:    info.SnapshotKey,
+		Snapshotter:    info.Snapshotter,
+		SandboxID:      info.SandboxID,
+	}
+	if len(info.Extensions) > 0 {
+		definition.Extensions = make(map[string]*containerdAny, len(info.Extensions))
+		for name, extension := range info.Extensions {
+			definition.Extensions[name] = encodeAny(extension)
+		}
+	}
+
+	content, err := json.Marshal(definition)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (cr *ContainerdRuntime) KeepDefinition(containerName string, content string) error {
+	var definition containerdDefinition
+	if err := json.Unmarshal([]byte(content), &definition); err != nil {
+		return fmt.Errorf("invalid definition of container %s: %w", containerName, err)
+	}
+
+	info := containers.Container{
+		ID:          definition.ID,
+		Labels:      definition.Labels,
+		Image:       definition.Image,
+		Runtime:     containers.RuntimeInfo{Name: definition.Runtime, Options: decodeAny(definition.RuntimeOptions)},
+		Spec:        decodeAny(definition.Spec),
+		SnapshotKey: definition.SnapshotKey,
+		Snapshotter: definition.Snapshotter,
+		SandboxID:   definition.SandboxID,
+	}
+	if len(definition.Extensions) > 0 {
+		info.Extensions = make(map[string]typeurl.Any, len(definition.Extensions))
+		for name, extension := range definition.Extensions {
+			info.Extensions[name] = decodeAny(extension)
+		}
+	}
+
+	cr.removedGuard.Lock()
+	defer cr.removedGuard.Unlock()
+
+	cr.removed[containerName] = info
+	return nil
+}
+
+func (cr *ContainerdRuntime) Events(ctx context.Context) (<-chan Event, <-chan error) {
+	ctx = namespaces.WithNamespace(ctx, cr.namespace)
+
+	envelopes, errs := cr.client.Subscribe(ctx, `topic~="/tasks/"`)
+
+	result := make(chan Event)
+	go func() {
+		defer close(result)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case envelope, ok := <-envelopes:
+				if !ok {
+					return
+				}
+
+				event := Event{Action: envelope.Topic}
+				if decoded, err := typeurl.UnmarshalAny(envelope.Event); err == nil {
+					if withID, ok := decoded.(interface{ GetContainerID() string }); ok {
+						event.ContainerID = withID.GetContainerID()
+					}
+				}
+
+				select {
+				case result <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return result, errs
+}
+
+// Stats reads the cgroup metrics of the task. Network counters are not reported by containerd.
+func (cr *ContainerdRuntime) Stats(ctx context.Context, containerID string) (Stats, error) {
+	ctx = namespaces.WithNamespace(ctx, cr.namespace)
+
+	task, err := cr.loadTask(ctx, containerID)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	metric, err := task.Metrics(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	data, err := typeurl.UnmarshalAny(metric.Data)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	// The CPU usage is compared to wall-clock time, so it is already relative to a single CPU.
+	stats := Stats{SystemTotal: uint64(time.Now().UnixNano()), OnlineCPUs: 1}
+
+	switch m := data.(type) {
+	case *cgroup1stats.Metrics:
+		if m.CPU != nil && m.CPU.Usage != nil {
+			stats.CPUTotal = m.CPU.Usage.Total
+		}
+		if m.Memory != nil && m.Memory.Usage != nil {
+			stats.MemoryBytes = m.Memory.Usage.Usage
+		}
+	case *cgroup2stats.Metrics:
+		if m.CPU != nil {
+			stats.CPUTotal = m.CPU.UsageUsec * 1000
+		}
+		if m.Memory != nil {
+			stats.MemoryBytes = m.Memory.Usage
+		}
+	default:
+		return Stats{}, fmt.Errorf("unsupported metrics type %T", data)
+	}
+
+	return stats, nil
+}
+
+// describe converts a containerd container and the status of its task into a Container.
+func (cr *ContainerdRuntime) describe(ctx context.Context, c containerd.Container) (Container, error) {
+	info, err := c.Info(ctx, containerd.WithoutRefreshedMetadata)
+	if err != nil {
+		return Container{}, err
+	}
+
+	state := "exited"
+	if task, err := c.Task(ctx, nil); err == nil {
+		if status, err := task.Status(ctx); err == nil {
+			switch status.Status {
+			case containerd.Running:
+				state = "running"
+			case containerd.Paused, containerd.Pausing:
+				state = "paused"
+			case containerd.Created:
+				state = "created"
+			}
+		}
+	} else if !errdefs.IsNotFound(err) {
+		return Container{}, err
+	}
+
+	return Container{ID: info.ID, Name: containerName(info), State: state, Labels: info.Labels}, nil
+}
+
+// loadTask returns the task of a container.
+func (cr *ContainerdRuntime) loadTask(ctx context.Context, containerID string) (containerd.Task, error) {
+	c, err := cr.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	return c.Task(ctx, nil)
+}
+
+// containerName returns the nerdctl name of a container, or its ID when it has none.
+func containerName(info containers.Container) string {
+	if name := info.Labels[containerdNameLabel]; name != "" {
+		return name
+	}
+	return info.ID
+}
@@ -0,0 +1,367 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package container_runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/checkpoint"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+)
+
+const checkpointPrefix = "gateway-"
+
+var (
+	_ Runtime          = (*DockerRuntime)(nil)
+	_ Checkpointer     = (*DockerRuntime)(nil)
+	_ DefinitionKeeper = (*DockerRuntime)(nil)
+)
+
+// DockerRuntime implements Runtime on top of the Docker Engine API.
+type DockerRuntime struct {
+	client *client.Client
+
+	templates      map[string]container.InspectResponse
+	templatesGuard sync.Mutex
+}
+
+// NewDockerRuntime creates a runtime connected to the daemon at host, or to the one configured by
+// the DOCKER_* environment variables when host is empty.
+func NewDockerRuntime(host string) (*DockerRuntime, error) {
+	opts := []client.Opt{client.FromEnv}
+	if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewDockerRuntimeWithClient(cli), nil
+}
+
+// DockerTLS holds the client certificates used to connect to a Docker daemon over TCP.
+type DockerTLS struct {
+	CACert string
+	Cert   string
+	Key    string
+}
+
+// NewDockerEndpointRuntime creates a runtime connected to a named Docker endpoint. The host may be
+// a unix socket, a TCP address, optionally secured with tls, or an ssh://[user@]host[:port] URL.
+func NewDockerEndpointRuntime(host string, tls *DockerTLS) (*DockerRuntime, error) {
+	opts := []client.Opt{client.WithAPIVersionNegotiation()}
+
+	if strings.HasPrefix(host, "ssh://") {
+		dialer, err := sshDialer(host)
+		if err != nil {
+			return nil, err
+		}
+		// The host is only used to build request URLs, the connection goes through ssh.
+		opts = append(opts, client.WithHost("http://docker.example.com"), client.WithDialContext(dialer))
+	} else {
+		opts = append(opts, client.WithHost(host))
+	}
+
+	if tls != nil {
+		opts = append(opts, client.WithTLSClientConfig(tls.CACert, tls.Cert, tls.Key))
+	}
+
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewDockerRuntimeWithClient(cli), nil
+}
+
+// NewDockerRuntimeWithClient creates a runtime using an existing Docker client.
+func NewDockerRuntimeWithClient(cli *client.Client) *DockerRuntime {
+	return &DockerRuntime{
+		client:    cli,
+		templates: make(map[string]container.InspectResponse),
+	}
+}
+
+// Client returns the underlying Docker client.
+func (dr *DockerRuntime) Client() *client.Client {
+	return dr.client
+}
+
+func (dr *DockerRuntime) List(ctx context.Context) ([]Container, error) {
+	summaries, err := dr.client.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	containers := make([]Container, 0, len(summaries))
+	for _, summary := range summaries {
+		name := ""
+		if len(summary.Names) > 0 {
+			name = strings.TrimPrefix(summary.Names[0], "/")
+		}
+
+		containers = append(containers, Container{
+			ID:     summary.ID,
+			Name:   name,
+			State:  summary.State,
+			Labels: summary.Labels,
+		})
+	}
+	return containers, nil
+}
+
+func (dr *DockerRuntime) Inspect(ctx context.Context, containerID string) (Container, error) {
+	inspect, err := dr.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return Container{}, err
+	}
+
+	result := Container{
+		ID:   inspect.ID,
+		Name: strings.TrimPrefix(inspect.Name, "/"),
+	}
+	if inspect.State != nil {
+		result.State = string(inspect.State.Status)
+	}
+	if inspect.Config != nil {
+		result.Labels = inspect.Config.Labels
+
+		var ports []string
+		for port := range inspect.Config.ExposedPorts {
+			ports = append(ports, string(port))
+		}
+		result.ExposedPorts = parseExposedPorts(ports)
+	}
+	if inspect.NetworkSettings != nil {
+		result.Networks = make(map[string]string)
+		for name, endpoint := range inspect.NetworkSettings.Networks {
+			if endpoint != nil && endpoint.IPAddress != "" {
+				result.Networks[name] = endpoint.IPAddress
+			}
+		}
+	}
+	return result, nil
+}
+
+func (dr *DockerRuntime) Start(ctx context.Context, containerID string) error {
+	return dr.client.ContainerStart(ctx, containerID, container.StartOptions{})
+}
+
+func (dr *DockerRuntime) Stop(ctx context.Context, containerID string) error {
+	return dr.client.ContainerStop(ctx, containerID, container.StopOptions{})
+}
+
+func (dr *DockerRuntime) Pause(ctx context.Context, containerID string) error {
+	return dr.client.ContainerPause(ctx, containerID)
+}
+
+func (dr *DockerRuntime) Unpause(ctx context.Context, containerID string) error {
+	return dr.client.ContainerUnpause(ctx, containerID)
+}
+
+func (dr *DockerRuntime) Remove(ctx context.Context, containerID string) error {
+	inspect, err := dr.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	if err := dr.client.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true}); err != nil {
+		return err
+	}
+
+	dr.templatesGuard.Lock()
+	defer dr.templatesGuard.Unlock()
+
+	dr.templates[strings.TrimPrefix(inspect.Name, "/")] = inspect
+	return nil
+}
+
+func (dr *DockerRuntime) Recreate(ctx context.Context, containerName string) (string, error) {
+	dr.templatesGuard.Lock()
+	inspect, exists := dr.templates[containerName]
+	dr.templatesGuard.Unlock()
+
+	if !exists {
+		return "", fmt.Errorf("no definition saved for container %s", containerName)
+	}
+
+	endpoints := make(map[string]*network.EndpointSettings)
+	if inspect.NetworkSettings != nil {
+		for name, endpoint := range inspect.NetworkSettings.Networks {
+			endpoints[name] = &network.EndpointSettings{
+				Aliases:    endpoint.Aliases,
+				IPAMConfig: endpoint.IPAMConfig,
+				Links:      endpoint.Links,
+				DriverOpts: endpoint.DriverOpts,
+			}
+		}
+	}
+
+	resp, err := dr.client.ContainerCreate(ctx, inspect.Config, inspect.HostConfig,
+		&network.NetworkingConfig{EndpointsConfig: endpoints}, nil, containerName)
+	if err != nil {
+		return "", err
+	}
+
+	dr.templatesGuard.Lock()
+	delete(dr.templates, containerName)
+	dr.templatesGuard.Unlock()
+
+	return resp.ID, nil
+}
+
+func (dr *DockerRuntime) Definition(containerName string) (string, error) {
+	dr.templatesGuard.Lock()
+	inspect, exists := dr.templates[containerName]
+	dr.templatesGuard.Unlock()
+
+	if !exists {
+		return "", fmt.Errorf("no definition saved for container %s", containerName)
+	}
+
+	content, err := json.Marshal(inspect)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (dr *DockerRuntime) KeepDefinition(containerName string, definition string) error {
+	var inspect container.InspectResponse
+	if err := json.Unmarshal([]byte(definition), &inspect); err != nil {
+		return fmt.Errorf("invalid definition of container %s: %w", containerName, err)
+	}
+
+	dr.templatesGuard.Lock()
+	defer dr.templatesGuard.Unlock()
+
+	dr.templates[containerName] = inspect
+	return nil
+}
+
+func (dr *DockerRuntime) Events(ctx context.Context) (<-chan Event, <-chan error) {
+	messages, errs := dr.client.Events(ctx, events.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("type", string(events.ContainerEventType))),
+	})
+
+	result := make(chan Event)
+	go func() {
+		defer close(result)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case result <- Event{ContainerID: message.Actor.ID, Action: string(message.Action)}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return result, errs
+}
+
+func (dr *DockerRuntime) Stats(ctx context.Context, containerID string) (Stats, error) {
+	reader, err := dr.client.ContainerStatsOneShot(ctx, containerID)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer reader.Body.Close()
+
+	var response container.StatsResponse
+	if err := json.NewDecoder(reader.Body).Decode(&response); err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{
+		CPUTotal:    response.CPUStats.CPUUsage.TotalUsage,
+		SystemTotal: response.CPUStats.SystemUsage,
+		OnlineCPUs:  response.CPUStats.OnlineCPUs,
+		MemoryBytes: response.MemoryStats.Usage,
+	}
+	for _, network := range response.Networks {
+		stats.NetworkBytes += network.RxBytes + network.TxBytes
+	}
+	return stats, nil
+}
+
+// Checkpoint saves the memory of a container through CRIU and stops it. Older checkpoints
+// created by the gateway are deleted once the new one is written.
+func (dr *DockerRuntime) Checkpoint(ctx context.Context, containerID string) error {
+	previous, _ := dr.listCheckpoints(ctx, containerID)
+	checkpointID := fmt.Sprintf("%s%d", checkpointPrefix, time.Now().UnixNano())
+
+	err := dr.client.CheckpointCreate(ctx, containerID, checkpoint.CreateOptions{CheckpointID: checkpointID, Exit: true})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range previous {
+		if err := dr.client.CheckpointDelete(ctx, containerID, checkpoint.DeleteOptions{CheckpointID: name}); err != nil {
+			log.Printf("Error deleting checkpoint %s of container %s: %v", name, containerID, err)
+		}
+	}
+	return nil
+}
+
+// Restore starts a container from the latest checkpoint created by the gateway.
+func (dr *DockerRuntime) Restore(ctx context.Context, containerID string) error {
+	checkpoints, err := dr.listCheckpoints(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	if len(checkpoints) == 0 {
+		return fmt.Errorf("no checkpoint available for container %s", containerID)
+	}
+
+	latest := checkpoints[len(checkpoints)-1]
+	return dr.client.ContainerStart(ctx, containerID, container.StartOptions{CheckpointID: latest})
+}
+
+// listCheckpoints returns the checkpoints created by the gateway for a container, oldest first.
+func (dr *DockerRuntime) listCheckpoints(ctx context.Context, containerID string) ([]string, error) {
+	summaries, err := dr.client.CheckpointList(ctx, containerID, checkpoint.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, summary := range summaries {
+		if strings.HasPrefix(summary.Name, checkpointPrefix) {
+			names = append(names, summary.Name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
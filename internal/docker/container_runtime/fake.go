@@ -0,0 +1,474 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package container_runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	_ Runtime          = (*FakeRuntime)(nil)
+	_ ServiceScaler    = (*FakeRuntime)(nil)
+	_ DefinitionKeeper = (*FakeRuntime)(nil)
+)
+
+// fakeIDs numbers the containers of every FakeRuntime, so IDs stay unique across instances.
+var fakeIDs atomic.Int64
+
+// FakeRuntime is a deterministic in-memory Runtime for tests. Containers are added with
+// AddContainer and can be configured to start slowly, fail or crash.
+type FakeRuntime struct {
+	mutex      sync.Mutex
+	containers map[string]*fakeContainer
+	removed    map[string]fakeContainer
+	services   map[string]*Service
+	listeners  []chan Event
+}
+
+// fakeContainer is a container held by FakeRuntime.
+type fakeContainer struct {
+	Container
+	stats      Stats
+	startDelay time.Duration
+	startErr   error
+	stopErr    error
+	starts     int
+	stops      int
+}
+
+// NewFakeRuntime creates an empty FakeRuntime.
+func NewFakeRuntime() *FakeRuntime {
+	return &FakeRuntime{
+		containers: make(map[string]*fakeContainer),
+		removed:    make(map[string]fakeContainer),
+		services:   make(map[string]*Service),
+	}
+}
+
+// AddContainer registers a container in the given state and returns its ID.
+func (fr *FakeRuntime) AddContainer(name, state string) string {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	id := fr.newID()
+	fr.containers[id] = &fakeContainer{Container: Container{ID: id, Name: name, State: state}}
+	return id
+}
+
+// AddService registers a replicated Swarm service with every desired task running and returns its ID.
+func (fr *FakeRuntime) AddService(name string, replicas uint64) string {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	id := fr.newID()
+	fr.services[id] = &Service{ID: id, Name: name, Replicas: replicas, RunningTasks: int(replicas)}
+	return id
+}
+
+// Replicas returns the desired number of tasks of a service.
+func (fr *FakeRuntime) Replicas(serviceID string) uint64 {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	if service, exists := fr.services[serviceID]; exists {
+		return service.Replicas
+	}
+	return 0
+}
+
+// SetLabels sets the labels reported for a container.
+func (fr *FakeRuntime) SetLabels(containerID string, labels map[string]string) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	if c, exists := fr.containers[containerID]; exists {
+		c.Labels = labels
+	}
+}
+
+// SetNetwork sets the IP address and exposed ports reported by Inspect for a container.
+func (fr *FakeRuntime) SetNetwork(containerID, network, ip string, ports ...int) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	if c, exists := fr.containers[containerID]; exists {
+		c.Networks = map[string]string{network: ip}
+		c.ExposedPorts = ports
+	}
+}
+
+// SetStartDelay makes Start block for the given duration before the container runs.
+func (fr *FakeRuntime) SetStartDelay(containerID string, delay time.Duration) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	if c, exists := fr.containers[containerID]; exists {
+		c.startDelay = delay
+	}
+}
+
+// SetStartError makes Start fail with err; nil restores the normal behaviour.
+func (fr *FakeRuntime) SetStartError(containerID string, err error) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	if c, exists := fr.containers[containerID]; exists {
+		c.startErr = err
+	}
+}
+
+// SetStopError makes Stop, Pause and Remove fail with err; nil restores the normal behaviour.
+func (fr *FakeRuntime) SetStopError(containerID string, err error) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	if c, exists := fr.containers[containerID]; exists {
+		c.stopErr = err
+	}
+}
+
+// SetStats sets the sample returned by Stats for a container.
+func (fr *FakeRuntime) SetStats(containerID string, stats Stats) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	if c, exists := fr.containers[containerID]; exists {
+		c.stats = stats
+	}
+}
+
+// Crash moves a running container to the exited state and emits a "die" event.
+func (fr *FakeRuntime) Crash(containerID string) {
+	fr.setState(containerID, "exited", "die")
+}
+
+// State returns the current state of a container, or an empty string if it does not exist.
+func (fr *FakeRuntime) State(containerID string) string {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	if c, exists := fr.containers[containerID]; exists {
+		return c.State
+	}
+	return ""
+}
+
+// Starts returns how many times Start was called successfully for a container.
+func (fr *FakeRuntime) Starts(containerID string) int {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	if c, exists := fr.containers[containerID]; exists {
+		return c.starts
+	}
+	return 0
+}
+
+// Stops returns how many times Stop was called successfully for a container.
+func (fr *FakeRuntime) Stops(containerID string) int {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	if c, exists := fr.containers[containerID]; exists {
+		return c.stops
+	}
+	return 0
+}
+
+func (fr *FakeRuntime) List(ctx context.Context) ([]Container, error) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	containers := make([]Container, 0, len(fr.containers))
+	for _, c := range fr.containers {
+		containers = append(containers, c.Container)
+	}
+
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].ID < containers[j].ID
+	})
+	return containers, nil
+}
+
+func (fr *FakeRuntime) Inspect(ctx context.Context, containerID string) (Container, error) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	c, exists := fr.containers[containerID]
+	if !exists {
+		return Container{}, notFound(containerID)
+	}
+	return c.Container, nil
+}
+
+func (fr *FakeRuntime) Start(ctx context.Context, containerID string) error {
+	fr.mutex.Lock()
+	c, exists := fr.containers[containerID]
+	if !exists {
+		fr.mutex.Unlock()
+		return notFound(containerID)
+	}
+	delay, err := c.startDelay, c.startErr
+	fr.mutex.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fr.mutex.Lock()
+	c.starts++
+	fr.mutex.Unlock()
+
+	fr.setState(containerID, "running", "start")
+	return nil
+}
+
+func (fr *FakeRuntime) Stop(ctx context.Context, containerID string) error {
+	fr.mutex.Lock()
+	c, exists := fr.containers[containerID]
+	var err error
+	if exists {
+		c.stops++
+		err = c.stopErr
+	}
+	fr.mutex.Unlock()
+
+	if !exists {
+		return notFound(containerID)
+	}
+	if err != nil {
+		return err
+	}
+
+	fr.setState(containerID, "exited", "stop")
+	return nil
+}
+
+func (fr *FakeRuntime) Pause(ctx context.Context, containerID string) error {
+	fr.mutex.Lock()
+	c, exists := fr.containers[containerID]
+	if exists && c.stopErr != nil {
+		fr.mutex.Unlock()
+		return c.stopErr
+	}
+	fr.mutex.Unlock()
+
+	return fr.transition(containerID, "running", "paused", "pause")
+}
+
+func (fr *FakeRuntime) Unpause(ctx context.Context, containerID string) error {
+	return fr.transition(containerID, "paused", "running", "unpause")
+}
+
+func (fr *FakeRuntime) Remove(ctx context.Context, containerID string) error {
+	fr.mutex.Lock()
+	c, exists := fr.containers[containerID]
+	if exists && c.stopErr != nil {
+		fr.mutex.Unlock()
+		return c.stopErr
+	}
+	if exists {
+		delete(fr.containers, containerID)
+		fr.removed[c.Name] = *c
+	}
+	fr.mutex.Unlock()
+
+	if !exists {
+		return notFound(containerID)
+	}
+
+	fr.emit(Event{ContainerID: containerID, Action: "destroy"})
+	return nil
+}
+
+func (fr *FakeRuntime) Recreate(ctx context.Context, containerName string) (string, error) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	c, exists := fr.removed[containerName]
+	if !exists {
+		return "", fmt.Errorf("no definition saved for container %s", containerName)
+	}
+	delete(fr.removed, containerName)
+
+	c.ID = fr.newID()
+	c.State = "created"
+	c.starts, c.stops = 0, 0
+	fr.containers[c.ID] = &c
+	return c.ID, nil
+}
+
+func (fr *FakeRuntime) Definition(containerName string) (string, error) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	c, exists := fr.removed[containerName]
+	if !exists {
+		return "", fmt.Errorf("no definition saved for container %s", containerName)
+	}
+
+	content, err := json.Marshal(c.Container)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (fr *FakeRuntime) KeepDefinition(containerName string, definition string) error {
+	var c fakeContainer
+	if err := json.Unmarshal([]byte(definition), &c.Container); err != nil {
+		return fmt.Errorf("invalid definition of container %s: %w", containerName, err)
+	}
+
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	fr.removed[containerName] = c
+	return nil
+}
+
+func (fr *FakeRuntime) Events(ctx context.Context) (<-chan Event, <-chan error) {
+	events := make(chan Event, 16)
+	errs := make(chan error)
+
+	fr.mutex.Lock()
+	fr.listeners = append(fr.listeners, events)
+	fr.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		fr.mutex.Lock()
+		defer fr.mutex.Unlock()
+		for i, listener := range fr.listeners {
+			if listener == events {
+				fr.listeners = append(fr.listeners[:i], fr.listeners[i+1:]...)
+				break
+			}
+		}
+		close(events)
+	}()
+
+	return events, errs
+}
+
+func (fr *FakeRuntime) Stats(ctx context.Context, containerID string) (Stats, error) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	c, exists := fr.containers[containerID]
+	if !exists {
+		return Stats{}, notFound(containerID)
+	}
+	return c.stats, nil
+}
+
+func (fr *FakeRuntime) ListServices(ctx context.Context) ([]Service, error) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	services := make([]Service, 0, len(fr.services))
+	for _, service := range fr.services {
+		services = append(services, *service)
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ID < services[j].ID
+	})
+	return services, nil
+}
+
+// ScaleService sets the desired replicas of a service; its tasks run immediately.
+func (fr *FakeRuntime) ScaleService(ctx context.Context, serviceID string, replicas uint64) error {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	service, exists := fr.services[serviceID]
+	if !exists {
+		return fmt.Errorf("no such service: %s", serviceID)
+	}
+	service.Replicas = replicas
+	service.RunningTasks = int(replicas)
+	return nil
+}
+
+// transition moves a container from one state to another, failing if it is in any other state.
+func (fr *FakeRuntime) transition(containerID, from, to, action string) error {
+	fr.mutex.Lock()
+	c, exists := fr.containers[containerID]
+	if !exists {
+		fr.mutex.Unlock()
+		return notFound(containerID)
+	}
+	if c.State != from {
+		fr.mutex.Unlock()
+		return fmt.Errorf("container %s is %s, not %s", containerID, c.State, from)
+	}
+	fr.mutex.Unlock()
+
+	fr.setState(containerID, to, action)
+	return nil
+}
+
+// setState changes the state of a container and emits the matching event.
+func (fr *FakeRuntime) setState(containerID, state, action string) {
+	fr.mutex.Lock()
+	c, exists := fr.containers[containerID]
+	if exists {
+		c.State = state
+	}
+	fr.mutex.Unlock()
+
+	if exists {
+		fr.emit(Event{ContainerID: containerID, Action: action})
+	}
+}
+
+// emit delivers an event to every listener without blocking on slow ones.
+func (fr *FakeRuntime) emit(event Event) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	for _, listener := range fr.listeners {
+		select {
+		case listener <- event:
+		default:
+		}
+	}
+}
+
+// newID returns a new container ID, unique among all fake runtimes.
+func (fr *FakeRuntime) newID() string {
+	return fmt.Sprintf("fake-%04d", fakeIDs.Add(1))
+}
+
+// notFound is the error returned for unknown containers.
+func notFound(containerID string) error {
+	return fmt.Errorf("no such container: %s", containerID)
+}
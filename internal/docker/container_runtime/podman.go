@@ -0,0 +1,309 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package container_runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	podmanAPIVersion    = "v4.0.0"
+	defaultPodmanSocket = "/run/podman/podman.sock"
+)
+
+var (
+	_ Runtime      = (*PodmanRuntime)(nil)
+	_ Checkpointer = (*PodmanRuntime)(nil)
+)
+
+// PodmanRuntime implements Runtime on top of the Podman libpod REST API.
+type PodmanRuntime struct {
+	client  *http.Client
+	baseURL string
+}
+
+// podmanContainer is a container as returned by the libpod list endpoint.
+type podmanContainer struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	State  string            `json:"State"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// podmanInspect is the subset of the libpod inspect response used by the gateway.
+type podmanInspect struct {
+	ID    string `json:"Id"`
+	Name  string `json:"Name"`
+	State struct {
+		Status string `json:"Status"`
+	} `json:"State"`
+	Config struct {
+		Labels       map[string]string   `json:"Labels"`
+		ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+	} `json:"Config"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// podmanEvent is an event as streamed by the libpod events endpoint.
+type podmanEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID string `json:"ID"`
+	} `json:"Actor"`
+}
+
+// podmanStats is the response of the libpod stats endpoint.
+type podmanStats struct {
+	Error interface{} `json:"Error"`
+	Stats []struct {
+		CPUNano    uint64 `json:"CPUNano"`
+		SystemNano uint64 `json:"SystemNano"`
+		MemUsage   uint64 `json:"MemUsage"`
+		NetInput   uint64 `json:"NetInput"`
+		NetOutput  uint64 `json:"NetOutput"`
+	} `json:"Stats"`
+}
+
+// NewPodmanRuntime creates a runtime talking to the Podman service at host, either a unix socket
+// ("unix:///run/podman/podman.sock" or a plain path) or a TCP address ("tcp://host:port").
+func NewPodmanRuntime(host string) (*PodmanRuntime, error) {
+	if host == "" {
+		host = defaultPodmanSocket
+	}
+
+	transport := &http.Transport{}
+	baseURL := "http://podman"
+
+	switch {
+	case strings.HasPrefix(host, "tcp://"):
+		baseURL = "http://" + strings.TrimPrefix(host, "tcp://")
+	case strings.HasPrefix(host, "unix://") || strings.HasPrefix(host, "/"):
+		socket := strings.TrimPrefix(host, "unix://")
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported podman host %q", host)
+	}
+
+	return &PodmanRuntime{
+		client:  &http.Client{Transport: transport},
+		baseURL: fmt.Sprintf("%s/%s/libpod", baseURL, podmanAPIVersion),
+	}, nil
+}
+
+func (pr *PodmanRuntime) List(ctx context.Context) ([]Container, error) {
+	var listed []podmanContainer
+	if err := pr.call(ctx, http.MethodGet, "/containers/json?all=true", &listed); err != nil {
+		return nil, err
+	}
+
+	containers := make([]Container, 0, len(listed))
+	for _, c := range listed {
+		name := ""
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		containers = append(containers, Container{ID: c.ID, Name: name, State: c.State, Labels: c.Labels})
+	}
+	return containers, nil
+}
+
+func (pr *PodmanRuntime) Inspect(ctx context.Context, containerID string) (Container, error) {
+	var inspect podmanInspect
+	if err := pr.call(ctx, http.MethodGet, "/containers/"+url.PathEscape(containerID)+"/json", &inspect); err != nil {
+		return Container{}, err
+	}
+
+	var ports []string
+	for port := range inspect.Config.ExposedPorts {
+		ports = append(ports, port)
+	}
+
+	networks := make(map[string]string)
+	for name, network := range inspect.NetworkSettings.Networks {
+		if network.IPAddress != "" {
+			networks[name] = network.IPAddress
+		}
+	}
+
+	return Container{
+		ID:           inspect.ID,
+		Name:         strings.TrimPrefix(inspect.Name, "/"),
+		State:        inspect.State.Status,
+		Labels:       inspect.Config.Labels,
+		Networks:     networks,
+		ExposedPorts: parseExposedPorts(ports),
+	}, nil
+}
+
+func (pr *PodmanRuntime) Start(ctx context.Context, containerID string) error {
+	return pr.containerAction(ctx, containerID, "start")
+}
+
+func (pr *PodmanRuntime) Stop(ctx context.Context, containerID string) error {
+	return pr.containerAction(ctx, containerID, "stop")
+}
+
+func (pr *PodmanRuntime) Pause(ctx context.Context, containerID string) error {
+	return pr.containerAction(ctx, containerID, "pause")
+}
+
+func (pr *PodmanRuntime) Unpause(ctx context.Context, containerID string) error {
+	return pr.containerAction(ctx, containerID, "unpause")
+}
+
+// Remove is not supported: libpod offers no way to create a container again from the
+// definition of a removed one.
+func (pr *PodmanRuntime) Remove(ctx context.Context, containerID string) error {
+	return ErrNotSupported
+}
+
+func (pr *PodmanRuntime) Recreate(ctx context.Context, containerName string) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (pr *PodmanRuntime) Events(ctx context.Context) (<-chan Event, <-chan error) {
+	result := make(chan Event)
+	errs := make(chan error, 1)
+
+	filters := url.QueryEscape(`{"type":["container"]}`)
+	resp, err := pr.request(ctx, http.MethodGet, "/events?stream=true&filters="+filters)
+	if err != nil {
+		errs <- err
+		close(result)
+		return result, errs
+	}
+
+	go func() {
+		defer close(result)
+		defer resp.Body.Close()
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var event podmanEvent
+			if err := decoder.Decode(&event); err != nil {
+				if ctx.Err() == nil {
+					errs <- err
+				}
+				return
+			}
+
+			select {
+			case result <- Event{ContainerID: event.Actor.ID, Action: event.Action}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return result, errs
+}
+
+func (pr *PodmanRuntime) Stats(ctx context.Context, containerID string) (Stats, error) {
+	var response podmanStats
+	path := "/containers/stats?stream=false&containers=" + url.QueryEscape(containerID)
+	if err := pr.call(ctx, http.MethodGet, path, &response); err != nil {
+		return Stats{}, err
+	}
+
+	if response.Error != nil {
+		return Stats{}, fmt.Errorf("podman stats: %v", response.Error)
+	}
+	if len(response.Stats) == 0 {
+		return Stats{}, fmt.Errorf("no stats returned for container %s", containerID)
+	}
+
+	sample := response.Stats[0]
+
+	// SystemNano is wall-clock time, so the CPU usage is already relative to a single CPU.
+	return Stats{
+		CPUTotal:     sample.CPUNano,
+		SystemTotal:  sample.SystemNano,
+		OnlineCPUs:   1,
+		NetworkBytes: sample.NetInput + sample.NetOutput,
+		MemoryBytes:  sample.MemUsage,
+	}, nil
+}
+
+// Checkpoint saves the memory of a container through CRIU and stops it.
+func (pr *PodmanRuntime) Checkpoint(ctx context.Context, containerID string) error {
+	return pr.containerAction(ctx, containerID, "checkpoint?keep=true")
+}
+
+// Restore starts a container from its checkpoint.
+func (pr *PodmanRuntime) Restore(ctx context.Context, containerID string) error {
+	return pr.containerAction(ctx, containerID, "restore?keep=true")
+}
+
+// containerAction calls a POST endpoint of a container that returns no body.
+func (pr *PodmanRuntime) containerAction(ctx context.Context, containerID, action string) error {
+	return pr.call(ctx, http.MethodPost, "/containers/"+url.PathEscape(containerID)+"/"+action, nil)
+}
+
+// call performs a request and decodes its JSON response into result, when not nil.
+func (pr *PodmanRuntime) call(ctx context.Context, method, path string, result interface{}) error {
+	resp, err := pr.request(ctx, method, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if result == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// request sends a request to the libpod API, turning error responses into errors.
+// A 304 response, returned when the container already is in the requested state, is a success.
+func (pr *PodmanRuntime) request(ctx context.Context, method, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, pr.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := pr.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+
+		var apiError struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiError)
+		return nil, fmt.Errorf("podman %s %s: %d %s", method, path, resp.StatusCode, apiError.Message)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package container_runtime
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNotSupported is returned by runtimes that cannot perform an operation.
+var ErrNotSupported = errors.New("operation not supported by the container runtime")
+
+// Container is a container as reported by the runtime.
+type Container struct {
+	ID     string
+	Name   string
+	State  string
+	Labels map[string]string
+
+	Networks     map[string]string // IP address of the container on each network; only set by Inspect
+	ExposedPorts []int             // TCP ports exposed by the container, in ascending order; only set by Inspect
+}
+
+// Stats is a sample of the resource counters of a container.
+type Stats struct {
+	CPUTotal     uint64 // CPU time consumed by the container
+	SystemTotal  uint64 // CPU time consumed by the host
+	OnlineCPUs   uint32 // Number of CPUs available to the container
+	NetworkBytes uint64 // Bytes received plus transmitted on every network
+	MemoryBytes  uint64 // Memory in use
+}
+
+// Event is a lifecycle change of a container.
+type Event struct {
+	ContainerID string
+	Action      string
+}
+
+// Runtime is the set of container operations the gateway relies on.
+type Runtime interface {
+	// List returns every container, including stopped ones.
+	List(ctx context.Context) ([]Container, error)
+	// Inspect returns the current state of a container.
+	Inspect(ctx context.Context, containerID string) (Container, error)
+	// Start starts a stopped container.
+	Start(ctx context.Context, containerID string) error
+	// Stop stops a running container.
+	Stop(ctx context.Context, containerID string) error
+	// Pause freezes a running container.
+	Pause(ctx context.Context, containerID string) error
+	// Unpause resumes a paused container.
+	Unpause(ctx context.Context, containerID string) error
+	// Remove removes a container, keeping its definition so Recreate can create it again.
+	Remove(ctx context.Context, containerID string) error
+	// Recreate creates a removed container again and returns its new ID.
+	Recreate(ctx context.Context, containerName string) (string, error)
+	// Events streams the lifecycle changes of the containers until the context is done.
+	Events(ctx context.Context) (<-chan Event, <-chan error)
+	// Stats returns a sample of the resource counters of a running container.
+	Stats(ctx context.Context, containerID string) (Stats, error)
+}
+
+// Checkpointer is implemented by runtimes able to checkpoint and restore containers.
+type Checkpointer interface {
+	// Checkpoint saves the memory of a container and stops it.
+	Checkpoint(ctx context.Context, containerID string) error
+	// Restore starts a container from its latest checkpoint.
+	Restore(ctx context.Context, containerID string) error
+}
+
+// DefinitionKeeper is implemented by runtimes able to export the definitions kept by Remove, so
+// removed containers can still be recreated after the gateway restarts.
+type DefinitionKeeper interface {
+	// Definition returns the definition kept for a removed container, encoded as JSON.
+	Definition(containerName string) (string, error)
+	// KeepDefinition restores a definition returned by Definition, typically by a previous run.
+	KeepDefinition(containerName string, definition string) error
+}
+
+// Service is a replicated Swarm service as reported by the runtime.
+type Service struct {
+	ID           string
+	Name         string
+	Replicas     uint64 // Desired number of tasks
+	RunningTasks int    // Tasks of the desired state that are running
+}
+
+// ServiceScaler is implemented by runtimes able to scale Swarm services.
+type ServiceScaler interface {
+	// ListServices returns every service with the state of its tasks.
+	ListServices(ctx context.Context) ([]Service, error)
+	// ScaleService sets the desired number of tasks of a replicated service.
+	ScaleService(ctx context.Context, serviceID string, replicas uint64) error
+}
+
+// parseExposedPorts returns the TCP ports of a set of "port/protocol" keys, in ascending order.
+func parseExposedPorts(keys []string) []int {
+	var ports []int
+	for _, key := range keys {
+		number, protocol, _ := strings.Cut(key, "/")
+		if protocol != "" && protocol != "tcp" {
+			continue
+		}
+		if port, err := strconv.Atoi(number); err == nil {
+			ports = append(ports, port)
+		}
+	}
+
+	sort.Ints(ports)
+	return ports
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package container_runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"time"
+)
+
+// sshDialer returns a dial function that reaches the Docker daemon behind an ssh:// URL. Each
+// connection runs "docker system dial-stdio" on the remote host through the local ssh client,
+// so the keys and settings of ~/.ssh apply.
+func sshDialer(host string) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	parsed, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Hostname() == "" {
+		return nil, fmt.Errorf("invalid ssh host %q", host)
+	}
+
+	args := []string{"-o", "BatchMode=yes"}
+	if parsed.User != nil {
+		args = append(args, "-l", parsed.User.Username())
+	}
+	if parsed.Port() != "" {
+		args = append(args, "-p", parsed.Port())
+	}
+	args = append(args, "--", parsed.Hostname(), "docker", "system", "dial-stdio")
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		cmd := exec.Command("ssh", args...)
+
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+
+		return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout, remote: parsed.Host}, nil
+	}, nil
+}
+
+// commandConn is a net.Conn over the standard input and output of a command.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	remote string
+}
+
+func (cc *commandConn) Read(p []byte) (int, error) {
+	return cc.stdout.Read(p)
+}
+
+func (cc *commandConn) Write(p []byte) (int, error) {
+	return cc.stdin.Write(p)
+}
+
+// Close ends the command, which closes the connection on the remote side.
+func (cc *commandConn) Close() error {
+	cc.stdin.Close()
+	if cc.cmd.Process != nil {
+		cc.cmd.Process.Kill()
+	}
+	cc.cmd.Wait()
+	return nil
+}
+
+func (cc *commandConn) LocalAddr() net.Addr {
+	return commandAddr("ssh")
+}
+
+func (cc *commandConn) RemoteAddr() net.Addr {
+	return commandAddr(cc.remote)
+}
+
+// Deadlines are not supported by pipes; timeouts are enforced by the request contexts.
+func (cc *commandConn) SetDeadline(t time.Time) error      { return nil }
+func (cc *commandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (cc *commandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// commandAddr is the net.Addr of a commandConn.
+type commandAddr string
+
+func (ca commandAddr) Network() string { return "ssh" }
+func (ca commandAddr) String() string  { return string(ca) }
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package container_runtime
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+var _ ServiceScaler = (*DockerRuntime)(nil)
+
+// ListServices returns the services of the Swarm, counting their running tasks. Tasks only reach
+// the running state once the container health check, when the image defines one, passes.
+func (dr *DockerRuntime) ListServices(ctx context.Context) ([]Service, error) {
+	services, err := dr.client.ServiceList(ctx, swarm.ServiceListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := dr.client.TaskList(ctx, swarm.TaskListOptions{
+		Filters: filters.NewArgs(filters.Arg("desired-state", string(swarm.TaskStateRunning))),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	running := make(map[string]int)
+	for _, task := range tasks {
+		if task.Status.State == swarm.TaskStateRunning {
+			running[task.ServiceID]++
+		}
+	}
+
+	result := make([]Service, 0, len(services))
+	for _, service := range services {
+		var replicas uint64
+		if service.Spec.Mode.Replicated != nil && service.Spec.Mode.Replicated.Replicas != nil {
+			replicas = *service.Spec.Mode.Replicated.Replicas
+		}
+
+		result = append(result, Service{
+			ID:           service.ID,
+			Name:         service.Spec.Name,
+			Replicas:     replicas,
+			RunningTasks: running[service.ID],
+		})
+	}
+	return result, nil
+}
+
+// ScaleService updates the replica count of a replicated service.
+func (dr *DockerRuntime) ScaleService(ctx context.Context, serviceID string, replicas uint64) error {
+	service, _, err := dr.client.ServiceInspectWithRaw(ctx, serviceID, swarm.ServiceInspectOptions{})
+	if err != nil {
+		return err
+	}
+	if service.Spec.Mode.Replicated == nil {
+		return fmt.Errorf("service %s is not in replicated mode", service.Spec.Name)
+	}
+
+	service.Spec.Mode.Replicated.Replicas = &replicas
+
+	response, err := dr.client.ServiceUpdate(ctx, service.ID, service.Version, service.Spec, swarm.ServiceUpdateOptions{})
+	if err != nil {
+		return err
+	}
+	for _, warning := range response.Warnings {
+		log.Printf("Warning scaling service %s: %s", service.Spec.Name, warning)
+	}
+	return nil
+}
@@ -4,46 +4,84 @@ import (
 	"time"
 )
 
-var containers = make(map[string]Container)
-var containersBySvc = make(map[string]Container)
+// defaultStore is the store shared by the gateway, used by the package-level functions.
+var defaultStore = NewContainerStore()
+
+// Default returns the store shared by the gateway.
+func Default() *ContainerStore {
+	return defaultStore
+}
 
 func Add(container Container) {
-	containers[container.ID] = container
-	containersBySvc[container.ContainerName] = container
+	defaultStore.Add(container)
 }
+
 func Update(container Container) {
-	containers[container.ID] = container
-	containersBySvc[container.ContainerName] = container
+	defaultStore.Update(container)
 }
 
 func Remove(containerID string) {
-	if container, exists := containers[containerID]; exists {
-		delete(containersBySvc, container.ContainerName)
-		delete(containers, containerID)
-	}
+	defaultStore.Remove(containerID)
 }
 
 func GetByID(containerID string) (Container, bool) {
-	container, exists := containers[containerID]
-	return container, exists
+	return defaultStore.GetByID(containerID)
 }
 
-func GetByContainerName(serviceName string) (*Container, bool) {
-	container, exists := containersBySvc[serviceName]
-	if !exists {
-		return nil, false
-	}
-	return &container, true
+// GetByContainerName looks a container up by its config.ContainerKey.
+func GetByContainerName(containerKey string) (*Container, bool) {
+	return defaultStore.GetByContainerName(containerKey)
 }
 
 func UpdateAccessTime(containerID string) {
-	if container, exists := containers[containerID]; exists {
+	defaultStore.Modify(containerID, func(container *Container) {
 		container.LastAccess = time.Now()
-		containers[containerID] = container
-		containersBySvc[container.ContainerName] = container
-	}
+	})
+}
+
+// UpdateLastAccess moves the last access of a container forward to at, typically an access
+// served by another gateway instance. An earlier time is ignored.
+func UpdateLastAccess(containerID string, at time.Time) {
+	defaultStore.Modify(containerID, func(container *Container) {
+		if at.After(container.LastAccess) {
+			container.LastAccess = at
+		}
+	})
 }
 
+// GetAll returns a snapshot of every stored container, by ID.
 func GetAll() map[string]Container {
-	return containers
+	return defaultStore.Snapshot()
+}
+
+// Modify applies change to a stored container atomically; see ContainerStore.Modify.
+func Modify(containerID string, change func(container *Container)) (Container, bool) {
+	return defaultStore.Modify(containerID, change)
+}
+
+// Subscribe receives the changes of the shared store; see ContainerStore.Subscribe.
+func Subscribe() (<-chan Change, func()) {
+	return defaultStore.Subscribe()
+}
+
+// UpdateAddress sets the resolved backend address of a container; an empty address forces it to
+// be resolved again.
+func UpdateAddress(containerID, address string) {
+	defaultStore.Modify(containerID, func(container *Container) {
+		container.Address = address
+	})
+}
+
+// UpdatePinned pins or unpins a container.
+func UpdatePinned(containerID string, pinned bool) {
+	defaultStore.Modify(containerID, func(container *Container) {
+		container.Pinned = pinned
+	})
+}
+
+// UpdateCooldown sets until when start attempts of a container are refused.
+func UpdateCooldown(containerID string, until time.Time) {
+	defaultStore.Modify(containerID, func(container *Container) {
+		container.CooldownUntil = until
+	})
 }
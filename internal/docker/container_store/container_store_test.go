@@ -0,0 +1,96 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package container_store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotIsACopy(t *testing.T) {
+	store := NewContainerStore()
+	store.Add(Container{ID: "1", ContainerName: "app", State: StateRunning})
+
+	snapshot := store.Snapshot()
+	snapshot["1"] = Container{ID: "1", ContainerName: "app", State: StateExited}
+	delete(snapshot, "1")
+
+	if stored, _ := store.GetByID("1"); stored.State != StateRunning {
+		t.Errorf("stored state = %q after changing a snapshot, want running", stored.State)
+	}
+}
+
+func TestSubscribeReceivesChanges(t *testing.T) {
+	store := NewContainerStore()
+	changes, unsubscribe := store.Subscribe()
+
+	store.Add(Container{ID: "1", ContainerName: "app", State: StateExited})
+	store.Modify("1", func(container *Container) {
+		container.LastAccess = time.Now()
+	})
+	store.Modify("1", func(container *Container) {
+		container.State = StateRunning
+	})
+	store.Remove("1")
+	unsubscribe()
+
+	var kinds []string
+	for change := range changes {
+		kinds = append(kinds, change.Kind)
+		if change.Kind == ChangeUpdated && (change.Previous.State != StateExited || change.Container.State != StateRunning) {
+			t.Errorf("update = %+v, want exited to running", change)
+		}
+	}
+
+	want := []string{ChangeAdded, ChangeUpdated, ChangeRemoved}
+	if len(kinds) != len(want) {
+		t.Fatalf("changes = %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Errorf("changes = %v, want %v", kinds, want)
+		}
+	}
+}
+
+func TestSaveRewritesMovedOrDeletedFile(t *testing.T) {
+	store := NewContainerStore()
+	store.Add(Container{ID: "1", ContainerName: "app", State: StateRunning, LastAccess: time.Now()})
+	keys := map[string]bool{"app": true}
+
+	first := filepath.Join(t.TempDir(), "state.json")
+	if err := store.Save(first, keys); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	os.Remove(first)
+	if err := store.Save(first, keys); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if _, err := os.Stat(first); err != nil {
+		t.Errorf("deleted state file was not written again: %v", err)
+	}
+
+	second := filepath.Join(t.TempDir(), "state.json")
+	if err := store.Save(second, keys); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if _, err := os.Stat(second); err != nil {
+		t.Errorf("state file was not written to the new path: %v", err)
+	}
+}
@@ -15,7 +15,190 @@
  */
 package container_store
 
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Kinds of change notified to the subscribers of a ContainerStore.
+const (
+	ChangeAdded   = "added"
+	ChangeUpdated = "updated"
+	ChangeRemoved = "removed"
+)
+
+// subscriberBuffer is how many changes a subscriber may lag behind before changes are dropped.
+const subscriberBuffer = 64
+
+// Change is a change of a stored container. Changes that only move its last access are not notified.
+type Change struct {
+	Kind      string
+	Container Container // The container after the change, or the removed container
+	Previous  Container // The container before an update
+}
+
+// ContainerStore holds the known containers. It is safe for concurrent use: every read returns
+// a copy, and changes are applied atomically.
 type ContainerStore struct {
+	mutex           sync.RWMutex
 	containers      map[string]Container // Map com ID como chave
 	containersBySvc map[string]Container // Map com Service como chave
+
+	subscribers map[int]chan Change
+	nextID      int
+
+	restored      map[string]persistedContainer // Saved states waiting for their container to be discovered
+	lastSaved     []byte                        // Content of the last file loaded or saved
+	lastSavedPath string                        // Path of the last file loaded or saved
+}
+
+// NewContainerStore creates an empty ContainerStore.
+func NewContainerStore() *ContainerStore {
+	return &ContainerStore{
+		containers:      make(map[string]Container),
+		containersBySvc: make(map[string]Container),
+		subscribers:     make(map[int]chan Change),
+		restored:        make(map[string]persistedContainer),
+	}
+}
+
+// Add stores a container, replacing any container with the same ID.
+func (cs *ContainerStore) Add(container Container) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	previous, exists := cs.containers[container.ID]
+	cs.put(container)
+
+	if exists {
+		cs.notify(Change{Kind: ChangeUpdated, Container: container, Previous: previous})
+	} else {
+		cs.notify(Change{Kind: ChangeAdded, Container: container})
+	}
+}
+
+// Update replaces a stored container. Prefer Modify, which does not overwrite concurrent changes.
+func (cs *ContainerStore) Update(container Container) {
+	cs.Add(container)
+}
+
+// Remove drops a container.
+func (cs *ContainerStore) Remove(containerID string) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	container, exists := cs.containers[containerID]
+	if !exists {
+		return
+	}
+
+	delete(cs.containers, containerID)
+	if current, exists := cs.containersBySvc[container.Key()]; exists && current.ID == containerID {
+		delete(cs.containersBySvc, container.Key())
+	}
+	cs.notify(Change{Kind: ChangeRemoved, Container: container})
+}
+
+// GetByID looks a container up by its ID.
+func (cs *ContainerStore) GetByID(containerID string) (Container, bool) {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	container, exists := cs.containers[containerID]
+	return container, exists
+}
+
+// GetByContainerName looks a container up by its config.ContainerKey.
+func (cs *ContainerStore) GetByContainerName(containerKey string) (*Container, bool) {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	container, exists := cs.containersBySvc[containerKey]
+	if !exists {
+		return nil, false
+	}
+	return &container, true
+}
+
+// Snapshot returns a copy of every stored container, by ID.
+func (cs *ContainerStore) Snapshot() map[string]Container {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	snapshot := make(map[string]Container, len(cs.containers))
+	for id, container := range cs.containers {
+		snapshot[id] = container
+	}
+	return snapshot
+}
+
+// Modify applies change to a stored container atomically and returns the result. It reports
+// false when the container is not stored.
+func (cs *ContainerStore) Modify(containerID string, change func(container *Container)) (Container, bool) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	previous, exists := cs.containers[containerID]
+	if !exists {
+		return Container{}, false
+	}
+
+	container := previous
+	change(&container)
+	container.ID = previous.ID
+	cs.put(container)
+
+	if withoutAccess(container) != withoutAccess(previous) {
+		cs.notify(Change{Kind: ChangeUpdated, Container: container, Previous: previous})
+	}
+	return container, true
+}
+
+// Subscribe returns a channel receiving every change of the store, and the function ending the
+// subscription. Changes are dropped, and logged, while the subscriber lags too far behind.
+func (cs *ContainerStore) Subscribe() (<-chan Change, func()) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	id := cs.nextID
+	cs.nextID++
+	changes := make(chan Change, subscriberBuffer)
+	cs.subscribers[id] = changes
+
+	return changes, func() {
+		cs.mutex.Lock()
+		defer cs.mutex.Unlock()
+
+		if _, exists := cs.subscribers[id]; exists {
+			delete(cs.subscribers, id)
+			close(changes)
+		}
+	}
+}
+
+// put indexes a container by ID and key. The caller holds the lock.
+func (cs *ContainerStore) put(container Container) {
+	if previous, exists := cs.containers[container.ID]; exists && previous.Key() != container.Key() {
+		delete(cs.containersBySvc, previous.Key())
+	}
+	cs.containers[container.ID] = container
+	cs.containersBySvc[container.Key()] = container
+}
+
+// notify sends a change to every subscriber without blocking. The caller holds the lock.
+func (cs *ContainerStore) notify(change Change) {
+	for _, subscriber := range cs.subscribers {
+		select {
+		case subscriber <- change:
+		default:
+			log.Printf("Dropping %s change of container %s: subscriber is lagging behind", change.Kind, change.Container.Key())
+		}
+	}
+}
+
+// withoutAccess returns the container with its last access cleared, to compare the rest of it.
+func withoutAccess(container Container) Container {
+	container.LastAccess = time.Time{}
+	return container
 }
@@ -15,11 +15,44 @@
  */
 package container_store
 
-import "time"
+import (
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+// Container states as reported by the Docker daemon. StateRemoved is only set by
+// the gateway for containers it removed on idle and is able to recreate.
+const (
+	StateCreated = "created"
+	StateRunning = "running"
+	StatePaused  = "paused"
+	StateExited  = "exited"
+	StateRemoved = "removed"
+)
+
+// Origins of a running container, as recorded in StartedBy.
+const (
+	StartedByGateway  = "gateway"
+	StartedByExternal = "external"
+)
 
 type Container struct {
 	ID            string
 	ContainerName string
+	Endpoint      string
+	Service       bool // Whether the entry is a Swarm service rather than a container
 	LastAccess    time.Time
 	IsActive      bool
+	State         string
+	Address       string    // Backend address resolved from the container network, cleared when it changes state
+	StartedBy     string    // Who last started the container (gateway or external)
+	Pinned        bool      // Whether the container is kept running regardless of its TTL
+	CooldownUntil time.Time // Start attempts are refused until then after a failed start
+	Definition    string    // JSON definition kept by the runtime for a removed container
+}
+
+// Key identifies the container across endpoints, matching config.ContainerKey.
+func (c Container) Key() string {
+	return config.ContainerKey(c.Endpoint, c.ContainerName)
 }
@@ -0,0 +1,210 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package container_store
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// persistedContainer is the state of a container kept across gateway restarts.
+type persistedContainer struct {
+	Key           string    `json:"key"`
+	LastAccess    time.Time `json:"lastAccess"`
+	StartedBy     string    `json:"startedBy,omitempty"`
+	Pinned        bool      `json:"pinned,omitempty"`
+	CooldownUntil time.Time `json:"cooldownUntil,omitempty"`
+
+	// Removed containers are absent from the runtime, so they are saved with what is needed to
+	// recreate them.
+	ID            string          `json:"id,omitempty"`
+	ContainerName string          `json:"containerName,omitempty"`
+	Endpoint      string          `json:"endpoint,omitempty"`
+	Definition    json.RawMessage `json:"definition,omitempty"`
+}
+
+// Load reads the state saved by Save into the shared store; see ContainerStore.Load.
+func Load(path string) error {
+	return defaultStore.Load(path)
+}
+
+// Restore applies the saved state of a newly discovered container; see ContainerStore.Restore.
+func Restore(container *Container) bool {
+	return defaultStore.Restore(container)
+}
+
+// Save writes the state of the shared store; see ContainerStore.Save.
+func Save(path string, keys map[string]bool) error {
+	return defaultStore.Save(path, keys)
+}
+
+// ResetState forgets the state loaded and saved by the shared store; see ContainerStore.ResetState.
+func ResetState() {
+	defaultStore.ResetState()
+}
+
+// Load reads the state saved by Save. It is applied by Restore to the containers as they are
+// discovered, while containers removed by the gateway are stored again right away, since the
+// runtime no longer lists them. A missing file is not an error.
+func (cs *ContainerStore) Load(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var entries []persistedContainer
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return err
+	}
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	for _, entry := range entries {
+		if entry.Definition == nil {
+			cs.restored[entry.Key] = entry
+			continue
+		}
+		container := Container{
+			ID:            entry.ID,
+			ContainerName: entry.ContainerName,
+			Endpoint:      entry.Endpoint,
+			LastAccess:    entry.LastAccess,
+			State:         StateRemoved,
+			Pinned:        entry.Pinned,
+			CooldownUntil: entry.CooldownUntil,
+			Definition:    string(entry.Definition),
+		}
+		cs.put(container)
+		cs.notify(Change{Kind: ChangeAdded, Container: container})
+	}
+	cs.lastSaved = content
+	cs.lastSavedPath = path
+	return nil
+}
+
+// ResetState forgets the saved states waiting for their container and the last content saved,
+// so the next Save writes the file whatever it holds. It is used by tests simulating restarts.
+func (cs *ContainerStore) ResetState() {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	cs.restored = make(map[string]persistedContainer)
+	cs.lastSaved = nil
+	cs.lastSavedPath = ""
+}
+
+// Restore applies the saved state of a newly discovered container, if any. It reports whether
+// a saved state was found.
+func (cs *ContainerStore) Restore(container *Container) bool {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	entry, exists := cs.restored[container.Key()]
+	if !exists {
+		return false
+	}
+	delete(cs.restored, container.Key())
+
+	container.LastAccess = entry.LastAccess
+	container.StartedBy = entry.StartedBy
+	container.Pinned = entry.Pinned
+	container.CooldownUntil = entry.CooldownUntil
+	return true
+}
+
+// Save writes the state of the containers whose key is in keys. States loaded but not restored
+// yet are kept, so containers absent during a restart are not forgotten. The file is only
+// rewritten when its content changes, or when it was moved or deleted since the last save.
+func (cs *ContainerStore) Save(path string, keys map[string]bool) error {
+	// Saves are serialized: the state is read and the file replaced under the lock.
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	byKey := make(map[string]persistedContainer)
+	for key, entry := range cs.restored {
+		if keys[key] {
+			byKey[key] = entry
+		}
+	}
+	for _, container := range cs.containers {
+		if !keys[container.Key()] {
+			continue
+		}
+		entry := persistedContainer{
+			Key:           container.Key(),
+			LastAccess:    container.LastAccess,
+			StartedBy:     container.StartedBy,
+			Pinned:        container.Pinned,
+			CooldownUntil: container.CooldownUntil,
+		}
+		if container.State == StateRemoved && container.Definition != "" {
+			entry.ID = container.ID
+			entry.ContainerName = container.ContainerName
+			entry.Endpoint = container.Endpoint
+			entry.Definition = json.RawMessage(container.Definition)
+		}
+		byKey[container.Key()] = entry
+	}
+
+	entries := make([]persistedContainer, 0, len(byKey))
+	for _, entry := range byKey {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if path == cs.lastSavedPath && bytes.Equal(content, cs.lastSaved) {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated state behind.
+	temporary, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+
+	if _, err := temporary.Write(content); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary.Name(), path); err != nil {
+		return err
+	}
+
+	cs.lastSaved = content
+	cs.lastSavedPath = path
+	return nil
+}
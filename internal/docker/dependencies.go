@@ -0,0 +1,206 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/events"
+)
+
+var (
+	starting      = make(map[string]int) // Cold starts in progress, by container key
+	startingGuard = &sync.Mutex{}
+)
+
+// beginStart records a cold start of the container identified by key, from the start of its
+// dependencies until it is running.
+func beginStart(key string) {
+	startingGuard.Lock()
+	defer startingGuard.Unlock()
+
+	starting[key]++
+}
+
+// endStart records the end of a cold start recorded by beginStart.
+func endStart(key string) {
+	startingGuard.Lock()
+	defer startingGuard.Unlock()
+
+	if starting[key] <= 1 {
+		delete(starting, key)
+		return
+	}
+	starting[key]--
+}
+
+// isStarting reports whether a cold start of the container identified by key is in progress.
+func isStarting(key string) bool {
+	startingGuard.Lock()
+	defer startingGuard.Unlock()
+
+	return starting[key] > 0
+}
+
+// startDependencies starts the dependencies of a container in the order resolved at config load,
+// waiting for each one to be healthy before moving to the next.
+func startDependencies(ctx context.Context, rt container_runtime.Runtime, policy config.ContainerPolicy) error {
+	for _, dependency := range policy.DependsOn {
+		if err := startDependency(ctx, rt, policy.DependencyKey(dependency), dependency); err != nil {
+			return fmt.Errorf("dependency %s of %s: %s", dependency.ContainerName, policy.Key(), err.Error())
+		}
+	}
+	return nil
+}
+
+// startDependency starts a single dependency container, identified by key, unless it is already running.
+func startDependency(ctx context.Context, rt container_runtime.Runtime, key string, dependency config.Dependency) error {
+	serviceMutex := getMutexForService(key)
+	serviceMutex.Lock()
+	defer serviceMutex.Unlock()
+
+	stored, exists := container_store.GetByContainerName(key)
+	if !exists {
+		return fmt.Errorf("container not found")
+	}
+
+	if stored.IsActive {
+		return nil
+	}
+
+	log.Printf("Starting dependency container: %s", key)
+
+	var err error
+	if stored.State == container_store.StatePaused {
+		err = rt.Unpause(ctx, stored.ID)
+	} else {
+		err = rt.Start(ctx, stored.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !checkProbe(key, dependency.Probe) {
+		return fmt.Errorf("health check failed")
+	}
+
+	log.Printf("Dependency container %s is healthy", key)
+	markContainerRunning(stored.ID)
+
+	return nil
+}
+
+// stopUnusedDependencies stops dependency containers that no running backend or dependency needs
+// anymore. Dependencies that are also the backend of a route are left to that route's TTL.
+func stopUnusedDependencies(policies []config.ContainerPolicy) {
+	for _, policy := range policies {
+		dependencies := policy.DependsOn
+
+		// Walk in reverse order so dependants are stopped before the containers they rely on.
+		for i := len(dependencies) - 1; i >= 0; i-- {
+			name := policy.DependencyKey(dependencies[i])
+
+			if isRouteBackend(name, policies) || hasRunningDependants(name, policies) {
+				continue
+			}
+
+			stopUnusedDependency(name, policies)
+		}
+	}
+}
+
+// stopUnusedDependency stops a dependency container unless it became needed while the monitor
+// was deciding. The container's mutex is held throughout, so a concurrent startDependency either
+// sees the container stopped or keeps it running.
+func stopUnusedDependency(key string, policies []config.ContainerPolicy) {
+	serviceMutex := getMutexForService(key)
+	serviceMutex.Lock()
+	defer serviceMutex.Unlock()
+
+	stored, exists := container_store.GetByContainerName(key)
+	if !exists || !stored.IsActive || hasRunningDependants(key, policies) {
+		return
+	}
+
+	rt, err := getContainerRuntime(stored.ID)
+	if err != nil {
+		log.Printf("Error creating container runtime: %v", err)
+		return
+	}
+
+	log.Printf("Dependency container %s is no longer needed", key)
+	if err := stopLockedContainer(context.Background(), rt, stored.ID, key); err != nil {
+		publishStopFailure(key, err)
+		return
+	}
+
+	container_store.Modify(stored.ID, func(stored *container_store.Container) {
+		stored.IsActive = false
+		stored.State = container_store.StateExited
+	})
+
+	event := containerEvent(events.ContainerStopped, key)
+	event.State = container_store.StateExited
+	event.Reason = "dependency no longer needed"
+	events.Publish(event)
+}
+
+// hasRunningDependants reports whether a backend or dependency that is running, starting or serving
+// requests still needs the container identified by key.
+func hasRunningDependants(key string, policies []config.ContainerPolicy) bool {
+	for _, policy := range policies {
+		for _, dependency := range policy.DependsOn {
+			if policy.DependencyKey(dependency) == key && isContainerNeeded(policy.Key()) {
+				return true
+			}
+
+			for _, required := range dependency.DependsOn {
+				if config.ContainerKey(policy.Endpoint, required) == key && isContainerNeeded(policy.DependencyKey(dependency)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isRouteBackend reports whether the container identified by key is the backend of any route.
+func isRouteBackend(key string, policies []config.ContainerPolicy) bool {
+	for _, policy := range policies {
+		if policy.Key() == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isContainerNeeded reports whether the container identified by key is running, being started or
+// serving requests.
+func isContainerNeeded(key string) bool {
+	return isContainerRunning(key) || isStarting(key) || inFlightRequests(key) > 0
+}
+
+// isContainerRunning reports whether the store knows the container identified by key as running.
+func isContainerRunning(key string) bool {
+	stored, exists := container_store.GetByContainerName(key)
+	return exists && stored.IsActive
+}
@@ -20,28 +20,24 @@ import (
 	"errors"
 	"fmt"
 	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
 	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/events"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/metrics"
 	"log"
 	"sync"
-
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
+	"time"
 )
 
 var (
 	mutexes      = make(map[string]*sync.Mutex)
 	mutexesGuard = &sync.Mutex{} // Guard para proteger o acesso ao mapa de mutexes
-	once         sync.Once
 )
 
-// getDockerClient garante que apenas uma instância do cliente Docker seja criada (singleton).
-func getDockerClient() (*client.Client, error) {
-	var err error
-	once.Do(func() {
-		dockerClientInstance, err = client.NewClientWithOpts(client.FromEnv)
-	})
-	return dockerClientInstance, err
-}
+// ErrStartTimeout is wrapped by the errors of starts whose container did not answer its liveness
+// probe, or was not started by the cluster leader, in time. A probe answering an error status is
+// not a timeout.
+var ErrStartTimeout = errors.New("timed out")
 
 // getMutexForService retorna o mutex associado a um serviço, criando um novo se necessário.
 func getMutexForService(service string) *sync.Mutex {
@@ -57,60 +53,177 @@ func getMutexForService(service string) *sync.Mutex {
 
 // StartContainer Funcionalidade de iniciar um container
 func StartContainer(route config.RouteConfig) (bool, error) {
-	if route.Backend.ContainerName == "" {
+	if !route.Backend.Managed() {
 		log.Println("No services associated with the route, ignoring container start.")
 		return true, nil
 	}
 
 	ctx := context.Background()
-	cli, err := getDockerClient()
+	key := route.Backend.ContainerKey()
+	rt, err := getRuntime(route.Backend.Endpoint)
 
 	if err != nil {
-		log.Printf("Error creating Docker client: %v", err)
+		log.Printf("Error creating container runtime: %v", err)
 		return false, err
 	}
 
-	log.Printf("Starting process for the service container: %s", route.Backend.ContainerName)
+	log.Printf("Starting process for the service container: %s", key)
+
+	if _, exists := container_store.GetByContainerName(key); !exists {
+		log.Printf("Unable to find service for container %s", key)
+		return false, fmt.Errorf("container %s not found", key)
+	}
 
-	containerService, exists := container_store.GetByContainerName(route.Backend.ContainerName)
+	if !isLeader() {
+		return forwardWake(route)
+	}
 
+	policy, exists := config.GetHostStore().GetContainerPolicy(key)
 	if !exists {
-		log.Printf("Unable to find service for container %s", route.Backend.ContainerName)
+		policy = config.NewRoutePolicy(route)
 	}
 
-	serviceMutex := getMutexForService(route.Backend.ContainerName)
+	// Keep the monitor from stopping the dependencies before the container is marked running.
+	beginStart(key)
+	defer endStart(key)
+
+	if err := startDependencies(ctx, rt, policy); err != nil {
+		log.Printf("Error starting dependencies for service %s: %v", key, err)
+		return false, err
+	}
+
+	serviceMutex := getMutexForService(key)
 	serviceMutex.Lock()
 	defer serviceMutex.Unlock()
 
-	log.Printf("Container for service %s is not running. Trying to start...", route.Backend.ContainerName)
-	if err := cli.ContainerStart(ctx, containerService.ID, container.StartOptions{}); err != nil {
-		log.Printf("Error starting container for service %s: %v", route.Backend.ContainerName, err)
+	// Another request may have started the container while this one waited for the mutex.
+	containerService, exists := container_store.GetByContainerName(key)
+	if !exists {
+		return false, fmt.Errorf("container %s not found", key)
+	}
+	if containerService.IsActive {
+		log.Printf("Container for service %s is already running.", key)
+		return true, nil
+	}
+	if err := checkCooldown(*containerService); err != nil {
+		event := routeEvent(events.ContainerStartFailed, route)
+		event.Reason = err.Error()
+		events.Publish(event)
+		return false, err
+	}
+	if err := checkColdStartLimit(policy); err != nil {
+		log.Printf("Refusing to start container %s: %v", key, err)
+		event := routeEvent(events.ContainerStartFailed, route)
+		event.Reason = err.Error()
+		events.Publish(event)
 		return false, err
 	}
 
-	log.Printf("Container started for service: %s", route.Backend.ContainerName)
+	reserveMemory(policy)
+
+	events.Publish(routeEvent(events.ContainerStarting, route))
+	startedAt := time.Now()
+
+	started, err := startStoredContainer(ctx, rt, policy, *containerService)
+	recordColdStart(key, startedAt, err)
+	if err != nil {
+		event := routeEvent(events.ContainerStartFailed, route)
+		event.Reason = err.Error()
+		event.DurationMs = time.Since(startedAt).Milliseconds()
+		events.Publish(event)
+		return started, err
+	}
+
+	event := routeEvent(events.ContainerStarted, route)
+	event.State = container_store.StateRunning
+	event.DurationMs = time.Since(startedAt).Milliseconds()
+	events.Publish(event)
+	return started, nil
+}
+
+// startStoredContainer brings a stopped, paused, removed or checkpointed container of a policy
+// back to running, or scales its Swarm service up. The caller holds the container's mutex.
+func startStoredContainer(ctx context.Context, rt container_runtime.Runtime, policy config.ContainerPolicy, containerService container_store.Container) (bool, error) {
+	key := policy.Key()
+
+	if policy.Service {
+		return startService(ctx, rt, policy, containerService)
+	}
+
+	if containerService.State == container_store.StatePaused {
+		log.Printf("Container for service %s is paused. Trying to unpause...", key)
+		if err := rt.Unpause(ctx, containerService.ID); err != nil {
+			log.Printf("Error unpausing container for service %s: %v", key, err)
+			return false, err
+		}
+
+		log.Printf("Container unpaused for service: %s", key)
+		markContainerRunning(containerService.ID)
+		return true, nil
+	}
+
+	containerID := containerService.ID
+
+	if containerService.State == container_store.StateRemoved {
+		log.Printf("Container for service %s was removed. Trying to recreate...", key)
+		var err error
+		containerID, err = recreateContainer(ctx, rt, containerService)
+		if err != nil {
+			log.Printf("Error recreating container for service %s: %v", key, err)
+			return false, err
+		}
+	}
+
+	startedAt := time.Now()
+
+	restored := policy.IdleAction == config.IdleActionCheckpoint && restoreContainer(ctx, rt, containerID)
+	if policy.IdleAction == config.IdleActionCheckpoint && !restored {
+		metrics.IncCounter(metricRestoreFailures, key)
+	}
+
+	if !restored {
+		log.Printf("Container for service %s is not running. Trying to start...", key)
+		if err := rt.Start(ctx, containerID); err != nil {
+			log.Printf("Error starting container for service %s: %v", key, err)
+			startFailed(containerID)
+			return false, err
+		}
+	}
+
+	log.Printf("Container started for service: %s", key)
+
+	// The container may have a new IP address after a start.
+	container_store.UpdateAddress(containerID, "")
 
 	// Verificar o healthcheck do container
-	if !checkHealth(route) {
-		log.Printf("Healthcheck failed for container %s", route.Backend.ContainerName)
-		return false, errors.New(fmt.Sprintf("Healthcheck failed for container %s", route.Backend.ContainerName))
+	if err := checkPolicyHealth(policy); err != nil {
+		log.Printf("Healthcheck failed for container %s: %v", key, err)
+		startFailed(containerID)
+		return false, fmt.Errorf("Healthcheck failed for container %s: %w", key, err)
 	}
 
-	log.Printf("Healthcheck successful for container: %s", route.Backend.ContainerName)
+	log.Printf("Healthcheck successful for container: %s", key)
+
+	if restored {
+		metrics.ObserveDuration(metricRestoreDuration, key, time.Since(startedAt))
+	} else {
+		metrics.ObserveDuration(metricColdStartDuration, key, time.Since(startedAt))
+	}
 
-	log.Printf("Last access to updated service container %s.", route.Backend.ContainerName)
-	container_store.UpdateAccessTime(containerService.ID)
+	log.Printf("Last access to updated service container %s.", key)
+	markContainerRunning(containerID)
 
 	return true, nil
 }
 
 // StopContainer Funcionalidade de parar um container
-func StopContainer(containerID string) {
+// It returns why the container could not be stopped.
+func StopContainer(containerID string) error {
 	ctx := context.Background()
-	cli, err := getDockerClient()
+	rt, err := getContainerRuntime(containerID)
 	if err != nil {
-		log.Printf("Error creating Docker client: %v", err)
-		return
+		log.Printf("Error creating container runtime: %v", err)
+		return err
 	}
 
 	log.Printf("Starting stop process for container: %s", containerID)
@@ -119,28 +232,174 @@ func StopContainer(containerID string) {
 	service := getServiceForContainer(containerID)
 	if service == "" {
 		log.Printf("Error finding the service associated with the container: %s", containerID)
-		return
+		return fmt.Errorf("no service is associated with container %s", containerID)
 	}
 
 	serviceMutex := getMutexForService(service)
 	serviceMutex.Lock()
 	defer serviceMutex.Unlock()
 
+	return stopLockedContainer(ctx, rt, containerID, service)
+}
+
+// stopLockedContainer stops a container through the runtime. The caller holds the mutex of its service.
+func stopLockedContainer(ctx context.Context, rt container_runtime.Runtime, containerID, service string) error {
 	log.Printf("Stopping container: %s of service: %s", containerID, service)
-	err = cli.ContainerStop(ctx, containerID, container.StopOptions{})
-	if err != nil {
+	if err := rt.Stop(ctx, containerID); err != nil {
 		log.Printf("Error stopping container %s: %v", containerID, err)
-	} else {
-		log.Printf("Container %s stopped successfully.", containerID)
+		return err
 	}
+
+	log.Printf("Container %s stopped successfully.", containerID)
+	return nil
+}
+
+// PauseContainer freezes a container through the runtime pause API and returns why it could not
+// be paused.
+func PauseContainer(containerID string) error {
+	ctx := context.Background()
+	rt, err := getContainerRuntime(containerID)
+	if err != nil {
+		log.Printf("Error creating container runtime: %v", err)
+		return err
+	}
+
+	service := getServiceForContainer(containerID)
+	if service == "" {
+		log.Printf("Error finding the service associated with the container: %s", containerID)
+		return fmt.Errorf("no service is associated with container %s", containerID)
+	}
+
+	serviceMutex := getMutexForService(service)
+	serviceMutex.Lock()
+	defer serviceMutex.Unlock()
+
+	log.Printf("Pausing container: %s of service: %s", containerID, service)
+	if err := rt.Pause(ctx, containerID); err != nil {
+		log.Printf("Error pausing container %s: %v", containerID, err)
+		return err
+	}
+
+	log.Printf("Container %s paused successfully.", containerID)
+	return nil
+}
+
+// RemoveContainer stops and removes a container, keeping its definition so it can be recreated on
+// demand. When the runtime cannot remove containers, the container is stopped instead. It returns
+// whether the container was removed, and why it could be neither removed nor stopped.
+func RemoveContainer(containerID string) (bool, error) {
+	ctx := context.Background()
+	rt, err := getContainerRuntime(containerID)
+	if err != nil {
+		log.Printf("Error creating container runtime: %v", err)
+		return false, err
+	}
+
+	service := getServiceForContainer(containerID)
+	if service == "" {
+		log.Printf("Error finding the service associated with the container: %s", containerID)
+		return false, fmt.Errorf("no service is associated with container %s", containerID)
+	}
+
+	serviceMutex := getMutexForService(service)
+	serviceMutex.Lock()
+	defer serviceMutex.Unlock()
+
+	log.Printf("Removing container: %s of service: %s", containerID, service)
+	err = rt.Remove(ctx, containerID)
+	if errors.Is(err, container_runtime.ErrNotSupported) {
+		log.Printf("Container runtime cannot remove containers, stopping %s instead.", containerID)
+		return false, stopLockedContainer(ctx, rt, containerID, service)
+	}
+	if err != nil {
+		log.Printf("Error removing container %s: %v", containerID, err)
+		return false, err
+	}
+
+	keepDefinition(rt, containerID)
+	log.Printf("Container %s removed successfully.", containerID)
+	return true, nil
+}
+
+// keepDefinition stores the definition the runtime kept for a removed container, so it is saved
+// with the container state and the container can still be recreated after a restart.
+func keepDefinition(rt container_runtime.Runtime, containerID string) {
+	keeper, ok := rt.(container_runtime.DefinitionKeeper)
+	if !ok {
+		return
+	}
+
+	stored, exists := container_store.GetByID(containerID)
+	if !exists {
+		return
+	}
+
+	definition, err := keeper.Definition(stored.ContainerName)
+	if err != nil {
+		if !errors.Is(err, container_runtime.ErrNotSupported) {
+			log.Printf("Error reading the definition of removed container %s: %v", stored.Key(), err)
+		}
+		return
+	}
+
+	container_store.Modify(containerID, func(stored *container_store.Container) {
+		stored.Definition = definition
+	})
 }
 
 // getServiceForContainer é um placeholder para obter o serviço associado ao containerID
 func getServiceForContainer(containerID string) string {
 	containerInStore, exists := container_store.GetByID(containerID)
 	if exists {
-		return containerInStore.ContainerName
+		return containerInStore.Key()
 	}
 	log.Printf("Unable to find service for container %s", containerID)
 	return ""
 }
+
+// getEndpointForContainer returns the endpoint running a stored container; the default endpoint
+// when the container is unknown.
+func getEndpointForContainer(containerID string) string {
+	containerInStore, _ := container_store.GetByID(containerID)
+	return containerInStore.Endpoint
+}
+
+// recreateContainer creates a removed container again through the runtime, replacing the old
+// entry in the store. It returns the ID of the new container.
+func recreateContainer(ctx context.Context, rt container_runtime.Runtime, stored container_store.Container) (string, error) {
+	// The runtime only remembers the containers it removed itself, not those of a previous run.
+	if keeper, ok := rt.(container_runtime.DefinitionKeeper); ok && stored.Definition != "" {
+		if err := keeper.KeepDefinition(stored.ContainerName, stored.Definition); err != nil {
+			return "", err
+		}
+	}
+
+	containerID, err := rt.Recreate(ctx, stored.ContainerName)
+	if err != nil {
+		return "", err
+	}
+
+	container_store.Remove(stored.ID)
+	container_store.Add(container_store.Container{
+		ID:            containerID,
+		ContainerName: stored.ContainerName,
+		Endpoint:      stored.Endpoint,
+		LastAccess:    stored.LastAccess,
+		State:         container_store.StateCreated,
+	})
+
+	log.Printf("Container %s recreated with ID %s", stored.Key(), containerID)
+
+	return containerID, nil
+}
+
+// markContainerRunning flags a container as running in the store and refreshes its last access.
+func markContainerRunning(containerID string) {
+	container_store.Modify(containerID, func(stored *container_store.Container) {
+		stored.IsActive = true
+		stored.State = container_store.StateRunning
+		stored.LastAccess = time.Now()
+		stored.StartedBy = container_store.StartedByGateway
+		stored.CooldownUntil = time.Time{}
+	})
+}
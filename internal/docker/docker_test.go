@@ -0,0 +1,366 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/ratelimit"
+)
+
+// setupFakeRuntime installs a fresh FakeRuntime and clears the container store and policies.
+func setupFakeRuntime(t *testing.T) *container_runtime.FakeRuntime {
+	t.Helper()
+
+	runtimesGuard.Lock()
+	runtimes = make(map[string]container_runtime.Runtime)
+	runtimesGuard.Unlock()
+
+	fake := container_runtime.NewFakeRuntime()
+	SetRuntime(fake)
+
+	for id := range container_store.GetAll() {
+		container_store.Remove(id)
+	}
+	container_store.ResetState()
+	config.GetHostStore().SetContainerPolicies(map[string]config.ContainerPolicy{})
+
+	return fake
+}
+
+// startHealthServer starts a backend answering its health check with the given status code.
+func startHealthServer(t *testing.T, status int) (string, int) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	return host, portNumber
+}
+
+// newTestRoute creates a route to a container with a single-attempt health check.
+func newTestRoute(containerName, host string, port int) config.RouteConfig {
+	return config.RouteConfig{
+		Path:       "/" + containerName,
+		IdleAction: config.IdleActionStop,
+		Backend: config.Backend{
+			Protocol:      "http",
+			Host:          host,
+			Port:          port,
+			ContainerName: containerName,
+		},
+		Retry:         config.RetryConfig{Attempts: 1, Period: 1},
+		LivenessProbe: config.LivenessProbeConfig{Path: "health"},
+	}
+}
+
+// registerPolicies sets the container policies built from the given routes.
+func registerPolicies(routes ...config.RouteConfig) {
+	policies := make(map[string]config.ContainerPolicy)
+	for _, route := range routes {
+		policies[route.Backend.ContainerKey()] = config.NewRoutePolicy(route)
+	}
+	config.GetHostStore().SetContainerPolicies(policies)
+}
+
+func TestStartContainerStartsStoppedContainer(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	host, port := startHealthServer(t, http.StatusOK)
+
+	id := fake.AddContainer("app", "exited")
+	syncContainersState()
+
+	route := newTestRoute("app", host, port)
+	registerPolicies(route)
+
+	if _, err := StartContainer(route); err != nil {
+		t.Fatalf("StartContainer returned error: %v", err)
+	}
+
+	if state := fake.State(id); state != "running" {
+		t.Errorf("runtime state = %q, want running", state)
+	}
+
+	stored, _ := container_store.GetByID(id)
+	if !stored.IsActive {
+		t.Errorf("stored container is not active after start")
+	}
+}
+
+func TestStartContainerUsesBackendEndpoint(t *testing.T) {
+	local := setupFakeRuntime(t)
+	remote := container_runtime.NewFakeRuntime()
+	SetEndpointRuntime("vm2", remote)
+	host, port := startHealthServer(t, http.StatusOK)
+
+	localID := local.AddContainer("app", "exited")
+	remoteID := remote.AddContainer("app", "exited")
+	syncContainersState()
+
+	route := newTestRoute("app", host, port)
+	route.Backend.Endpoint = "vm2"
+	registerPolicies(route)
+
+	if _, err := StartContainer(route); err != nil {
+		t.Fatalf("StartContainer returned error: %v", err)
+	}
+
+	if state := remote.State(remoteID); state != "running" {
+		t.Errorf("remote state = %q, want running", state)
+	}
+	if state := local.State(localID); state != "exited" {
+		t.Errorf("local state = %q, want exited", state)
+	}
+}
+
+func TestStartContainerConcurrentColdStartsStartOnce(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	host, port := startHealthServer(t, http.StatusOK)
+
+	id := fake.AddContainer("app", "exited")
+	fake.SetStartDelay(id, 50*time.Millisecond)
+	syncContainersState()
+
+	route := newTestRoute("app", host, port)
+	registerPolicies(route)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := StartContainer(route); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("StartContainer returned error: %v", err)
+	}
+
+	if starts := fake.Starts(id); starts != 1 {
+		t.Errorf("container started %d times, want 1", starts)
+	}
+}
+
+func TestColdStartsRaceWithMonitors(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	host, port := startHealthServer(t, http.StatusOK)
+
+	var routes []config.RouteConfig
+	for _, name := range []string{"app", "api", "worker"} {
+		id := fake.AddContainer(name, "exited")
+		fake.SetStartDelay(id, 20*time.Millisecond)
+
+		route := newTestRoute(name, host, port)
+		route.TTL = 60
+		routes = append(routes, route)
+	}
+	syncContainersState()
+	registerPolicies(routes...)
+
+	done := make(chan struct{})
+	var monitors sync.WaitGroup
+	monitors.Add(1)
+	go func() {
+		defer monitors.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				syncContainersState()
+				monitorAndStopContainers()
+				for _, stored := range container_store.GetAll() {
+					container_store.UpdateAccessTime(stored.ID)
+				}
+			}
+		}
+	}()
+
+	var starts sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		for _, route := range routes {
+			starts.Add(1)
+			go func(route config.RouteConfig) {
+				defer starts.Done()
+				if _, err := StartContainer(route); err != nil {
+					t.Errorf("StartContainer(%s) returned error: %v", route.Backend.ContainerName, err)
+				}
+			}(route)
+		}
+	}
+	starts.Wait()
+	close(done)
+	monitors.Wait()
+
+	for _, route := range routes {
+		stored, _ := container_store.GetByContainerName(route.Backend.ContainerKey())
+		if !stored.IsActive {
+			t.Errorf("%s is not active after its cold start", route.Backend.ContainerName)
+		}
+	}
+}
+
+func TestStartContainerFailedHealthCheck(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	host, port := startHealthServer(t, http.StatusInternalServerError)
+
+	id := fake.AddContainer("app", "exited")
+	syncContainersState()
+
+	route := newTestRoute("app", host, port)
+	registerPolicies(route)
+
+	if _, err := StartContainer(route); err == nil {
+		t.Fatal("StartContainer succeeded with a failing health check")
+	}
+
+	stored, _ := container_store.GetByID(id)
+	if stored.IsActive {
+		t.Errorf("stored container is active after a failed health check")
+	}
+}
+
+func TestStartContainerTimesOutOnlyWithoutAnswer(t *testing.T) {
+	// A port nothing listens on, so the liveness probe is never answered.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	tests := []struct {
+		name        string
+		status      int
+		wantTimeout bool
+	}{
+		{name: "probe answers an error", status: http.StatusInternalServerError, wantTimeout: false},
+		{name: "probe never answered", wantTimeout: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := setupFakeRuntime(t)
+			fake.AddContainer("app", "exited")
+			syncContainersState()
+
+			host, port := "127.0.0.1", closedPort
+			if test.status != 0 {
+				host, port = startHealthServer(t, test.status)
+			}
+			route := newTestRoute("app", host, port)
+			registerPolicies(route)
+
+			_, err := StartContainer(route)
+			if err == nil {
+				t.Fatal("StartContainer succeeded with a failing health check")
+			}
+			if timeout := errors.Is(err, ErrStartTimeout); timeout != test.wantTimeout {
+				t.Errorf("errors.Is(%v, ErrStartTimeout) = %t, want %t", err, timeout, test.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestStartContainerRuntimeError(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	host, port := startHealthServer(t, http.StatusOK)
+
+	id := fake.AddContainer("app", "exited")
+	fake.SetStartError(id, errors.New("boom"))
+	syncContainersState()
+
+	route := newTestRoute("app", host, port)
+	registerPolicies(route)
+
+	if _, err := StartContainer(route); err == nil {
+		t.Fatal("StartContainer succeeded although the runtime failed")
+	}
+}
+
+func TestStartContainerUnpausesWithoutHealthCheck(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	id := fake.AddContainer("app", "paused")
+	syncContainersState()
+
+	// Nothing listens on the backend address: a health check would fail.
+	route := newTestRoute("app", "127.0.0.1", 1)
+	route.IdleAction = config.IdleActionPause
+	registerPolicies(route)
+
+	if _, err := StartContainer(route); err != nil {
+		t.Fatalf("StartContainer returned error: %v", err)
+	}
+
+	if state := fake.State(id); state != "running" {
+		t.Errorf("runtime state = %q, want running", state)
+	}
+}
+
+func TestColdStartLimitRefusesStarts(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	host, port := startHealthServer(t, http.StatusOK)
+	ratelimit.SetStore(ratelimit.NewMemoryStore())
+
+	id := fake.AddContainer("app", "exited")
+	syncContainersState()
+
+	route := newTestRoute("app", host, port)
+	route.ColdStartLimit = config.ColdStartLimitConfig{Starts: 1, Period: 60}
+	registerPolicies(route)
+
+	if _, err := StartContainer(route); err != nil {
+		t.Fatalf("first StartContainer returned error: %v", err)
+	}
+
+	container_store.Modify(id, func(stored *container_store.Container) {
+		stored.IsActive = false
+		stored.State = container_store.StateExited
+	})
+
+	_, err := StartContainer(route)
+	var limitErr *ColdStartLimitError
+	if !errors.As(err, &limitErr) || limitErr.RetryAfter <= 0 {
+		t.Fatalf("second StartContainer returned %v, want a cold start limit error", err)
+	}
+	if starts := fake.Starts(id); starts != 1 {
+		t.Errorf("container started %d times, want 1", starts)
+	}
+}
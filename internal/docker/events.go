@@ -0,0 +1,71 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/events"
+)
+
+// storeEventTypes maps the kinds of container store changes to event types.
+var storeEventTypes = map[string]string{
+	container_store.ChangeAdded:   events.StoreAdded,
+	container_store.ChangeUpdated: events.StoreUpdated,
+	container_store.ChangeRemoved: events.StoreRemoved,
+}
+
+// publishStoreChanges publishes the changes of the container store on the event bus.
+func publishStoreChanges(changes <-chan container_store.Change) {
+	for change := range changes {
+		event := containerEvent(storeEventTypes[change.Kind], change.Container.Key())
+		event.State = change.Container.State
+		if change.Kind == container_store.ChangeUpdated && change.Previous.State != change.Container.State {
+			event.Reason = "was " + change.Previous.State
+		}
+		events.Publish(event)
+	}
+}
+
+// publishStopFailure publishes that the runtime failed to stop, pause or remove a container.
+func publishStopFailure(containerKey string, err error) {
+	event := containerEvent(events.ContainerStopFailed, containerKey)
+	event.Reason = err.Error()
+	events.Publish(event)
+}
+
+// routeEvent creates a lifecycle event of the container of a route.
+func routeEvent(eventType string, route config.RouteConfig) events.Event {
+	return events.Event{
+		Type:      eventType,
+		Container: route.Backend.ContainerKey(),
+		Host:      route.Host,
+		Route:     route.Path,
+	}
+}
+
+// containerEvent creates a lifecycle event of a container, attributed to its first route when it
+// is referenced by one.
+func containerEvent(eventType, containerKey string) events.Event {
+	policy, exists := config.GetHostStore().GetContainerPolicy(containerKey)
+	if !exists || len(policy.Routes) == 0 {
+		return events.Event{Type: eventType, Container: containerKey}
+	}
+
+	event := routeEvent(eventType, policy.Routes[0])
+	event.Container = containerKey
+	return event
+}
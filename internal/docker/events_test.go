@@ -0,0 +1,50 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/events"
+)
+
+func TestStartContainerPublishesLifecycleEvents(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	host, port := startHealthServer(t, http.StatusOK)
+
+	fake.AddContainer("app", "exited")
+	syncContainersState()
+
+	route := newTestRoute("app", host, port)
+	route.Host = "example.com"
+	registerPolicies(route)
+
+	published, unsubscribe := events.Subscribe(16)
+	defer unsubscribe()
+
+	if _, err := StartContainer(route); err != nil {
+		t.Fatalf("StartContainer returned error: %v", err)
+	}
+
+	starting, started := <-published, <-published
+	if starting.Type != events.ContainerStarting || started.Type != events.ContainerStarted {
+		t.Fatalf("events = %s, %s, want starting then started", starting.Type, started.Type)
+	}
+	if started.Container != "app" || started.Host != "example.com" || started.Route != "/app" || started.State != "running" {
+		t.Errorf("started event = %+v, want container app on example.com/app", started)
+	}
+}
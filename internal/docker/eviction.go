@@ -0,0 +1,242 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"bufio"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+const megabyte = 1024 * 1024
+
+var (
+	evicting      = make(map[string]bool) // Containers chosen for eviction and not stopped yet
+	evictionMutex sync.Mutex
+)
+
+// evictionCandidate is a running container that may be stopped early to free memory.
+type evictionCandidate struct {
+	container container_store.Container
+	policy    config.ContainerPolicy
+	memory    uint64
+}
+
+// evictUnderPressure stops least recently used containers while the host is short on memory
+// or the managed containers exceed the memory budget.
+func evictUnderPressure(now time.Time) {
+	gateway := config.GetGatewayConfig()
+	if gateway.MemoryBudgetMB <= 0 && gateway.MinAvailableMemoryPercent <= 0 {
+		return
+	}
+
+	tried := make(map[string]bool)
+	for {
+		candidate, found := nextEviction(gateway, "", 0, math.MaxInt, tried, now)
+		if !found {
+			return
+		}
+
+		log.Printf("Memory pressure: evicting container %s (priority %d, last access %s)",
+			candidate.policy.Key(), candidate.policy.Priority, candidate.container.LastAccess.Format(time.RFC3339))
+		evict(candidate, "host memory pressure")
+	}
+}
+
+// reserveMemory stops least recently used containers, with a priority not above the one of the
+// container about to start, until its memory estimate fits in the budget. The caller holds the
+// mutex of the container and has checked that it is not running.
+func reserveMemory(policy config.ContainerPolicy) {
+	gateway := config.GetGatewayConfig()
+	if gateway.MemoryBudgetMB <= 0 || policy.MemoryEstimateMB <= 0 || policy.Service {
+		return
+	}
+
+	required := uint64(policy.MemoryEstimateMB) * megabyte
+
+	tried := make(map[string]bool)
+	for {
+		candidate, found := nextEviction(gateway, policy.Key(), required, policy.Priority, tried, time.Now())
+		if !found {
+			break
+		}
+
+		log.Printf("Memory budget: evicting container %s to start %s", candidate.policy.Key(), policy.Key())
+		evict(candidate, "memory budget needed to start "+policy.Key())
+	}
+
+	if isUnderMemoryPressure(gateway, config.GetHostStore().ListContainerPolicies(), required) {
+		log.Printf("Memory budget of %d MB exceeded to start container %s", gateway.MemoryBudgetMB, policy.Key())
+	}
+}
+
+// nextEviction chooses the next container to evict while the memory pressure lasts, skipping the
+// tried ones, those above the given priority and those another eviction has chosen. Only the
+// choice is serialized: stopping a container waits for its mutex, which a cold start holds while
+// it reserves memory, so evictionMutex is never held during a stop.
+func nextEviction(gateway config.GatewayConfig, exclude string, required uint64, priority int, tried map[string]bool, now time.Time) (evictionCandidate, bool) {
+	evictionMutex.Lock()
+	defer evictionMutex.Unlock()
+
+	policies := config.GetHostStore().ListContainerPolicies()
+	if !isUnderMemoryPressure(gateway, policies, required) {
+		return evictionCandidate{}, false
+	}
+
+	for _, candidate := range evictionCandidates(policies, exclude, now) {
+		key := candidate.policy.Key()
+		if tried[key] || evicting[key] || candidate.policy.Priority > priority {
+			continue
+		}
+
+		tried[key] = true
+		evicting[key] = true
+		return candidate, true
+	}
+	return evictionCandidate{}, false
+}
+
+// evict stops a container chosen by nextEviction.
+func evict(candidate evictionCandidate, reason string) {
+	defer func() {
+		evictionMutex.Lock()
+		delete(evicting, candidate.policy.Key())
+		evictionMutex.Unlock()
+	}()
+
+	stopAndRemoveContainer(candidate.container, candidate.policy, reason)
+}
+
+// evictionCandidates lists the running containers that may be evicted, lowest priority and least
+// recently used first. Containers that must be kept warm, pinned ones and those a running dependant
+// needs are excluded, as are Swarm services, whose tasks do not run on the gateway host.
+func evictionCandidates(policies []config.ContainerPolicy, exclude string, now time.Time) []evictionCandidate {
+	var candidates []evictionCandidate
+
+	for _, policy := range policies {
+		if policy.Key() == exclude || policy.Service || policy.KeepWarm || policy.InWarmWindow(now) {
+			continue
+		}
+		if hasRunningDependants(policy.Key(), policies) || isContainerBusy(policy) {
+			continue
+		}
+
+		stored, exists := container_store.GetByContainerName(policy.Key())
+		if !exists || !stored.IsActive || stored.Pinned {
+			continue
+		}
+
+		candidates = append(candidates, evictionCandidate{
+			container: *stored,
+			policy:    policy,
+			memory:    containerMemory(policy),
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].policy.Priority != candidates[j].policy.Priority {
+			return candidates[i].policy.Priority < candidates[j].policy.Priority
+		}
+		return candidates[i].container.LastAccess.Before(candidates[j].container.LastAccess)
+	})
+
+	return candidates
+}
+
+// isUnderMemoryPressure reports whether the host is short on memory or the managed containers,
+// plus the additional memory required, exceed the budget.
+func isUnderMemoryPressure(gateway config.GatewayConfig, policies []config.ContainerPolicy, required uint64) bool {
+	if gateway.MemoryBudgetMB > 0 {
+		if usedContainerMemory(policies)+required > uint64(gateway.MemoryBudgetMB)*megabyte {
+			return true
+		}
+	}
+
+	if gateway.MinAvailableMemoryPercent > 0 {
+		available, total, err := readHostMemory()
+		if err != nil {
+			log.Printf("Error reading host memory: %v", err)
+			return false
+		}
+		if available < required || (available-required)*100 < total*uint64(gateway.MinAvailableMemoryPercent) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// usedContainerMemory sums the memory of every running container referenced by a route.
+func usedContainerMemory(policies []config.ContainerPolicy) uint64 {
+	var used uint64
+	for _, policy := range policies {
+		if !policy.Service && isContainerRunning(policy.Key()) {
+			used += containerMemory(policy)
+		}
+	}
+	return used
+}
+
+// containerMemory returns the memory used by a running container, or its estimate when
+// it has not been sampled yet.
+func containerMemory(policy config.ContainerPolicy) uint64 {
+	if current, exists := GetContainerStats(policy.Key()); exists && current.MemoryBytes > 0 {
+		return current.MemoryBytes
+	}
+	return uint64(policy.MemoryEstimateMB) * megabyte
+}
+
+// readHostMemory reads the available and total memory of the host from /proc/meminfo.
+func readHostMemory() (uint64, uint64, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var available, total uint64
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch fields[0] {
+		case "MemTotal:":
+			total = value * 1024
+		case "MemAvailable:":
+			available = value * 1024
+		}
+	}
+
+	return available, total, scanner.Err()
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+// setMemoryBudget sets the memory budget of the gateway until the test ends.
+func setMemoryBudget(t *testing.T, megabytes int) {
+	t.Helper()
+
+	previous := config.GetGatewayConfig()
+	t.Cleanup(func() { config.SetGatewayConfig(previous) })
+
+	gateway := previous
+	gateway.MemoryBudgetMB = megabytes
+	config.SetGatewayConfig(gateway)
+}
+
+// addIdleContainers adds running containers of 100 MB each, accessed the given time ago, with
+// the given priority, and returns their routes.
+func addIdleContainers(t *testing.T, fake *container_runtime.FakeRuntime, containers map[string]time.Duration, priorities map[string]int) []config.RouteConfig {
+	t.Helper()
+
+	var routes []config.RouteConfig
+	ids := make(map[string]string)
+	for name := range containers {
+		ids[name] = fake.AddContainer(name, "running")
+	}
+	syncContainersState()
+
+	for name, idle := range containers {
+		setLastAccess(t, ids[name], time.Now().Add(-idle))
+
+		route := newTestRoute(name, "127.0.0.1", 1)
+		route.TTL = 86400
+		route.MemoryEstimateMB = 100
+		route.Priority = priorities[name]
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// runningContainers returns the names of the running containers of the store, in name order.
+func runningContainers() string {
+	var names []string
+	for _, stored := range container_store.GetAll() {
+		if stored.IsActive {
+			names = append(names, stored.ContainerName)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func TestEvictionCandidatesOrder(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	routes := addIdleContainers(t, fake, map[string]time.Duration{
+		"recent":    time.Minute,
+		"old":       3 * time.Hour,
+		"important": 5 * time.Hour,
+		"warm":      9 * time.Hour,
+		"pinned":    9 * time.Hour,
+	}, map[string]int{"important": 1})
+
+	for i := range routes {
+		if routes[i].Backend.ContainerName == "warm" {
+			routes[i].KeepWarm = true
+		}
+	}
+	registerPolicies(routes...)
+
+	pinned, _ := container_store.GetByContainerName("pinned")
+	container_store.UpdatePinned(pinned.ID, true)
+
+	var order []string
+	for _, candidate := range evictionCandidates(config.GetHostStore().ListContainerPolicies(), "", time.Now()) {
+		order = append(order, candidate.policy.Key())
+	}
+
+	if got := strings.Join(order, ", "); got != "old, recent, important" {
+		t.Errorf("eviction order = %s, want old, recent, important", got)
+	}
+}
+
+func TestReserveMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	tests := []struct {
+		name       string
+		estimateMB int
+		priority   int
+		want       string // Containers still running afterwards
+	}{
+		{"one container", 100, 1, "important, recent"},
+		{"small start", 50, 0, "important, recent"},
+		{"several containers", 250, 1, "important"},
+		{"higher priority", 250, 5, ""},
+		{"fits", 0, 0, "important, old, recent"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := setupFakeRuntime(t)
+			setMemoryBudget(t, 300)
+
+			routes := addIdleContainers(t, fake, map[string]time.Duration{
+				"old":       2 * time.Hour,
+				"recent":    time.Hour,
+				"important": time.Minute,
+			}, map[string]int{"important": 5})
+
+			starting := newTestRoute("starting", "127.0.0.1", 1)
+			starting.MemoryEstimateMB = test.estimateMB
+			starting.Priority = test.priority
+			registerPolicies(append(routes, starting)...)
+
+			reserveMemory(config.NewRoutePolicy(starting))
+
+			if got := runningContainers(); got != test.want {
+				t.Errorf("running containers = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStartContainerOfRunningContainerEvictsNothing(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	setMemoryBudget(t, 100)
+	host, port := startHealthServer(t, http.StatusOK)
+
+	routes := addIdleContainers(t, fake, map[string]time.Duration{"old": time.Hour}, nil)
+	fake.AddContainer("app", "running")
+	syncContainersState()
+
+	app := newTestRoute("app", host, port)
+	app.MemoryEstimateMB = 100
+	registerPolicies(append(routes, app)...)
+
+	if _, err := StartContainer(app); err != nil {
+		t.Fatalf("StartContainer returned error: %v", err)
+	}
+	if got := runningContainers(); got != "app, old" {
+		t.Errorf("running containers = %q, want app, old", got)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"sync"
+	"time"
+)
+
+// coldStartHistory is how many recent cold starts are kept per container.
+const coldStartHistory = 20
+
+// ColdStart is the outcome of an attempt to start a stopped container.
+type ColdStart struct {
+	At         time.Time `json:"at"`
+	DurationMs int64     `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+}
+
+var (
+	coldStarts      = make(map[string][]ColdStart)
+	coldStartsGuard = &sync.Mutex{}
+)
+
+// recordColdStart keeps the outcome of a start attempt, dropping the oldest beyond the history size.
+func recordColdStart(containerKey string, startedAt time.Time, err error) {
+	coldStart := ColdStart{At: startedAt, DurationMs: time.Since(startedAt).Milliseconds()}
+	if err != nil {
+		coldStart.Error = err.Error()
+	}
+
+	coldStartsGuard.Lock()
+	defer coldStartsGuard.Unlock()
+
+	history := append(coldStarts[containerKey], coldStart)
+	if len(history) > coldStartHistory {
+		history = history[len(history)-coldStartHistory:]
+	}
+	coldStarts[containerKey] = history
+}
+
+// GetColdStarts returns the recent start attempts of a container, identified by its
+// config.ContainerKey, oldest first.
+func GetColdStarts(containerKey string) []ColdStart {
+	coldStartsGuard.Lock()
+	defer coldStartsGuard.Unlock()
+
+	return append([]ColdStart(nil), coldStarts[containerKey]...)
+}
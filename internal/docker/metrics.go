@@ -0,0 +1,63 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/metrics"
+)
+
+// Names of the metrics recorded by the container lifecycle.
+const (
+	metricColdStartDuration = "gateway_cold_start_seconds"
+	metricRestoreDuration   = "gateway_checkpoint_restore_seconds"
+	metricRestoreFailures   = "gateway_checkpoint_restore_failures_total"
+	metricCPUPercent        = "gateway_container_cpu_percent"
+	metricNetworkRate       = "gateway_container_network_bytes_per_second"
+	metricMemoryBytes       = "gateway_container_memory_bytes"
+	metricContainerActive   = "gateway_container_active"
+)
+
+func init() {
+	metrics.Describe(metricColdStartDuration, "Time to start a stopped container until its health check succeeds.")
+	metrics.Describe(metricRestoreDuration, "Time to restore a container from a checkpoint until its health check succeeds.")
+	metrics.Describe(metricRestoreFailures, "Checkpoint restores that failed and fell back to a cold start.")
+	metrics.Describe(metricCPUPercent, "CPU usage of a running container, in percent of one core.")
+	metrics.Describe(metricNetworkRate, "Network I/O of a running container, received plus transmitted.")
+	metrics.Describe(metricMemoryBytes, "Memory usage of a running container.")
+	metrics.Describe(metricContainerActive, "Whether a known container is running (1) or not (0).")
+}
+
+// recordContainerChanges keeps the container state metrics in line with the changes of the store.
+func recordContainerChanges(changes <-chan container_store.Change) {
+	for change := range changes {
+		key := change.Container.Key()
+
+		if change.Kind == container_store.ChangeRemoved {
+			// A recreated container is added under the same key before the old one is forgotten.
+			if _, exists := container_store.GetByContainerName(key); !exists {
+				metrics.DeleteGauge(metricContainerActive, key)
+			}
+			continue
+		}
+
+		active := 0.0
+		if change.Container.IsActive {
+			active = 1
+		}
+		metrics.SetGauge(metricContainerActive, key, active)
+	}
+}
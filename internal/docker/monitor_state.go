@@ -17,89 +17,162 @@ package docker
 
 import (
 	"context"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
 	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/events"
 	"log"
-	"strings"
 	"sync"
 	"time"
-
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
 )
 
 var (
 	updateContainerMutex sync.Mutex
-	dockerClientInstance *client.Client
 )
 
-// CheckContainersActive starts the continuous process of verifying the containers.
+// CheckContainersActive starts the continuous process of verifying the containers. Besides the
+// periodic synchronization, any container event reported by the runtime triggers one immediately.
 func CheckContainersActive() {
+	storeChanges, _ := container_store.Subscribe()
+	go recordContainerChanges(storeChanges)
+	busChanges, _ := container_store.Subscribe()
+	go publishStoreChanges(busChanges)
+
+	loadState()
+	syncContainersState()
+	WarmUpContainers()
+
+	changes := make(chan struct{}, 1)
+	for _, endpoint := range listEndpoints() {
+		go watchContainerEvents(context.Background(), endpoint, changes)
+	}
+
 	for {
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+		}
 		syncContainersState()
+	}
+}
+
+// watchContainerEvents notifies the changes channel whenever the runtime of an endpoint reports a
+// container event. The subscription is renewed if the event stream fails.
+func watchContainerEvents(ctx context.Context, endpoint string, changes chan<- struct{}) {
+	for ctx.Err() == nil {
+		rt, err := getRuntime(endpoint)
+		if err != nil {
+			log.Printf("Error obtaining container runtime of endpoint %q: %v", endpoint, err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		events, errs := rt.Events(ctx)
+
+	receive:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-events:
+				if !ok {
+					break receive
+				}
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			case err := <-errs:
+				log.Printf("Error watching container events of endpoint %q: %v", endpoint, err)
+				break receive
+			}
+		}
+
 		time.Sleep(5 * time.Second)
 	}
 }
 
-// syncContainersState is the main process that synchronizes the state of the containers.
+// syncContainersState is the main process that synchronizes the state of the containers of
+// every endpoint.
 func syncContainersState() {
 	updateContainerMutex.Lock()
 	defer updateContainerMutex.Unlock()
 
-	cli, err := getDockerClient()
+	for _, endpoint := range listEndpoints() {
+		syncEndpointState(endpoint)
+	}
+	saveState()
+}
+
+// syncEndpointState synchronizes the containers of one endpoint. When the endpoint cannot be
+// reached, its containers are kept in the store as they were.
+func syncEndpointState(endpoint string) {
+	rt, err := getRuntime(endpoint)
 	if err != nil {
-		log.Println("Error obtaining Docker client:", err)
+		log.Printf("Error obtaining container runtime of endpoint %q: %v", endpoint, err)
 		return
 	}
 
-	containers, err := listAllContainers(cli)
+	containers, err := rt.List(context.Background())
 	if err != nil {
-		log.Println("Error listing containers:", err)
+		log.Printf("Error listing containers of endpoint %q: %v", endpoint, err)
 		return
 	}
 
-	currentContainers := mapContainers(containers)
-	activeContainers := container_store.GetAll()
+	services, err := mapServices(endpoint, rt)
+	if err != nil {
+		log.Printf("Error listing services of endpoint %q: %v", endpoint, err)
+		return
+	}
+
+	currentContainers := mapContainers(endpoint, containers)
+	for id, service := range services {
+		currentContainers[id] = service
+	}
+	activeContainers := make(map[string]container_store.Container)
+	for id, stored := range container_store.GetAll() {
+		if stored.Endpoint == endpoint {
+			activeContainers[id] = stored
+		}
+	}
 
 	removeMissingContainers(activeContainers, currentContainers)
 	updateOrAddContainers(activeContainers, currentContainers)
 }
 
-// listAllContainers lists all containers, including stopped ones.
-func listAllContainers(cli *client.Client) ([]types.Container, error) {
-	ctx := context.Background()
-	return cli.ContainerList(ctx, container.ListOptions{All: true})
-}
-
 // mapContainers creates a map of the current containers with their relevant information.
-func mapContainers(containers []types.Container) map[string]container_store.Container {
+func mapContainers(endpoint string, containers []container_runtime.Container) map[string]container_store.Container {
 	currentContainers := make(map[string]container_store.Container)
 
 	for _, container := range containers {
-		for _, name := range container.Names {
-			newContainer := createContainerObject(container, name)
-			currentContainers[container.ID] = newContainer
-		}
+		currentContainers[container.ID] = createContainerObject(endpoint, container)
 	}
 	return currentContainers
 }
 
 // createContainerObject creates a Container instance based on the provided data.
-func createContainerObject(container types.Container, name string) container_store.Container {
+func createContainerObject(endpoint string, container container_runtime.Container) container_store.Container {
 	return container_store.Container{
 		ID:            container.ID,
-		ContainerName: strings.ReplaceAll(name, "/", ""),
+		ContainerName: container.Name,
+		Endpoint:      endpoint,
 		LastAccess:    time.Now(),
-		IsActive:      container.State == "running",
+		IsActive:      container.State == container_store.StateRunning,
+		State:         container.State,
+		StartedBy:     startedBy(container.State),
 	}
 }
 
 // removeMissingContainers removes containers that are no longer present on the host.
+// Containers removed by the gateway itself are kept so they can be recreated on demand.
 func removeMissingContainers(activeContainers, currentContainers map[string]container_store.Container) {
 	for containerID, storedContainer := range activeContainers {
+		if storedContainer.State == container_store.StateRemoved {
+			continue
+		}
+
 		if _, exists := currentContainers[containerID]; !exists {
 			container_store.Remove(containerID)
-			log.Printf("Removed container: %s (%s)", storedContainer.ContainerName, storedContainer.ID)
+			log.Printf("Removed container: %s (%s)", storedContainer.Key(), storedContainer.ID)
 		}
 	}
 }
@@ -115,20 +188,55 @@ func updateOrAddContainers(activeContainers, currentContainers map[string]contai
 	}
 }
 
-// updateContainerIfChanged updates a container if there is a change in its status.
+// updateContainerIfChanged updates a container if there is a change in its status. The update is
+// skipped when the stored status changed since it was read, for instance because a cold start
+// completed while the runtime was being listed; the next synchronization catches up.
 func updateContainerIfChanged(storedContainer, currentContainer container_store.Container) {
-	if storedContainer.IsActive != currentContainer.IsActive {
-		storedContainer.IsActive = currentContainer.IsActive
+	if storedContainer.IsActive == currentContainer.IsActive && storedContainer.State == currentContainer.State {
+		return
+	}
 
-		container_store.Update(storedContainer)
+	updated := false
+	container_store.Modify(storedContainer.ID, func(stored *container_store.Container) {
+		if stored.IsActive != storedContainer.IsActive || stored.State != storedContainer.State {
+			return
+		}
+		stored.IsActive = currentContainer.IsActive
+		stored.State = currentContainer.State
+		stored.Address = ""
+		updated = true
+	})
+
+	if updated {
+		log.Printf("Updated container: %s (%s) - IsActive: %v, State: %s",
+			storedContainer.Key(), storedContainer.ID, currentContainer.IsActive, currentContainer.State)
+
+		event := containerEvent(events.ContainerStateChanged, storedContainer.Key())
+		event.State = currentContainer.State
+		event.Reason = "was " + storedContainer.State
+		events.Publish(event)
+	}
+}
 
-		log.Printf("Updated container: %s (%s) - IsActive: %v",
-			storedContainer.ContainerName, storedContainer.ID, storedContainer.IsActive)
+// startedBy returns the origin of a container found by the monitor, which did not start it.
+func startedBy(state string) string {
+	if state == container_store.StateRunning {
+		return container_store.StartedByExternal
 	}
+	return ""
 }
 
-// addNewContainer adds a new container to the store.
+// addNewContainer adds a new container to the store, with the state saved by a previous run of
+// the gateway when there is one.
 func addNewContainer(currentContainer container_store.Container) {
+	// A container recreated outside the gateway replaces the definition kept for it.
+	if stale, exists := container_store.GetByContainerName(currentContainer.Key()); exists && stale.State == container_store.StateRemoved {
+		container_store.Remove(stale.ID)
+	}
+	if container_store.Restore(&currentContainer) {
+		log.Printf("Restored saved state of container %s, last access %s",
+			currentContainer.Key(), currentContainer.LastAccess.Format(time.RFC3339))
+	}
 	container_store.Add(currentContainer)
-	log.Printf("Added new container: %s (%s)", currentContainer.ContainerName, currentContainer.ID)
+	log.Printf("Added new container: %s (%s)", currentContainer.Key(), currentContainer.ID)
 }
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+func TestSyncContainersStateAddsContainers(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	runningID := fake.AddContainer("app", "running")
+	pausedID := fake.AddContainer("worker", "paused")
+
+	syncContainersState()
+
+	running, exists := container_store.GetByContainerName("app")
+	if !exists || running.ID != runningID || !running.IsActive {
+		t.Errorf("app = %+v, want active container %s", running, runningID)
+	}
+
+	paused, exists := container_store.GetByContainerName("worker")
+	if !exists || paused.ID != pausedID || paused.IsActive || paused.State != container_store.StatePaused {
+		t.Errorf("worker = %+v, want inactive paused container %s", paused, pausedID)
+	}
+}
+
+func TestSyncContainersStateDetectsCrash(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	id := fake.AddContainer("app", "running")
+	syncContainersState()
+
+	fake.Crash(id)
+	syncContainersState()
+
+	stored, _ := container_store.GetByID(id)
+	if stored.IsActive || stored.State != "exited" {
+		t.Errorf("stored container = %+v, want inactive and exited", stored)
+	}
+}
+
+func TestSyncContainersStateNamespacesEndpoints(t *testing.T) {
+	local := setupFakeRuntime(t)
+	remote := container_runtime.NewFakeRuntime()
+	SetEndpointRuntime("vm2", remote)
+
+	localID := local.AddContainer("app", "running")
+	remoteID := remote.AddContainer("app", "exited")
+
+	syncContainersState()
+
+	stored, exists := container_store.GetByContainerName("app")
+	if !exists || stored.ID != localID || stored.Endpoint != "" {
+		t.Errorf("app = %+v, want local container %s", stored, localID)
+	}
+
+	stored, exists = container_store.GetByContainerName("vm2/app")
+	if !exists || stored.ID != remoteID || stored.Endpoint != "vm2" || stored.IsActive {
+		t.Errorf("vm2/app = %+v, want stopped remote container %s", stored, remoteID)
+	}
+
+	remote.Remove(context.Background(), remoteID)
+	syncContainersState()
+
+	if _, exists := container_store.GetByContainerName("vm2/app"); exists {
+		t.Errorf("vm2/app still stored after it was removed from its endpoint")
+	}
+	if _, exists := container_store.GetByID(localID); !exists {
+		t.Errorf("local container was removed while syncing another endpoint")
+	}
+}
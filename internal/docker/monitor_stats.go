@@ -0,0 +1,191 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/metrics"
+)
+
+// ContainerStats is the resource usage of a container computed from its last two samples.
+type ContainerStats struct {
+	CPUPercent            float64   `json:"cpuPercent"`
+	NetworkBytesPerSecond float64   `json:"networkBytesPerSecond"`
+	MemoryBytes           uint64    `json:"memoryBytes"`
+	SampledAt             time.Time `json:"sampledAt"`
+}
+
+// statsSample is the raw counters read from the Docker stats API.
+type statsSample struct {
+	cpuTotal     uint64
+	systemTotal  uint64
+	onlineCPUs   uint32
+	networkBytes uint64
+	readAt       time.Time
+}
+
+var (
+	stats        = make(map[string]ContainerStats)
+	statsSamples = make(map[string]statsSample)
+	statsGuard   = &sync.Mutex{}
+)
+
+// CheckContainersStats starts the continuous process of sampling the resource usage of the
+// running containers referenced by a route.
+func CheckContainersStats() {
+	for {
+		collectContainersStats()
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// collectContainersStats samples every running container referenced by a route.
+func collectContainersStats() {
+	for _, policy := range config.GetHostStore().ListContainerPolicies() {
+		stored, exists := container_store.GetByContainerName(policy.Key())
+		if !exists || !stored.IsActive || stored.Service {
+			forgetContainerStats(policy.Key())
+			continue
+		}
+
+		rt, err := getRuntime(policy.Endpoint)
+		if err != nil {
+			log.Printf("Error obtaining container runtime of %s: %v", policy.Key(), err)
+			continue
+		}
+
+		sample, memory, err := readStatsSample(rt, stored.ID)
+		if err != nil {
+			log.Printf("Error reading stats of container %s: %v", policy.Key(), err)
+			continue
+		}
+
+		recordContainerStats(policy.Key(), sample, memory)
+	}
+}
+
+// readStatsSample reads the current resource counters of a container.
+func readStatsSample(rt container_runtime.Runtime, containerID string) (statsSample, uint64, error) {
+	current, err := rt.Stats(context.Background(), containerID)
+	if err != nil {
+		return statsSample{}, 0, err
+	}
+
+	sample := statsSample{
+		cpuTotal:     current.CPUTotal,
+		systemTotal:  current.SystemTotal,
+		onlineCPUs:   current.OnlineCPUs,
+		networkBytes: current.NetworkBytes,
+		readAt:       time.Now(),
+	}
+
+	return sample, current.MemoryBytes, nil
+}
+
+// recordContainerStats computes the usage rates against the previous sample and publishes them.
+func recordContainerStats(containerName string, sample statsSample, memory uint64) {
+	statsGuard.Lock()
+	defer statsGuard.Unlock()
+
+	previous, exists := statsSamples[containerName]
+	statsSamples[containerName] = sample
+
+	current := ContainerStats{MemoryBytes: memory, SampledAt: sample.readAt}
+
+	if exists {
+		cpuDelta := float64(sample.cpuTotal) - float64(previous.cpuTotal)
+		systemDelta := float64(sample.systemTotal) - float64(previous.systemTotal)
+		if cpuDelta > 0 && systemDelta > 0 {
+			current.CPUPercent = cpuDelta / systemDelta * float64(sample.onlineCPUs) * 100
+		}
+
+		elapsed := sample.readAt.Sub(previous.readAt).Seconds()
+		if elapsed > 0 && sample.networkBytes >= previous.networkBytes {
+			current.NetworkBytesPerSecond = float64(sample.networkBytes-previous.networkBytes) / elapsed
+		}
+	}
+
+	stats[containerName] = current
+
+	metrics.SetGauge(metricCPUPercent, containerName, current.CPUPercent)
+	metrics.SetGauge(metricNetworkRate, containerName, current.NetworkBytesPerSecond)
+	metrics.SetGauge(metricMemoryBytes, containerName, float64(current.MemoryBytes))
+}
+
+// forgetContainerStats drops the samples of a container that is no longer running.
+func forgetContainerStats(containerName string) {
+	statsGuard.Lock()
+	defer statsGuard.Unlock()
+
+	if _, exists := statsSamples[containerName]; !exists {
+		return
+	}
+
+	delete(stats, containerName)
+	delete(statsSamples, containerName)
+
+	metrics.DeleteGauge(metricCPUPercent, containerName)
+	metrics.DeleteGauge(metricNetworkRate, containerName)
+	metrics.DeleteGauge(metricMemoryBytes, containerName)
+}
+
+// GetContainerStats returns the last computed resource usage of a running container, identified
+// by its config.ContainerKey.
+func GetContainerStats(containerKey string) (ContainerStats, bool) {
+	statsGuard.Lock()
+	defer statsGuard.Unlock()
+
+	current, exists := stats[containerKey]
+	return current, exists
+}
+
+// isContainerBusy reports whether the container is serving requests on any gateway instance, or
+// its resource usage is above the activity thresholds of its policy, in which case it must not be
+// considered idle.
+func isContainerBusy(policy config.ContainerPolicy) bool {
+	if requests := inFlightRequests(policy.Key()); requests > 0 {
+		log.Printf("Container %s is busy: %d requests in flight", policy.Key(), requests)
+		return true
+	}
+
+	if !policy.Activity.Enabled() {
+		return false
+	}
+
+	current, exists := GetContainerStats(policy.Key())
+	if !exists {
+		return false
+	}
+
+	if policy.Activity.CPUPercent > 0 && current.CPUPercent >= policy.Activity.CPUPercent {
+		log.Printf("Container %s is busy: CPU at %.2f%%", policy.Key(), current.CPUPercent)
+		return true
+	}
+
+	if policy.Activity.NetworkBytesPerSecond > 0 && current.NetworkBytesPerSecond >= policy.Activity.NetworkBytesPerSecond {
+		log.Printf("Container %s is busy: network at %.0f bytes/s", policy.Key(), current.NetworkBytesPerSecond)
+		return true
+	}
+
+	return false
+}
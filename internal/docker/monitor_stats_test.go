@@ -0,0 +1,127 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
+)
+
+// recordSamples records two samples of a container, one second apart, and forgets them when the
+// test ends.
+func recordSamples(t *testing.T, containerName string, first, second statsSample) {
+	t.Helper()
+	t.Cleanup(func() { forgetContainerStats(containerName) })
+
+	second.readAt = first.readAt.Add(time.Second)
+	recordContainerStats(containerName, first, 0)
+	recordContainerStats(containerName, second, 64<<20)
+}
+
+func TestRecordContainerStatsComputesRates(t *testing.T) {
+	now := time.Now()
+
+	recordContainerStats("stats", statsSample{cpuTotal: 100, systemTotal: 1000, onlineCPUs: 2, networkBytes: 500, readAt: now}, 0)
+	t.Cleanup(func() { forgetContainerStats("stats") })
+
+	if current, _ := GetContainerStats("stats"); current.CPUPercent != 0 || current.NetworkBytesPerSecond != 0 {
+		t.Errorf("first sample = %+v, want no rates", current)
+	}
+
+	recordContainerStats("stats", statsSample{cpuTotal: 300, systemTotal: 2000, onlineCPUs: 2, networkBytes: 4500, readAt: now.Add(2 * time.Second)}, 64<<20)
+
+	current, _ := GetContainerStats("stats")
+	if current.CPUPercent != 40 {
+		t.Errorf("CPUPercent = %.2f, want 40", current.CPUPercent)
+	}
+	if current.NetworkBytesPerSecond != 2000 {
+		t.Errorf("NetworkBytesPerSecond = %.2f, want 2000", current.NetworkBytesPerSecond)
+	}
+	if current.MemoryBytes != 64<<20 {
+		t.Errorf("MemoryBytes = %d, want %d", current.MemoryBytes, 64<<20)
+	}
+}
+
+func TestIsContainerBusy(t *testing.T) {
+	now := time.Now()
+	idle := statsSample{cpuTotal: 0, systemTotal: 0, onlineCPUs: 1, readAt: now}
+
+	tests := []struct {
+		name     string
+		activity config.ActivityConfig
+		sample   *statsSample // Second sample; no stats when nil
+		want     bool
+	}{
+		{"disabled", config.ActivityConfig{}, &statsSample{cpuTotal: 900, systemTotal: 1000, onlineCPUs: 1}, false},
+		{"no stats", config.ActivityConfig{CPUPercent: 10}, nil, false},
+		{"cpu above", config.ActivityConfig{CPUPercent: 10}, &statsSample{cpuTotal: 200, systemTotal: 1000, onlineCPUs: 1}, true},
+		{"cpu at threshold", config.ActivityConfig{CPUPercent: 20}, &statsSample{cpuTotal: 200, systemTotal: 1000, onlineCPUs: 1}, true},
+		{"cpu below", config.ActivityConfig{CPUPercent: 50}, &statsSample{cpuTotal: 200, systemTotal: 1000, onlineCPUs: 1}, false},
+		{"network above", config.ActivityConfig{NetworkBytesPerSecond: 1000}, &statsSample{systemTotal: 1000, onlineCPUs: 1, networkBytes: 5000}, true},
+		{"network below", config.ActivityConfig{NetworkBytesPerSecond: 10000}, &statsSample{systemTotal: 1000, onlineCPUs: 1, networkBytes: 5000}, false},
+		{"either", config.ActivityConfig{CPUPercent: 50, NetworkBytesPerSecond: 1000}, &statsSample{cpuTotal: 10, systemTotal: 1000, onlineCPUs: 1, networkBytes: 5000}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.sample != nil {
+				recordSamples(t, "busy", idle, *test.sample)
+			}
+
+			route := newTestRoute("busy", "127.0.0.1", 1)
+			route.Activity = test.activity
+
+			if got := isContainerBusy(config.NewRoutePolicy(route)); got != test.want {
+				t.Errorf("isContainerBusy = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMonitorKeepsBusyContainerPastTTL(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	id := fake.AddContainer("worker", "running")
+	syncContainersState()
+	setLastAccess(t, id, time.Now().Add(-time.Hour))
+	t.Cleanup(func() { forgetContainerStats("worker") })
+
+	route := newTestRoute("worker", "127.0.0.1", 1)
+	route.TTL = 10
+	route.Activity = config.ActivityConfig{CPUPercent: 5}
+	registerPolicies(route)
+
+	fake.SetStats(id, container_runtime.Stats{CPUTotal: 0, SystemTotal: 0, OnlineCPUs: 1})
+	collectContainersStats()
+	fake.SetStats(id, container_runtime.Stats{CPUTotal: 500, SystemTotal: 1000, OnlineCPUs: 1})
+	collectContainersStats()
+
+	monitorAndStopContainers()
+	if state := fake.State(id); state != "running" {
+		t.Fatalf("container state = %q, want running while its CPU is above the threshold", state)
+	}
+
+	fake.SetStats(id, container_runtime.Stats{CPUTotal: 500, SystemTotal: 2000, OnlineCPUs: 1})
+	collectContainersStats()
+
+	monitorAndStopContainers()
+	if state := fake.State(id); state != "exited" {
+		t.Errorf("container state = %q, want exited once idle", state)
+	}
+}
@@ -16,8 +16,10 @@
 package docker
 
 import (
+	"fmt"
 	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
 	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/events"
 	"sync"
 	"time"
 )
@@ -27,53 +29,93 @@ var (
 )
 
 // CheckContainersToStop starts the continuous process of monitoring and stopping inactive containers.
+// In a high-availability cluster only the leader does it.
 func CheckContainersToStop() {
 	for {
-		monitorAndStopContainers()
+		if isLeader() {
+			monitorAndStopContainers()
+		}
 		time.Sleep(5 * time.Second)
 	}
 }
 
 // monitorAndStopContainers monitors and stops containers that are inactive beyond the timeout limit.
+// Each container is checked once per tick against the policy merged from all of its routes.
 func monitorAndStopContainers() {
 	containerMonitorMutex.Lock()
 	defer containerMonitorMutex.Unlock()
 
 	now := time.Now()
 
-	hostStore := config.GetHostStore()
-
-	hosts := hostStore.ListHosts()
+	policies := config.GetHostStore().ListContainerPolicies()
 
-	for _, host := range hosts {
-		routes, _ := hostStore.GetAllRoutes(host)
+	for _, policy := range policies {
+		container, _ := container_store.GetByContainerName(policy.Key())
 
-		for _, route := range routes {
-			container, _ := container_store.GetByContainerName(route.Backend.ContainerName)
+		if policy.KeepWarm || policy.InWarmWindow(now) || (container != nil && container.Pinned) {
+			keepContainerWarm(policy, container)
+			continue
+		}
+		setWarmError(policy.Key(), "")
 
-			if container != nil {
-				checkAndStopContainer(*container, route, now)
-			}
+		if container != nil && !hasRunningDependants(policy.Key(), policies) {
+			checkAndStopContainer(*container, policy, now)
 		}
 	}
+
+	stopUnusedDependencies(policies)
+	evictUnderPressure(now)
 }
 
 // checkAndStopContainer checks if the container should be stopped based on TTL.
-func checkAndStopContainer(container container_store.Container, route config.RouteConfig, now time.Time) {
-	if isContainerExpired(container, route, now) {
-		stopAndRemoveContainer(container)
+func checkAndStopContainer(container container_store.Container, policy config.ContainerPolicy, now time.Time) {
+	if isContainerExpired(container, policy, now) && !isContainerBusy(policy) {
+		stopAndRemoveContainer(container, policy, fmt.Sprintf("idle for more than %d seconds", policy.TTL))
 	}
 }
 
 // isContainerExpired checks if the container has exceeded the allowed inactivity time.
-func isContainerExpired(container container_store.Container, route config.RouteConfig, now time.Time) bool {
-	return now.Sub(container.LastAccess) > time.Duration(route.TTL)*time.Second && container.IsActive
+func isContainerExpired(container container_store.Container, policy config.ContainerPolicy, now time.Time) bool {
+	return now.Sub(container.LastAccess) > time.Duration(policy.TTL)*time.Second && container.IsActive
 }
 
-// stopAndRemoveContainer stops and removes the container from the store.
-func stopAndRemoveContainer(container container_store.Container) {
-	StopContainer(container.ID)
+// stopAndRemoveContainer applies the container's idle action, updates the store and publishes
+// why the container was stopped. When the idle action fails, the stored state is left alone and
+// only the failure is published, so the container is stopped again on a later tick.
+func stopAndRemoveContainer(container container_store.Container, policy config.ContainerPolicy, reason string) error {
+	state := container_store.StateExited
+
+	var err error
+	switch {
+	case policy.Service:
+		err = ScaleDownService(container.ID)
+	case policy.IdleAction == config.IdleActionPause:
+		err = PauseContainer(container.ID)
+		state = container_store.StatePaused
+	case policy.IdleAction == config.IdleActionCheckpoint:
+		err = CheckpointContainer(container.ID)
+	case policy.IdleAction == config.IdleActionRemove:
+		var removed bool
+		if removed, err = RemoveContainer(container.ID); removed {
+			state = container_store.StateRemoved
+		}
+	default:
+		err = StopContainer(container.ID)
+	}
+
+	if err != nil {
+		publishStopFailure(policy.Key(), err)
+		return err
+	}
+
+	container_store.Modify(container.ID, func(stored *container_store.Container) {
+		stored.IsActive = false
+		stored.State = state
+	})
 
-	container.IsActive = false
-	container_store.Update(container)
+	event := containerEvent(events.ContainerStopped, policy.Key())
+	event.State = state
+	event.Reason = reason
+	events.Publish(event)
+	return nil
 }
@@ -0,0 +1,229 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/events"
+)
+
+// setLastAccess moves the last access of a stored container to the given time.
+func setLastAccess(t *testing.T, containerID string, lastAccess time.Time) {
+	t.Helper()
+
+	stored, exists := container_store.GetByID(containerID)
+	if !exists {
+		t.Fatalf("container %s not in store", containerID)
+	}
+	stored.LastAccess = lastAccess
+	container_store.Update(stored)
+}
+
+func TestMonitorStopsExpiredContainer(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	id := fake.AddContainer("app", "running")
+	syncContainersState()
+	setLastAccess(t, id, time.Now().Add(-time.Minute))
+
+	route := newTestRoute("app", "127.0.0.1", 1)
+	route.TTL = 10
+	registerPolicies(route)
+
+	monitorAndStopContainers()
+
+	if state := fake.State(id); state != "exited" {
+		t.Errorf("runtime state = %q, want exited", state)
+	}
+
+	stored, _ := container_store.GetByID(id)
+	if stored.IsActive {
+		t.Errorf("stored container is still active")
+	}
+}
+
+func TestMonitorKeepsContainerWithinTTL(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	id := fake.AddContainer("app", "running")
+	syncContainersState()
+
+	route := newTestRoute("app", "127.0.0.1", 1)
+	route.TTL = 60
+	registerPolicies(route)
+
+	monitorAndStopContainers()
+
+	if stops := fake.Stops(id); stops != 0 {
+		t.Errorf("container stopped %d times, want 0", stops)
+	}
+}
+
+func TestMonitorPausesExpiredContainer(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	id := fake.AddContainer("app", "running")
+	syncContainersState()
+	setLastAccess(t, id, time.Now().Add(-time.Minute))
+
+	route := newTestRoute("app", "127.0.0.1", 1)
+	route.IdleAction = config.IdleActionPause
+	registerPolicies(route)
+
+	monitorAndStopContainers()
+
+	if state := fake.State(id); state != "paused" {
+		t.Errorf("runtime state = %q, want paused", state)
+	}
+}
+
+func TestMonitorKeepsDependencyOfRunningBackend(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	appID := fake.AddContainer("app", "running")
+	dbID := fake.AddContainer("db", "running")
+	syncContainersState()
+	setLastAccess(t, dbID, time.Now().Add(-time.Minute))
+
+	route := newTestRoute("app", "127.0.0.1", 1)
+	route.TTL = 60
+	route.Backend.DependsOn = []config.Dependency{{ContainerName: "db"}}
+	registerPolicies(route)
+
+	monitorAndStopContainers()
+
+	if state := fake.State(dbID); state != "running" {
+		t.Errorf("dependency state = %q, want running", state)
+	}
+
+	setLastAccess(t, appID, time.Now().Add(-time.Minute))
+	monitorAndStopContainers()
+
+	if state := fake.State(appID); state != "exited" {
+		t.Errorf("backend state = %q, want exited", state)
+	}
+	if state := fake.State(dbID); state != "exited" {
+		t.Errorf("dependency state = %q, want exited once the backend stopped", state)
+	}
+}
+
+func TestMonitorKeepsDependencyOfStartingBackend(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	fake.AddContainer("app", "exited")
+	dbID := fake.AddContainer("db", "running")
+	syncContainersState()
+
+	route := newTestRoute("app", "127.0.0.1", 1)
+	route.Backend.DependsOn = []config.Dependency{{ContainerName: "db"}}
+	registerPolicies(route)
+
+	// The backend is between the start of its dependencies and its health check.
+	beginStart("app")
+	monitorAndStopContainers()
+	endStart("app")
+
+	if state := fake.State(dbID); state != "running" {
+		t.Errorf("dependency state = %q, want running while the backend starts", state)
+	}
+
+	monitorAndStopContainers()
+
+	if state := fake.State(dbID); state != "exited" {
+		t.Errorf("dependency state = %q, want exited once the start is over", state)
+	}
+}
+
+func TestApplyIdleActionPausesWithinTTL(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	id := fake.AddContainer("app", "running")
+	syncContainersState()
+
+	route := newTestRoute("app", "127.0.0.1", 1)
+	route.TTL = 3600
+	route.IdleAction = config.IdleActionPause
+	registerPolicies(route)
+
+	if found, err := ApplyIdleAction("app"); !found || err != nil {
+		t.Fatalf("ApplyIdleAction(app) = %t, %v for a container referenced by a route", found, err)
+	}
+	if state := fake.State(id); state != "paused" {
+		t.Errorf("runtime state = %q, want paused", state)
+	}
+	if found, _ := ApplyIdleAction("unknown"); found {
+		t.Errorf("ApplyIdleAction(unknown) found the container")
+	}
+}
+
+func TestFailedIdleActionKeepsStoredState(t *testing.T) {
+	tests := []struct {
+		name       string
+		idleAction string
+	}{
+		{name: "stop", idleAction: config.IdleActionStop},
+		{name: "pause", idleAction: config.IdleActionPause},
+		{name: "checkpoint", idleAction: config.IdleActionCheckpoint},
+		{name: "remove", idleAction: config.IdleActionRemove},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := setupFakeRuntime(t)
+			published, unsubscribe := events.SubscribeTypes(8, func(eventType string) bool {
+				return eventType == events.ContainerStopped || eventType == events.ContainerStopFailed
+			})
+			defer unsubscribe()
+
+			id := fake.AddContainer("app", "running")
+			syncContainersState()
+			fake.SetStopError(id, errors.New("daemon unavailable"))
+
+			route := newTestRoute("app", "127.0.0.1", 1)
+			route.IdleAction = test.idleAction
+			registerPolicies(route)
+
+			stored, _ := container_store.GetByID(id)
+			if err := stopAndRemoveContainer(stored, config.NewRoutePolicy(route), "idle"); err == nil {
+				t.Fatalf("stopAndRemoveContainer succeeded while the runtime fails")
+			}
+
+			stored, _ = container_store.GetByID(id)
+			if !stored.IsActive || stored.State != container_store.StateRunning {
+				t.Errorf("stored container = %t %s, want it still active and running", stored.IsActive, stored.State)
+			}
+
+			select {
+			case event := <-published:
+				if event.Type != events.ContainerStopFailed {
+					t.Errorf("event = %s, want only %s", event.Type, events.ContainerStopFailed)
+				}
+			default:
+				t.Errorf("the stop failure was not published")
+			}
+			select {
+			case event := <-published:
+				t.Errorf("unexpected event %s", event.Type)
+			default:
+			}
+		})
+	}
+}
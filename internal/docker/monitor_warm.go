@@ -0,0 +1,107 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"log"
+	"sync"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+var (
+	prewarming      = make(map[string]bool)
+	warmErrors      = make(map[string]string)
+	prewarmingGuard = &sync.Mutex{}
+)
+
+// keepContainerWarm starts, in the background, a stopped container that must be running because
+// its routes require it to be kept warm or one of its warm windows is open or about to open.
+func keepContainerWarm(policy config.ContainerPolicy, container *container_store.Container) {
+	if container == nil {
+		setWarmError(policy.Key(), "container not found")
+		return
+	}
+
+	if container.IsActive || len(policy.Routes) == 0 {
+		setWarmError(policy.Key(), "")
+		return
+	}
+
+	prewarmingGuard.Lock()
+	defer prewarmingGuard.Unlock()
+
+	if prewarming[policy.Key()] {
+		return
+	}
+	prewarming[policy.Key()] = true
+
+	go func() {
+		defer func() {
+			prewarmingGuard.Lock()
+			delete(prewarming, policy.Key())
+			prewarmingGuard.Unlock()
+		}()
+
+		log.Printf("Container %s must be kept warm, starting it.", policy.Key())
+		if _, err := StartContainer(policy.Routes[0]); err != nil {
+			log.Printf("Error warming container %s: %v", policy.Key(), err)
+			setWarmError(policy.Key(), err.Error())
+			return
+		}
+
+		setWarmError(policy.Key(), "")
+	}()
+}
+
+// setWarmError records why a container that must be warm is not running; an empty reason clears it.
+func setWarmError(containerName, reason string) {
+	prewarmingGuard.Lock()
+	defer prewarmingGuard.Unlock()
+
+	if reason == "" {
+		delete(warmErrors, containerName)
+		return
+	}
+	warmErrors[containerName] = reason
+}
+
+// GetWarmError returns why a container that must be warm could not be kept running. The container
+// is identified by its config.ContainerKey.
+func GetWarmError(containerKey string) (string, bool) {
+	prewarmingGuard.Lock()
+	defer prewarmingGuard.Unlock()
+
+	reason, exists := warmErrors[containerKey]
+	return reason, exists
+}
+
+// WarmUpContainers starts every stopped container whose routes require it to be kept warm.
+// It is called once at boot, after the first synchronization of the container states. In a
+// high-availability cluster only the leader does it.
+func WarmUpContainers() {
+	if !isLeader() {
+		return
+	}
+
+	for _, policy := range config.GetHostStore().ListContainerPolicies() {
+		if policy.KeepWarm {
+			container, _ := container_store.GetByContainerName(policy.Key())
+			keepContainerWarm(policy, container)
+		}
+	}
+}
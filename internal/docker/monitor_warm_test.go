@@ -0,0 +1,147 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+// waitForWarmUps waits until no container is being warmed in the background.
+func waitForWarmUps(t *testing.T) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		prewarmingGuard.Lock()
+		pending := len(prewarming)
+		prewarmingGuard.Unlock()
+
+		if pending == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("containers still warming up after 5s")
+}
+
+func TestWarmUpStartsKeepWarmContainers(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	host, port := startHealthServer(t, http.StatusOK)
+
+	warmID := fake.AddContainer("warm", "exited")
+	coldID := fake.AddContainer("cold", "exited")
+	syncContainersState()
+
+	warm := newTestRoute("warm", host, port)
+	warm.KeepWarm = true
+	registerPolicies(warm, newTestRoute("cold", host, port))
+
+	WarmUpContainers()
+	waitForWarmUps(t)
+
+	if state := fake.State(warmID); state != "running" {
+		t.Errorf("keepWarm container state = %q, want running", state)
+	}
+	if state := fake.State(coldID); state != "exited" {
+		t.Errorf("other container state = %q, want exited", state)
+	}
+	if reason, exists := GetWarmError("warm"); exists {
+		t.Errorf("GetWarmError(warm) = %q, want none", reason)
+	}
+}
+
+func TestWarmUpRecordsWarmError(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T) (host string, port int)
+		want    string
+	}{
+		{
+			name: "missing container",
+			prepare: func(t *testing.T) (string, int) {
+				setupFakeRuntime(t)
+				return "127.0.0.1", 1
+			},
+			want: "container not found",
+		},
+		{
+			name: "start failure",
+			prepare: func(t *testing.T) (string, int) {
+				fake := setupFakeRuntime(t)
+				fake.SetStartError(fake.AddContainer("warm-error", "exited"), errors.New("no space left on device"))
+				syncContainersState()
+				return "127.0.0.1", 1
+			},
+			want: "no space left on device",
+		},
+		{
+			name: "failed health check",
+			prepare: func(t *testing.T) (string, int) {
+				fake := setupFakeRuntime(t)
+				fake.AddContainer("warm-error", "exited")
+				syncContainersState()
+				return startHealthServer(t, http.StatusInternalServerError)
+			},
+			want: "Healthcheck failed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host, port := test.prepare(t)
+			setWarmError("warm-error", "")
+
+			route := newTestRoute("warm-error", host, port)
+			route.KeepWarm = true
+			registerPolicies(route)
+
+			WarmUpContainers()
+			waitForWarmUps(t)
+
+			if reason, _ := GetWarmError("warm-error"); !strings.Contains(reason, test.want) {
+				t.Errorf("GetWarmError = %q, want it to contain %q", reason, test.want)
+			}
+		})
+	}
+}
+
+func TestMonitorKeepsWarmContainerPastTTL(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	id := fake.AddContainer("warm", "running")
+	syncContainersState()
+	setLastAccess(t, id, time.Now().Add(-time.Hour))
+
+	route := newTestRoute("warm", "127.0.0.1", 1)
+	route.TTL = 10
+	route.KeepWarm = true
+	registerPolicies(route)
+
+	monitorAndStopContainers()
+
+	if state := fake.State(id); state != "running" {
+		t.Errorf("keepWarm container state = %q, want running past its TTL", state)
+	}
+	if stored, _ := container_store.GetByID(id); !stored.IsActive {
+		t.Errorf("stored keepWarm container is no longer active")
+	}
+}
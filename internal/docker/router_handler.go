@@ -18,21 +18,24 @@ package docker
 import (
 	"fmt"
 	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/events"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 )
 
-// checkHealth performs a health check for a specific route using Retry and Liveness Probe.
-func checkHealth(route config.RouteConfig) bool {
+// checkHealth performs a health check for a specific route using Retry and Liveness Probe. When
+// every attempt fails, the error wraps ErrStartTimeout if the backend never answered, and reports
+// the status of the last answer otherwise.
+func checkHealth(route config.RouteConfig) error {
 	client := &http.Client{
 		Timeout: time.Duration(route.Retry.Period) * time.Second,
 	}
 
 	// Extract Liveness Probe configuration
 	liveness := route.LivenessProbe
-	url := fmt.Sprintf("%s://%s:%d/%s", route.Backend.Protocol, route.Backend.Host, route.Backend.Port, route.LivenessProbe.Path)
-
 	log.Printf("Performing health check for service: %s", route.Backend.ContainerName)
 
 	// Initial delay defined in the Liveness Probe
@@ -41,20 +44,35 @@ func checkHealth(route config.RouteConfig) bool {
 		time.Sleep(time.Duration(liveness.InitialDelaySeconds) * time.Second)
 	}
 
+	startedAt := time.Now()
+	failure := fmt.Errorf("no attempt configured: %w", ErrStartTimeout)
+
 	// Attempts defined in RetryConfig
 	for attempt := 1; attempt <= route.Retry.Attempts; attempt++ {
-		resp, err := client.Get(url)
+		// The address is resolved on every attempt, as a starting container may not have it yet.
+		var resp *http.Response
+		address, err := BackendAddress(route)
+		if err == nil {
+			resp, err = client.Get(fmt.Sprintf("%s://%s/%s", route.Backend.Protocol, address, route.LivenessProbe.Path))
+		}
 
 		// Success check
 		if err == nil && resp.StatusCode == http.StatusOK {
 			log.Printf("Health check succeeded for %s on attempt %d",
 				route.Backend.ContainerName, attempt)
-			return true
+			return nil
 		}
 
 		log.Printf("Attempt %d failed for %s, error: %v",
 			attempt, route.Backend.ContainerName, err)
 
+		if err != nil {
+			failure = fmt.Errorf("%v: %w", err, ErrStartTimeout)
+		} else {
+			resp.Body.Close()
+			failure = fmt.Errorf("liveness probe answered %d", resp.StatusCode)
+		}
+
 		// If not the last attempt, wait for the retry period
 		if attempt < route.Retry.Attempts {
 			log.Printf("Waiting %d seconds before the next attempt...", route.Retry.Period)
@@ -66,6 +84,71 @@ func checkHealth(route config.RouteConfig) bool {
 	log.Printf("Health check failed for %s after %d attempts. Waiting %d seconds before finalizing...",
 		route.Backend.ContainerName, route.Retry.Attempts, route.TTL)
 
+	event := routeEvent(events.HealthCheckFailed, route)
+	event.Reason = fmt.Sprintf("%v after %d attempts", failure, route.Retry.Attempts)
+	event.DurationMs = time.Since(startedAt).Milliseconds()
+	events.Publish(event)
+
 	time.Sleep(time.Duration(route.TTL) * time.Second)
+	return failure
+}
+
+// checkPolicyHealth performs the health check of every distinct liveness probe of a container and
+// returns the error of the first one failing.
+func checkPolicyHealth(policy config.ContainerPolicy) error {
+	for _, route := range policy.Probes() {
+		if err := checkHealth(route); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkProbe performs the TCP or HTTP health check of a dependency container.
+func checkProbe(name string, probe config.ProbeConfig) bool {
+	if probe.Port == 0 {
+		log.Printf("No probe configured for %s, assuming healthy", name)
+		return true
+	}
+
+	period := time.Duration(probe.Period) * time.Second
+	address := net.JoinHostPort(probe.Host, strconv.Itoa(probe.Port))
+
+	if probe.InitialDelaySeconds > 0 {
+		log.Printf("Waiting %d seconds before initial probe of %s...", probe.InitialDelaySeconds, name)
+		time.Sleep(time.Duration(probe.InitialDelaySeconds) * time.Second)
+	}
+
+	for attempt := 1; attempt <= probe.Attempts; attempt++ {
+		var err error
+
+		if probe.Protocol == "tcp" {
+			var conn net.Conn
+			if conn, err = net.DialTimeout("tcp", address, period); err == nil {
+				conn.Close()
+			}
+		} else {
+			client := &http.Client{Timeout: period}
+			var resp *http.Response
+			if resp, err = client.Get(fmt.Sprintf("%s://%s/%s", probe.Protocol, address, probe.Path)); err == nil {
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+				}
+			}
+		}
+
+		if err == nil {
+			log.Printf("Probe succeeded for %s on attempt %d", name, attempt)
+			return true
+		}
+
+		log.Printf("Probe attempt %d failed for %s, error: %v", attempt, name, err)
+
+		if attempt < probe.Attempts {
+			time.Sleep(period)
+		}
+	}
+
 	return false
 }
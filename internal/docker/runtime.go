@@ -0,0 +1,133 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
+)
+
+var (
+	runtimes      = make(map[string]container_runtime.Runtime)
+	runtimesGuard sync.Mutex
+)
+
+// getRuntime returns the container runtime of an endpoint, creating it on first use. The empty
+// endpoint is the default runtime selected in the gateway configuration. Runtimes are wrapped so
+// that compose services are handled as container groups.
+func getRuntime(endpoint string) (container_runtime.Runtime, error) {
+	runtimesGuard.Lock()
+	defer runtimesGuard.Unlock()
+
+	if runtime, exists := runtimes[endpoint]; exists {
+		return runtime, nil
+	}
+
+	gateway := config.GetGatewayConfig()
+
+	var runtime container_runtime.Runtime
+	var err error
+	if endpoint == "" {
+		runtime, err = newRuntime(gateway)
+	} else {
+		runtime, err = newEndpointRuntime(gateway, endpoint)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	runtimes[endpoint] = container_runtime.NewComposeRuntime(runtime, endpoint)
+	return runtimes[endpoint], nil
+}
+
+// getContainerRuntime returns the runtime of the endpoint running a stored container.
+func getContainerRuntime(containerID string) (container_runtime.Runtime, error) {
+	return getRuntime(getEndpointForContainer(containerID))
+}
+
+// newRuntime creates the container runtime backend selected in the gateway configuration.
+func newRuntime(gateway config.GatewayConfig) (container_runtime.Runtime, error) {
+	log.Printf("Using %s container runtime", gateway.Runtime)
+
+	switch gateway.Runtime {
+	case config.RuntimeDocker:
+		return container_runtime.NewDockerRuntime(gateway.RuntimeHost)
+	case config.RuntimePodman:
+		return container_runtime.NewPodmanRuntime(gateway.RuntimeHost)
+	case config.RuntimeContainerd:
+		return container_runtime.NewContainerdRuntime(gateway.RuntimeHost, gateway.ContainerdNamespace)
+	default:
+		return nil, fmt.Errorf("unknown container runtime %q", gateway.Runtime)
+	}
+}
+
+// newEndpointRuntime creates the Docker runtime of a named endpoint.
+func newEndpointRuntime(gateway config.GatewayConfig, name string) (container_runtime.Runtime, error) {
+	endpoint, exists := gateway.GetEndpoint(name)
+	if !exists {
+		return nil, fmt.Errorf("unknown endpoint %q", name)
+	}
+
+	log.Printf("Connecting to Docker endpoint %s at %s", endpoint.Name, endpoint.Host)
+
+	var tls *container_runtime.DockerTLS
+	if endpoint.TLS.Enabled() {
+		tls = &container_runtime.DockerTLS{CACert: endpoint.TLS.CACert, Cert: endpoint.TLS.Cert, Key: endpoint.TLS.Key}
+	}
+	return container_runtime.NewDockerEndpointRuntime(endpoint.Host, tls)
+}
+
+// listEndpoints returns the default endpoint followed by every named endpoint, in name order.
+func listEndpoints() []string {
+	names := make(map[string]bool)
+	for _, endpoint := range config.GetGatewayConfig().Endpoints {
+		names[endpoint.Name] = true
+	}
+
+	runtimesGuard.Lock()
+	for name := range runtimes {
+		names[name] = true
+	}
+	runtimesGuard.Unlock()
+
+	endpoints := []string{""}
+	for name := range names {
+		if name != "" {
+			endpoints = append(endpoints, name)
+		}
+	}
+	sort.Strings(endpoints[1:])
+	return endpoints
+}
+
+// SetRuntime replaces the default container runtime used by the package. It must be called before
+// the monitors start, typically by tests with a container_runtime.FakeRuntime.
+func SetRuntime(runtime container_runtime.Runtime) {
+	SetEndpointRuntime("", runtime)
+}
+
+// SetEndpointRuntime replaces the container runtime of a named endpoint.
+func SetEndpointRuntime(endpoint string, runtime container_runtime.Runtime) {
+	runtimesGuard.Lock()
+	defer runtimesGuard.Unlock()
+
+	runtimes[endpoint] = container_runtime.NewComposeRuntime(runtime, endpoint)
+}
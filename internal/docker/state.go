@@ -0,0 +1,196 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/ratelimit"
+)
+
+var (
+	waking      = make(map[string]bool)
+	wakingGuard = &sync.Mutex{}
+)
+
+// loadState reads the container state saved by a previous run of the gateway.
+func loadState() {
+	path := config.GetGatewayConfig().StateFile
+	if path == "" {
+		return
+	}
+
+	if err := container_store.Load(path); err != nil {
+		log.Printf("Error loading container state from %s: %v", path, err)
+		return
+	}
+	log.Printf("Container state loaded from %s", path)
+}
+
+// saveState saves the state of the containers referenced by a route, as backend or dependency.
+func saveState() {
+	path := config.GetGatewayConfig().StateFile
+	if path == "" {
+		return
+	}
+
+	keys := make(map[string]bool)
+	for _, policy := range config.GetHostStore().ListContainerPolicies() {
+		keys[policy.Key()] = true
+		for _, dependency := range policy.DependsOn {
+			keys[policy.DependencyKey(dependency)] = true
+		}
+	}
+
+	if err := container_store.Save(path, keys); err != nil {
+		log.Printf("Error saving container state to %s: %v", path, err)
+	}
+}
+
+// checkCooldown refuses to start a container that failed to start too recently.
+func checkCooldown(stored container_store.Container) error {
+	if remaining := time.Until(stored.CooldownUntil); remaining > 0 {
+		return fmt.Errorf("container %s failed to start, retrying in %s", stored.Key(), remaining.Round(time.Second))
+	}
+	return nil
+}
+
+// ColdStartLimitError is returned when the cold start limit of a container refuses a start.
+type ColdStartLimitError struct {
+	Container  string
+	RetryAfter time.Duration
+}
+
+func (e *ColdStartLimitError) Error() string {
+	return fmt.Sprintf("container %s reached its cold start limit, retrying in %s", e.Container, e.RetryAfter.Round(time.Second))
+}
+
+// checkColdStartLimit takes a start of a container from its cold start limit, shared by the
+// instances of a cluster, and refuses it once the limit is reached.
+func checkColdStartLimit(policy config.ContainerPolicy) error {
+	coldStartLimit := policy.ColdStartLimit
+	if !coldStartLimit.Enabled() {
+		return nil
+	}
+
+	result := ratelimit.Take("coldstart:"+policy.Key(), ratelimit.Limit{Rate: coldStartLimit.Rate(), Burst: coldStartLimit.Starts})
+	if result.Allowed {
+		return nil
+	}
+	return &ColdStartLimitError{Container: policy.Key(), RetryAfter: result.RetryAfter}
+}
+
+// startFailed puts a container that failed to start in cooldown, when one is configured.
+func startFailed(containerID string) {
+	cooldown := config.GetGatewayConfig().StartFailureCooldown
+	if cooldown <= 0 {
+		return
+	}
+	container_store.UpdateCooldown(containerID, time.Now().Add(time.Duration(cooldown)*time.Second))
+}
+
+// PinContainer pins a container so it is kept running regardless of its TTL, or unpins it. The
+// container is identified by its config.ContainerKey. It reports whether the container exists.
+func PinContainer(containerKey string, pinned bool) bool {
+	stored, exists := container_store.GetByContainerName(containerKey)
+	if !exists {
+		return false
+	}
+
+	container_store.UpdatePinned(stored.ID, pinned)
+	recordPinChange(containerKey, pinned)
+	log.Printf("Container %s pinned: %v", containerKey, pinned)
+	return true
+}
+
+// WakeContainer starts, in the background, a container referenced by a route. A container
+// already being woken up is left to that start. It reports false when no route references the
+// container.
+func WakeContainer(containerKey string) bool {
+	policy, exists := config.GetHostStore().GetContainerPolicy(containerKey)
+	if !exists || len(policy.Routes) == 0 {
+		return false
+	}
+
+	wakingGuard.Lock()
+	defer wakingGuard.Unlock()
+
+	if waking[containerKey] {
+		return true
+	}
+	waking[containerKey] = true
+
+	go func() {
+		defer func() {
+			wakingGuard.Lock()
+			delete(waking, containerKey)
+			wakingGuard.Unlock()
+		}()
+
+		log.Printf("Waking container %s up", containerKey)
+		if _, err := StartContainer(policy.Routes[0]); err != nil {
+			log.Printf("Error waking container %s up: %v", containerKey, err)
+		}
+	}()
+	return true
+}
+
+// ApplyIdleAction stops a running container at once, as if its TTL had expired. In a
+// high-availability cluster, followers forward the stop to the leader and return without waiting
+// for it. It reports false when the container is unknown or not referenced by a route, and an
+// error when the stop could not be forwarded.
+func ApplyIdleAction(containerKey string) (bool, error) {
+	if _, exists := config.GetHostStore().GetContainerPolicy(containerKey); !exists {
+		return false, nil
+	}
+	if _, exists := container_store.GetByContainerName(containerKey); !exists {
+		return false, nil
+	}
+
+	if !isLeader() {
+		log.Printf("Forwarding the stop of container %s to the cluster leader", containerKey)
+		if err := getCluster().Store().PushStop(context.Background(), containerKey); err != nil {
+			log.Printf("Error forwarding the stop of container %s: %v", containerKey, err)
+			return true, err
+		}
+		return true, nil
+	}
+	return applyIdleAction(containerKey), nil
+}
+
+// applyIdleAction applies the idle action of a running container on this instance.
+func applyIdleAction(containerKey string) bool {
+	policy, exists := config.GetHostStore().GetContainerPolicy(containerKey)
+	if !exists {
+		return false
+	}
+	stored, exists := container_store.GetByContainerName(containerKey)
+	if !exists {
+		return false
+	}
+
+	if stored.IsActive {
+		log.Printf("Applying idle action %s to container %s on request", policy.IdleAction, containerKey)
+		stopAndRemoveContainer(*stored, policy, "stopped on request")
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+func TestSavedStateSurvivesRestart(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	id := fake.AddContainer("app", "running")
+	fake.AddContainer("unrelated", "running")
+	syncContainersState()
+
+	route := newTestRoute("app", "127.0.0.1", 1)
+	route.TTL = 10
+	registerPolicies(route)
+
+	lastAccess := time.Now().Add(-time.Minute).Truncate(time.Second)
+	setLastAccess(t, id, lastAccess)
+	container_store.UpdatePinned(id, true)
+
+	if err := container_store.Save(path, map[string]bool{"app": true}); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if strings.Contains(string(content), "unrelated") {
+		t.Errorf("state file contains a container not referenced by any route:\n%s", content)
+	}
+
+	// Simulate a restart: the store is empty and the containers are discovered again.
+	for stored := range container_store.GetAll() {
+		container_store.Remove(stored)
+	}
+	if err := container_store.Load(path); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	syncContainersState()
+
+	stored, _ := container_store.GetByID(id)
+	if !stored.LastAccess.Equal(lastAccess) || !stored.Pinned {
+		t.Errorf("restored container = %+v, want last access %s and pinned", stored, lastAccess)
+	}
+}
+
+func TestRemovedContainerRecreatedAfterRestart(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	host, port := startHealthServer(t, http.StatusOK)
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	id := fake.AddContainer("app", "running")
+	syncContainersState()
+
+	route := newTestRoute("app", host, port)
+	route.IdleAction = config.IdleActionRemove
+	registerPolicies(route)
+
+	stored, _ := container_store.GetByID(id)
+	stopAndRemoveContainer(stored, config.NewRoutePolicy(route), "idle")
+	if err := container_store.Save(path, map[string]bool{"app": true}); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	// Simulate a restart: the new runtime knows nothing about the removed container.
+	fake = setupFakeRuntime(t)
+	registerPolicies(route)
+	if err := container_store.Load(path); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	syncContainersState()
+
+	removed, exists := container_store.GetByContainerName("app")
+	if !exists || removed.State != container_store.StateRemoved {
+		t.Fatalf("restored container = %+v, want a removed container", removed)
+	}
+
+	if _, err := StartContainer(route); err != nil {
+		t.Fatalf("StartContainer returned error: %v", err)
+	}
+	recreated, _ := container_store.GetByContainerName("app")
+	if recreated.ID == id || fake.State(recreated.ID) != "running" {
+		t.Errorf("container after start = %+v, want a new running container", recreated)
+	}
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/metrics"
+)
+
+// serviceStartTimeout bounds the wait for the tasks of a scaled up service to run.
+const serviceStartTimeout = 2 * time.Minute
+
+// startService scales a Swarm service from zero to its configured replicas and waits until every
+// task runs and the health check through the service VIP succeeds. The caller holds the service mutex.
+func startService(ctx context.Context, rt container_runtime.Runtime, policy config.ContainerPolicy, stored container_store.Container) (bool, error) {
+	scaler, ok := rt.(container_runtime.ServiceScaler)
+	if !ok {
+		return false, fmt.Errorf("container runtime cannot scale service %s", policy.Key())
+	}
+
+	startedAt := time.Now()
+	replicas := uint64(policy.Replicas)
+
+	log.Printf("Service %s is scaled down. Scaling it to %d replicas...", policy.Key(), replicas)
+	if err := scaler.ScaleService(ctx, stored.ID, replicas); err != nil {
+		log.Printf("Error scaling service %s: %v", policy.Key(), err)
+		startFailed(stored.ID)
+		return false, err
+	}
+
+	if err := waitForTasks(ctx, scaler, stored.ID, replicas); err != nil {
+		log.Printf("Tasks of service %s did not start: %v", policy.Key(), err)
+		startFailed(stored.ID)
+		return false, err
+	}
+
+	if err := checkPolicyHealth(policy); err != nil {
+		log.Printf("Healthcheck failed for service %s: %v", policy.Key(), err)
+		startFailed(stored.ID)
+		return false, fmt.Errorf("Healthcheck failed for service %s: %w", policy.Key(), err)
+	}
+
+	log.Printf("Service %s is running with %d replicas", policy.Key(), replicas)
+	metrics.ObserveDuration(metricColdStartDuration, policy.Key(), time.Since(startedAt))
+	markContainerRunning(stored.ID)
+
+	return true, nil
+}
+
+// waitForTasks polls a service until the given number of tasks is running.
+func waitForTasks(ctx context.Context, scaler container_runtime.ServiceScaler, serviceID string, replicas uint64) error {
+	deadline := time.Now().Add(serviceStartTimeout)
+
+	for {
+		services, err := scaler.ListServices(ctx)
+		if err != nil {
+			return err
+		}
+		for _, service := range services {
+			if service.ID == serviceID && uint64(service.RunningTasks) >= replicas {
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d tasks not running after %s: %w", replicas, serviceStartTimeout, ErrStartTimeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// ScaleDownService scales a Swarm service to zero replicas.
+func ScaleDownService(serviceID string) error {
+	ctx := context.Background()
+	rt, err := getContainerRuntime(serviceID)
+	if err != nil {
+		log.Printf("Error creating container runtime: %v", err)
+		return err
+	}
+
+	scaler, ok := rt.(container_runtime.ServiceScaler)
+	if !ok {
+		log.Printf("Container runtime cannot scale service %s", serviceID)
+		return container_runtime.ErrNotSupported
+	}
+
+	service := getServiceForContainer(serviceID)
+	if service == "" {
+		log.Printf("Error finding the service associated with the container: %s", serviceID)
+		return fmt.Errorf("no service is associated with container %s", serviceID)
+	}
+
+	serviceMutex := getMutexForService(service)
+	serviceMutex.Lock()
+	defer serviceMutex.Unlock()
+
+	log.Printf("Scaling service %s to 0 replicas", service)
+	if err := scaler.ScaleService(ctx, serviceID, 0); err != nil {
+		log.Printf("Error scaling service %s: %v", service, err)
+		return err
+	}
+
+	log.Printf("Service %s scaled down successfully.", service)
+	return nil
+}
+
+// mapServices lists the Swarm services of an endpoint when a route uses one. A service is active
+// while it has replicas and running tasks.
+func mapServices(endpoint string, rt container_runtime.Runtime) (map[string]container_store.Container, error) {
+	services := make(map[string]container_store.Container)
+
+	scaler, ok := rt.(container_runtime.ServiceScaler)
+	if !ok || !endpointHasServices(endpoint) {
+		return services, nil
+	}
+
+	list, err := scaler.ListServices(context.Background())
+	if errors.Is(err, container_runtime.ErrNotSupported) {
+		log.Printf("Container runtime of endpoint %q cannot scale Swarm services", endpoint)
+		return services, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, service := range list {
+		state := container_store.StateExited
+		if service.Replicas > 0 && service.RunningTasks > 0 {
+			state = container_store.StateRunning
+		} else if service.Replicas > 0 {
+			state = container_store.StateCreated
+		}
+
+		services[service.ID] = container_store.Container{
+			ID:            service.ID,
+			ContainerName: service.Name,
+			Endpoint:      endpoint,
+			Service:       true,
+			LastAccess:    time.Now(),
+			IsActive:      state == container_store.StateRunning,
+			State:         state,
+			StartedBy:     startedBy(state),
+		}
+	}
+	return services, nil
+}
+
+// endpointHasServices reports whether any route uses a Swarm service of the endpoint.
+func endpointHasServices(endpoint string) bool {
+	for _, policy := range config.GetHostStore().ListContainerPolicies() {
+		if policy.Service && policy.Endpoint == endpoint {
+			return true
+		}
+	}
+	return false
+}
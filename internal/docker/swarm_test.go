@@ -0,0 +1,80 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+// newServiceRoute creates a route to a Swarm service with the given replicas.
+func newServiceRoute(service, host string, port, replicas int) config.RouteConfig {
+	route := newTestRoute(service, host, port)
+	route.Backend.ContainerName = ""
+	route.Backend.Service = service
+	route.Backend.Replicas = replicas
+	return route
+}
+
+func TestStartContainerScalesServiceUp(t *testing.T) {
+	fake := setupFakeRuntime(t)
+	host, port := startHealthServer(t, http.StatusOK)
+
+	id := fake.AddService("api", 0)
+	route := newServiceRoute("api", host, port, 3)
+	registerPolicies(route)
+	syncContainersState()
+
+	if _, err := StartContainer(route); err != nil {
+		t.Fatalf("StartContainer returned error: %v", err)
+	}
+
+	if replicas := fake.Replicas(id); replicas != 3 {
+		t.Errorf("replicas = %d, want 3", replicas)
+	}
+
+	stored, _ := container_store.GetByID(id)
+	if !stored.IsActive || !stored.Service {
+		t.Errorf("stored service = %+v, want active service", stored)
+	}
+}
+
+func TestMonitorScalesExpiredServiceDown(t *testing.T) {
+	fake := setupFakeRuntime(t)
+
+	id := fake.AddService("api", 2)
+	route := newServiceRoute("api", "127.0.0.1", 1, 2)
+	route.TTL = 10
+	registerPolicies(route)
+	syncContainersState()
+	setLastAccess(t, id, time.Now().Add(-time.Minute))
+
+	monitorAndStopContainers()
+
+	if replicas := fake.Replicas(id); replicas != 0 {
+		t.Errorf("replicas = %d, want 0", replicas)
+	}
+
+	syncContainersState()
+	stored, _ := container_store.GetByID(id)
+	if stored.IsActive || stored.State != container_store.StateExited {
+		t.Errorf("stored service = %+v, want inactive and exited", stored)
+	}
+}
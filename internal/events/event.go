@@ -0,0 +1,118 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Types of the container lifecycle events.
+const (
+	ContainerStarting     = "container.starting"
+	ContainerStarted      = "container.started"
+	ContainerStartFailed  = "container.start_failed"
+	HealthCheckFailed     = "container.health_check_failed"
+	ContainerStopped      = "container.stopped"
+	ContainerStopFailed   = "container.stop_failed"
+	ContainerStateChanged = "container.state_changed"
+)
+
+// Types of the other gateway events: changes of the container store, requests matching a route
+// and configuration loads.
+const (
+	StoreAdded   = "store.added"
+	StoreUpdated = "store.updated"
+	StoreRemoved = "store.removed"
+	RouteMatched = "route.matched"
+	ConfigLoaded = "config.loaded"
+)
+
+// Event is something the gateway did or observed, mostly in the lifecycle of a container.
+type Event struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Container  string    `json:"container,omitempty"`  // config.ContainerKey of the container
+	Host       string    `json:"host,omitempty"`       // Host of the route the event relates to
+	Route      string    `json:"route,omitempty"`      // Path of the route the event relates to
+	State      string    `json:"state,omitempty"`      // State of the container after the event
+	Reason     string    `json:"reason,omitempty"`     // Why the event happened
+	DurationMs int64     `json:"durationMs,omitempty"` // Time the operation took, in milliseconds
+}
+
+// subscriber receives the published events its filter accepts.
+type subscriber struct {
+	events  chan Event
+	accepts func(eventType string) bool
+}
+
+var (
+	subscribers     = make(map[int]subscriber)
+	nextSubscriber  int
+	subscriberGuard = &sync.Mutex{}
+)
+
+// Publish sends an event to every subscriber accepting its type without blocking. Subscribers
+// lagging too far behind miss the event.
+func Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	subscriberGuard.Lock()
+	defer subscriberGuard.Unlock()
+
+	for _, subscriber := range subscribers {
+		if subscriber.accepts != nil && !subscriber.accepts(event.Type) {
+			continue
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+			log.Printf("Dropping event %s of container %s: subscriber is lagging behind", event.Type, event.Container)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the published events, holding up to buffer pending
+// events, and the function ending the subscription.
+func Subscribe(buffer int) (<-chan Event, func()) {
+	return SubscribeTypes(buffer, nil)
+}
+
+// SubscribeTypes is like Subscribe, but the channel only receives the events whose type accepts
+// returns true for, so frequent events of other types cannot fill its buffer. A nil accepts
+// receives every event.
+func SubscribeTypes(buffer int, accepts func(eventType string) bool) (<-chan Event, func()) {
+	subscriberGuard.Lock()
+	defer subscriberGuard.Unlock()
+
+	id := nextSubscriber
+	nextSubscriber++
+	events := make(chan Event, buffer)
+	subscribers[id] = subscriber{events: events, accepts: accepts}
+
+	return events, func() {
+		subscriberGuard.Lock()
+		defer subscriberGuard.Unlock()
+
+		if _, exists := subscribers[id]; exists {
+			delete(subscribers, id)
+			close(events)
+		}
+	}
+}
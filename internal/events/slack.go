@@ -0,0 +1,68 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package events
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// slackMessage is the payload of a Slack-compatible incoming webhook.
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// slackText describes an event in Slack markup.
+func slackText(event Event) string {
+	var text string
+	switch event.Type {
+	case ContainerStarting:
+		text = fmt.Sprintf(":hourglass_flowing_sand: Starting *%s*", event.Container)
+	case ContainerStarted:
+		text = fmt.Sprintf(":white_check_mark: *%s* started", event.Container)
+	case ContainerStartFailed:
+		text = fmt.Sprintf(":x: *%s* failed to start", event.Container)
+	case HealthCheckFailed:
+		text = fmt.Sprintf(":warning: Health check of *%s* failed", event.Container)
+	case ContainerStopped:
+		text = fmt.Sprintf(":zzz: *%s* stopped", event.Container)
+	case ContainerStateChanged:
+		text = fmt.Sprintf(":arrows_counterclockwise: *%s* is now %s", event.Container, event.State)
+	default:
+		text = fmt.Sprintf("*%s*: %s", event.Container, event.Type)
+	}
+
+	if event.DurationMs > 0 {
+		text += fmt.Sprintf(" in %s", time.Duration(event.DurationMs)*time.Millisecond)
+	}
+	if event.Reason != "" {
+		text += ": " + event.Reason
+	}
+
+	var details []string
+	if event.Host != "" {
+		details = append(details, "host `"+event.Host+"`")
+	}
+	if event.Route != "" {
+		details = append(details, "route `"+event.Route+"`")
+	}
+	if len(details) > 0 {
+		text += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	return text
+}
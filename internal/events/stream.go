@@ -0,0 +1,150 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Stream formats served by StreamHandler.
+const (
+	formatSSE    = "sse"
+	formatNDJSON = "ndjson"
+)
+
+const (
+	// streamBuffer is how many events a stream client may lag behind before missing events.
+	streamBuffer = 256
+
+	// keepAliveInterval is how often an idle SSE stream sends a comment, so proxies keep it open.
+	keepAliveInterval = 15 * time.Second
+)
+
+// StreamHandler streams the published events to the client as they happen, either as
+// Server-Sent Events or as newline-delimited JSON.
+//
+// The format is chosen with the "format" query parameter (sse or ndjson); without it, clients
+// accepting text/event-stream get SSE and the others NDJSON. The "types" query parameter
+// restricts the stream to a comma-separated list of event types, where "container.*" matches
+// every type starting with "container.".
+func StreamHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		format := streamFormat(r)
+		filter := parseTypes(r.URL.Query().Get("types"))
+
+		events, unsubscribe := SubscribeTypes(streamBuffer, func(eventType string) bool {
+			return matchesTypes(filter, eventType)
+		})
+		defer unsubscribe()
+
+		if format == formatSSE {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				if format == formatSSE {
+					fmt.Fprint(w, ": keep-alive\n\n")
+					flusher.Flush()
+				}
+			case event := <-events:
+				if err := writeStreamEvent(w, format, event); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// streamFormat returns the stream format requested by the client.
+func streamFormat(r *http.Request) string {
+	switch r.URL.Query().Get("format") {
+	case formatSSE:
+		return formatSSE
+	case formatNDJSON:
+		return formatNDJSON
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return formatSSE
+	}
+	return formatNDJSON
+}
+
+// parseTypes splits the comma-separated event types of a stream filter.
+func parseTypes(types string) []string {
+	var filter []string
+	for _, eventType := range strings.Split(types, ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			filter = append(filter, eventType)
+		}
+	}
+	return filter
+}
+
+// matchesTypes reports whether an event type passes a stream filter; an empty filter passes all.
+func matchesTypes(filter []string, eventType string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, accepted := range filter {
+		if accepted == eventType {
+			return true
+		}
+		if strings.HasSuffix(accepted, "*") && strings.HasPrefix(eventType, strings.TrimSuffix(accepted, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeStreamEvent writes one event in the stream format.
+func writeStreamEvent(w http.ResponseWriter, format string, event Event) error {
+	content, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if format == formatSSE {
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, content)
+	} else {
+		_, err = fmt.Fprintf(w, "%s\n", content)
+	}
+	return err
+}
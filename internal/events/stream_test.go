@@ -0,0 +1,113 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openStream connects to a stream and waits until it is subscribed to the bus.
+func openStream(t *testing.T, query, accept string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+
+	server := httptest.NewServer(StreamHandler())
+	t.Cleanup(server.Close)
+
+	subscriberGuard.Lock()
+	before := len(subscribers)
+	subscriberGuard.Unlock()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/events"+query, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/events: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	for deadline := time.Now().Add(time.Second); ; {
+		subscriberGuard.Lock()
+		subscribed := len(subscribers) > before
+		subscriberGuard.Unlock()
+		if subscribed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream did not subscribe to the event bus")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return resp, bufio.NewReader(resp.Body)
+}
+
+func TestStreamNDJSONFiltersTypes(t *testing.T) {
+	resp, reader := openStream(t, "?types=container.*", "")
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", contentType)
+	}
+
+	Publish(Event{Type: RouteMatched, Host: "example.com", Route: "/api"})
+	Publish(Event{Type: ContainerStarted, Container: "app", DurationMs: 900})
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+
+	var event Event
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		t.Fatalf("invalid NDJSON line %q: %v", line, err)
+	}
+	if event.Type != ContainerStarted || event.Container != "app" || event.DurationMs != 900 {
+		t.Errorf("event = %+v, want the container.started event of app", event)
+	}
+}
+
+func TestStreamServerSentEvents(t *testing.T) {
+	resp, reader := openStream(t, "", "text/event-stream")
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", contentType)
+	}
+
+	Publish(Event{Type: StoreUpdated, Container: "app", State: "running", Reason: "was exited"})
+
+	var lines []string
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if lines[0] != "event: "+StoreUpdated {
+		t.Errorf("first line = %q, want the event type", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "data: {") || !strings.Contains(lines[1], `"reason":"was exited"`) {
+		t.Errorf("second line = %q, want the JSON event", lines[1])
+	}
+}
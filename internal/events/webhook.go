@@ -0,0 +1,141 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package events
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+// webhookBuffer is how many events a webhook may lag behind while retrying a delivery.
+const webhookBuffer = 256
+
+var (
+	webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+	// retryDelay is the wait before the first retry of a delivery; it doubles on every retry.
+	retryDelay = time.Second
+)
+
+// permanentError is a delivery failure that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (pe permanentError) Error() string {
+	return pe.err.Error()
+}
+
+// StartWebhooks delivers the published events to every webhook, each from its own goroutine so
+// a slow endpoint does not delay the others. Each webhook only subscribes to the types it
+// accepts, so per-request events do not crowd out the lifecycle events.
+func StartWebhooks(webhooks []config.WebhookConfig) {
+	for _, webhook := range webhooks {
+		events, _ := SubscribeTypes(webhookBuffer, webhook.Accepts)
+		go runWebhook(webhook, events)
+	}
+}
+
+// runWebhook delivers, in order, the events of a webhook subscription.
+func runWebhook(webhook config.WebhookConfig, events <-chan Event) {
+	for event := range events {
+		deliver(webhook, event)
+	}
+}
+
+// deliver sends an event to a webhook, retrying failed attempts with exponential backoff.
+func deliver(webhook config.WebhookConfig, event Event) error {
+	payload, err := formatPayload(webhook.Format, event)
+	if err != nil {
+		log.Printf("Error formatting event %s for webhook %s: %v", event.Type, webhook.URL, err)
+		return err
+	}
+
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		err = send(webhook, event.Type, payload)
+		if err == nil {
+			return nil
+		}
+
+		if _, permanent := err.(permanentError); permanent || attempt >= webhook.Retries {
+			log.Printf("Error delivering event %s of container %s to webhook %s: %v",
+				event.Type, event.Container, webhook.URL, err)
+			return err
+		}
+
+		log.Printf("Delivery of event %s to webhook %s failed, retrying in %s: %v", event.Type, webhook.URL, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// send performs a single delivery. Client errors other than 429 are permanent.
+func send(webhook config.WebhookConfig, eventType string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return permanentError{err}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gateway-Event", eventType)
+	if webhook.Secret != "" {
+		req.Header.Set("X-Gateway-Signature", Sign(webhook.Secret, payload))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+
+	err = fmt.Errorf("webhook answered %d", resp.StatusCode)
+	if resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+		return permanentError{err}
+	}
+	return err
+}
+
+// Sign returns the signature of a payload sent in the X-Gateway-Signature header:
+// "sha256=" followed by the hex-encoded HMAC-SHA256 of the payload keyed with secret.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// formatPayload encodes an event in the format of a webhook.
+func formatPayload(format string, event Event) ([]byte, error) {
+	if format == config.WebhookFormatSlack {
+		return json.Marshal(slackMessage{Text: slackText(event)})
+	}
+	return json.Marshal(event)
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+// recordingServer answers the given status codes in turn, then 200, and records the requests.
+type recordingServer struct {
+	mutex    sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func startRecordingServer(t *testing.T, statuses ...int) (*recordingServer, string) {
+	recorder := &recordingServer{statuses: statuses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		recorder.mutex.Lock()
+		defer recorder.mutex.Unlock()
+
+		recorder.bodies = append(recorder.bodies, body)
+		recorder.headers = append(recorder.headers, r.Header.Clone())
+		if len(recorder.statuses) > 0 {
+			w.WriteHeader(recorder.statuses[0])
+			recorder.statuses = recorder.statuses[1:]
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	delay := retryDelay
+	retryDelay = time.Millisecond
+	t.Cleanup(func() { retryDelay = delay })
+
+	return recorder, server.URL
+}
+
+func TestDeliverRetriesAndSigns(t *testing.T) {
+	recorder, url := startRecordingServer(t, http.StatusServiceUnavailable, http.StatusBadGateway)
+	webhook := config.WebhookConfig{URL: url, Secret: "s3cret", Format: config.WebhookFormatJSON, Retries: 3}
+
+	event := Event{Type: ContainerStarted, Container: "app", Host: "example.com", Route: "/api", DurationMs: 1200}
+	if err := deliver(webhook, event); err != nil {
+		t.Fatalf("deliver returned error: %v", err)
+	}
+
+	if len(recorder.bodies) != 3 {
+		t.Fatalf("webhook received %d requests, want 3", len(recorder.bodies))
+	}
+
+	body, header := recorder.bodies[2], recorder.headers[2]
+	if signature := header.Get("X-Gateway-Signature"); signature != Sign("s3cret", body) {
+		t.Errorf("signature = %q, want %q", signature, Sign("s3cret", body))
+	}
+	if header.Get("X-Gateway-Event") != ContainerStarted {
+		t.Errorf("X-Gateway-Event = %q, want %q", header.Get("X-Gateway-Event"), ContainerStarted)
+	}
+
+	var received Event
+	if err := json.Unmarshal(body, &received); err != nil || received != event {
+		t.Errorf("received event = %+v (%v), want %+v", received, err, event)
+	}
+}
+
+func TestDeliverDoesNotRetryClientErrors(t *testing.T) {
+	recorder, url := startRecordingServer(t, http.StatusBadRequest)
+	webhook := config.WebhookConfig{URL: url, Format: config.WebhookFormatJSON, Retries: 3}
+
+	if err := deliver(webhook, Event{Type: ContainerStopped, Container: "app"}); err == nil {
+		t.Fatalf("deliver succeeded although the webhook rejected the event")
+	}
+	if len(recorder.bodies) != 1 {
+		t.Errorf("webhook received %d requests, want 1", len(recorder.bodies))
+	}
+	if recorder.headers[0].Get("X-Gateway-Signature") != "" {
+		t.Errorf("payload signed although the webhook has no secret")
+	}
+}
+
+func TestSlackPayload(t *testing.T) {
+	payload, err := formatPayload(config.WebhookFormatSlack, Event{
+		Type:      ContainerStopped,
+		Container: "app",
+		Host:      "example.com",
+		Route:     "/api",
+		Reason:    "idle for more than 300 seconds",
+	})
+	if err != nil {
+		t.Fatalf("formatPayload returned error: %v", err)
+	}
+
+	var message slackMessage
+	json.Unmarshal(payload, &message)
+	for _, part := range []string{"*app* stopped", "idle for more than 300 seconds", "host `example.com`", "route `/api`"} {
+		if !strings.Contains(message.Text, part) {
+			t.Errorf("slack text %q does not contain %q", message.Text, part)
+		}
+	}
+}
+
+func TestWebhookSubscriptionSkipsOtherTypes(t *testing.T) {
+	webhook := config.WebhookConfig{Format: config.WebhookFormatJSON}
+	events, unsubscribe := SubscribeTypes(1, webhook.Accepts)
+	defer unsubscribe()
+
+	// Route matches come with every request; they must not fill the buffer of a webhook that
+	// does not accept them.
+	for i := 0; i < 10; i++ {
+		Publish(Event{Type: RouteMatched, Container: "app"})
+	}
+	Publish(Event{Type: ContainerStopped, Container: "app"})
+
+	select {
+	case event := <-events:
+		if event.Type != ContainerStopped {
+			t.Errorf("event type = %q, want %q", event.Type, ContainerStopped)
+		}
+	default:
+		t.Fatalf("the lifecycle event was dropped")
+	}
+}
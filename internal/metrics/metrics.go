@@ -0,0 +1,143 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// summary accumulates the observations of a duration metric.
+type summary struct {
+	count int64
+	sum   float64
+}
+
+var (
+	mutex     sync.Mutex
+	counters  = make(map[string]map[string]float64)
+	gauges    = make(map[string]map[string]float64)
+	summaries = make(map[string]map[string]*summary)
+	help      = make(map[string]string)
+)
+
+// Describe registers the help text printed for a metric.
+func Describe(name, text string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	help[name] = text
+}
+
+// IncCounter increments a counter metric for the given container.
+func IncCounter(name, container string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, exists := counters[name]; !exists {
+		counters[name] = make(map[string]float64)
+	}
+	counters[name][container]++
+}
+
+// SetGauge sets the current value of a gauge metric for the given container.
+func SetGauge(name, container string, value float64) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, exists := gauges[name]; !exists {
+		gauges[name] = make(map[string]float64)
+	}
+	gauges[name][container] = value
+}
+
+// DeleteGauge removes the value of a gauge metric for the given container.
+func DeleteGauge(name, container string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	delete(gauges[name], container)
+}
+
+// ObserveDuration records a duration, in seconds, for the given container.
+func ObserveDuration(name, container string, duration time.Duration) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, exists := summaries[name]; !exists {
+		summaries[name] = make(map[string]*summary)
+	}
+	if _, exists := summaries[name][container]; !exists {
+		summaries[name][container] = &summary{}
+	}
+
+	summaries[name][container].count++
+	summaries[name][container].sum += duration.Seconds()
+}
+
+// Handler exposes every metric in the Prometheus text format.
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		for _, name := range sortedKeys(counters) {
+			writeHeader(w, name, "counter")
+			for _, container := range sortedKeys(counters[name]) {
+				fmt.Fprintf(w, "%s{container=%q} %g\n", name, container, counters[name][container])
+			}
+		}
+
+		for _, name := range sortedKeys(gauges) {
+			writeHeader(w, name, "gauge")
+			for _, container := range sortedKeys(gauges[name]) {
+				fmt.Fprintf(w, "%s{container=%q} %g\n", name, container, gauges[name][container])
+			}
+		}
+
+		for _, name := range sortedKeys(summaries) {
+			writeHeader(w, name, "summary")
+			for _, container := range sortedKeys(summaries[name]) {
+				s := summaries[name][container]
+				fmt.Fprintf(w, "%s_sum{container=%q} %g\n", name, container, s.sum)
+				fmt.Fprintf(w, "%s_count{container=%q} %d\n", name, container, s.count)
+			}
+		}
+	}
+}
+
+// writeHeader prints the HELP and TYPE lines of a metric.
+func writeHeader(w http.ResponseWriter, name, metricType string) {
+	if text, exists := help[name]; exists {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, text)
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+// sortedKeys returns the keys of a map in a stable order.
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
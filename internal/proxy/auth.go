@@ -0,0 +1,98 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/auth"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+var (
+	authenticators      = make(map[string]*auth.Authenticator)
+	authenticatorsGuard = &sync.Mutex{}
+)
+
+// authenticate checks the credentials of a request against the authentication of its route, or
+// of the route's host. Unauthenticated requests are answered with the unauthorized error, and
+// false is returned. The identity headers of authenticated requests are set for the backend.
+func authenticate(w http.ResponseWriter, r *http.Request, route config.RouteConfig) bool {
+	settings := routeAuth(route)
+	if !settings.Enabled() {
+		return true
+	}
+
+	authenticator, err := getAuthenticator(settings, route.Host)
+	if err != nil {
+		log.Printf("Error loading the authentication of %s%s, refusing the request: %v", route.Host, route.Path, err)
+		WriteError(w, r, route.Host, http.StatusUnauthorized, config.ErrorUnauthorized, "Authentication is not available.")
+		return false
+	}
+
+	for _, header := range authenticator.ClaimHeaders() {
+		r.Header.Del(header)
+	}
+
+	identity, err := authenticator.Authenticate(r)
+	if err != nil {
+		log.Printf("Request %s to %s%s refused: %v", r.Header.Get(RequestIDHeader), route.Host, r.URL.Path, err)
+		w.Header().Set("WWW-Authenticate", authenticator.Challenge())
+		WriteError(w, r, route.Host, http.StatusUnauthorized, config.ErrorUnauthorized, "Authentication is required.")
+		return false
+	}
+
+	for header, value := range identity.Headers {
+		r.Header.Set(header, value)
+	}
+	return true
+}
+
+// routeAuth returns the authentication required by a route: its own, or its host's when the
+// route has none. Disabled routes are public.
+func routeAuth(route config.RouteConfig) config.AuthConfig {
+	if route.Auth.Disabled {
+		return config.AuthConfig{}
+	}
+	if route.Auth.Enabled() {
+		return route.Auth
+	}
+	hostAuth, _ := config.GetHostStore().GetAuth(route.Host)
+	return hostAuth
+}
+
+// getAuthenticator returns the authenticator of authentication settings, created once per
+// distinct settings.
+func getAuthenticator(settings config.AuthConfig, host string) (*auth.Authenticator, error) {
+	key := fmt.Sprintf("%s|%+v", host, settings)
+
+	authenticatorsGuard.Lock()
+	defer authenticatorsGuard.Unlock()
+
+	if authenticator, exists := authenticators[key]; exists {
+		return authenticator, nil
+	}
+
+	authenticator, err := auth.New(settings, host)
+	if err != nil {
+		return nil, err
+	}
+	authenticators[key] = authenticator
+	return authenticator, nil
+}
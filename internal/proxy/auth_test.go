@@ -0,0 +1,43 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+func TestUnauthenticatedRequestDoesNotWakeContainer(t *testing.T) {
+	route, fake, id := setupSleepingRoute(t, config.WakePageConfig{})
+	t.Setenv("TEST_GATEWAY_KEYS", "valid-key")
+	route.Auth = config.AuthConfig{APIKeys: config.APIKeysConfig{Env: "TEST_GATEWAY_KEYS", Header: "X-API-Key"}}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set("X-API-Key", "guessed-key")
+	recorder := httptest.NewRecorder()
+	HandleRequest(route)(recorder, req)
+
+	if recorder.Code != http.StatusUnauthorized || recorder.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("response = %d with challenge %q, want 401 with a challenge",
+			recorder.Code, recorder.Header().Get("WWW-Authenticate"))
+	}
+	if starts := fake.Starts(id); starts != 0 {
+		t.Errorf("container started %d times for an unauthenticated request", starts)
+	}
+}
@@ -0,0 +1,275 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"bytes"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+// RequestIDHeader carries the ID of a request, to the backend and back to the client.
+const RequestIDHeader = "X-Request-ID"
+
+// errorCORSRejected is the kind of the error answered to origins rejected by CORS. It has no
+// template of its own.
+const errorCORSRejected = "corsRejected"
+
+//go:embed templates/error.html
+var defaultErrorPage string
+
+var (
+	defaultErrorTemplate = htmltemplate.Must(htmltemplate.New("error").Parse(defaultErrorPage))
+
+	htmlErrorTemplates  = make(map[string]*htmltemplate.Template)
+	jsonErrorTemplates  = make(map[string]*texttemplate.Template)
+	errorTemplatesGuard = &sync.Mutex{}
+
+	// jsonTemplateFuncs are the functions of the JSON error templates. json encodes a value as a
+	// JSON literal, so request data cannot break out of the string it is placed in.
+	jsonTemplateFuncs = texttemplate.FuncMap{
+		"json": func(value any) (string, error) {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err
+		},
+	}
+)
+
+// errorData is the data rendered by an error template.
+type errorData struct {
+	Kind      string // Kind of error, such as notFound or startTimeout
+	Status    int
+	Title     string // Status text of the status code
+	Detail    string
+	Host      string
+	Path      string
+	RequestID string
+}
+
+// problem is the built-in application/problem+json body of an error (RFC 9457).
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance"`
+	RequestID string `json:"requestId"`
+}
+
+// WithRequestID makes sure every request carries an ID, keeping the one sent by the client when
+// it is valid, and returns it in the response.
+func WithRequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+			r.Header.Set(RequestIDHeader, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next(w, r)
+	}
+}
+
+// validRequestID reports whether a request ID sent by a client is kept: at most 128 letters,
+// digits, dots, underscores, colons and dashes, so it is safe to place in error bodies and logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '_', c == ':', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random request ID.
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Error generating request ID: %v", err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// WriteError answers a request with an error of the given kind, rendered in the format and with
+// the templates of the host's error pages. The error is logged with the request ID.
+func WriteError(w http.ResponseWriter, r *http.Request, host string, status int, kind, detail string) {
+	log.Printf("Request %s: %s %s%s answered %d (%s): %s",
+		r.Header.Get(RequestIDHeader), r.Method, host, r.URL.Path, status, kind, detail)
+
+	contentType, body := renderError(r, host, status, kind, detail)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// WriteCORSRejection answers a request whose origin is not allowed by the CORS configuration of
+// the host.
+func WriteCORSRejection(w http.ResponseWriter, r *http.Request, host string) {
+	WriteError(w, r, host, http.StatusUnauthorized, errorCORSRejected, "The origin of the request is not allowed.")
+}
+
+// renderError renders the body of an error and returns it with its content type. Templates that
+// fail to render fall back to the built-in body.
+func renderError(r *http.Request, host string, status int, kind, detail string) (string, []byte) {
+	errorPages, _ := config.GetHostStore().GetErrorPages(host)
+
+	data := errorData{
+		Kind:      kind,
+		Status:    status,
+		Title:     http.StatusText(status),
+		Detail:    detail,
+		Host:      host,
+		Path:      r.URL.Path,
+		RequestID: r.Header.Get(RequestIDHeader),
+	}
+
+	var body bytes.Buffer
+
+	if wantsHTML(r, errorPages.Format) {
+		tmpl := defaultErrorTemplate
+		if path, exists := errorPages.HTML[kind]; exists {
+			tmpl = htmlErrorTemplate(path)
+		}
+		if err := tmpl.Execute(&body, data); err != nil {
+			log.Printf("Error rendering the %s error page of %s: %v", kind, host, err)
+			body.Reset()
+			defaultErrorTemplate.Execute(&body, data)
+		}
+		return "text/html; charset=utf-8", body.Bytes()
+	}
+
+	if path, exists := errorPages.JSON[kind]; exists {
+		if tmpl := jsonErrorTemplate(path); tmpl != nil {
+			err := tmpl.Execute(&body, data)
+			if err == nil {
+				return "application/problem+json", body.Bytes()
+			}
+			log.Printf("Error rendering the %s error body of %s: %v", kind, host, err)
+			body.Reset()
+		}
+	}
+
+	json.NewEncoder(&body).Encode(problem{
+		Type:      "urn:gateway:error:" + kind,
+		Title:     data.Title,
+		Status:    status,
+		Detail:    detail,
+		Instance:  data.Path,
+		RequestID: data.RequestID,
+	})
+	return "application/problem+json", body.Bytes()
+}
+
+// wantsHTML reports whether an error is answered as an HTML page. In the auto format, JSON is
+// preferred unless the client accepts HTML and not JSON.
+func wantsHTML(r *http.Request, format string) bool {
+	switch format {
+	case config.ErrorFormatHTML:
+		return true
+	case config.ErrorFormatJSON:
+		return false
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "text/html") && !strings.Contains(accept, "json")
+}
+
+// htmlErrorTemplate returns the parsed HTML error template at path, or the built-in one when it
+// cannot be parsed. Templates are parsed once.
+func htmlErrorTemplate(path string) *htmltemplate.Template {
+	errorTemplatesGuard.Lock()
+	defer errorTemplatesGuard.Unlock()
+
+	if parsed, exists := htmlErrorTemplates[path]; exists {
+		return parsed
+	}
+
+	parsed, err := htmltemplate.ParseFiles(path)
+	if err != nil {
+		log.Printf("Error parsing error page template %s, using the built-in page: %v", path, err)
+		parsed = defaultErrorTemplate
+	}
+	htmlErrorTemplates[path] = parsed
+	return parsed
+}
+
+// jsonErrorTemplate returns the parsed JSON error template at path, or nil when it cannot be
+// parsed. Templates are parsed once.
+func jsonErrorTemplate(path string) *texttemplate.Template {
+	errorTemplatesGuard.Lock()
+	defer errorTemplatesGuard.Unlock()
+
+	if parsed, exists := jsonErrorTemplates[path]; exists {
+		return parsed
+	}
+
+	parsed, err := texttemplate.New(filepath.Base(path)).Funcs(jsonTemplateFuncs).ParseFiles(path)
+	if err != nil {
+		log.Printf("Error parsing error body template %s, using the built-in body: %v", path, err)
+		parsed = nil
+	}
+	jsonErrorTemplates[path] = parsed
+	return parsed
+}
+
+// replaceUpstreamError returns a ModifyResponse hook of the reverse proxy that replaces the body
+// of 5xx responses of the backend with the upstream error of the route's host.
+func replaceUpstreamError(route config.RouteConfig) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		if resp.StatusCode < 500 {
+			return nil
+		}
+
+		r := resp.Request
+		log.Printf("Request %s: backend of %s%s answered %d",
+			r.Header.Get(RequestIDHeader), route.Host, r.URL.Path, resp.StatusCode)
+
+		contentType, body := renderError(r, route.Host, resp.StatusCode, config.ErrorUpstream,
+			"The service failed to handle the request.")
+
+		resp.Body.Close()
+
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		resp.ContentLength = int64(len(body))
+		resp.Header.Set("Content-Type", contentType)
+		resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		resp.Header.Del("Content-Encoding")
+		return nil
+	}
+}
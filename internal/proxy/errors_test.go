@@ -0,0 +1,159 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+// setupUnmanagedRoute registers a host whose route proxies to the backend at address.
+func setupUnmanagedRoute(t *testing.T, address string, errorPages config.ErrorPagesConfig) config.RouteConfig {
+	t.Helper()
+
+	host, port, _ := strings.Cut(address, ":")
+	portNumber, _ := strconv.Atoi(port)
+
+	route := config.RouteConfig{
+		Host:    "errors.example.com",
+		Path:    "/",
+		Backend: config.Backend{Protocol: "http", Host: host, Port: portNumber},
+	}
+	config.GetHostStore().AddHost(config.HostConfig{Host: route.Host, ErrorPages: errorPages, Routes: []config.RouteConfig{route}})
+	return route
+}
+
+func TestErrorIsProblemJSONWithRequestID(t *testing.T) {
+	route := setupUnmanagedRoute(t, "127.0.0.1:1", config.ErrorPagesConfig{Format: config.ErrorFormatAuto})
+
+	req := httptest.NewRequest(http.MethodGet, "http://errors.example.com/orders", nil)
+	req.Header.Set("Accept", "application/json")
+	recorder := httptest.NewRecorder()
+	WithRequestID(HandleRequest(route))(recorder, req)
+
+	var body problem
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %q is not JSON: %v", recorder.Body.String(), err)
+	}
+
+	id := recorder.Header().Get(RequestIDHeader)
+	if recorder.Code != http.StatusBadGateway || recorder.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("response = %d %s, want 502 application/problem+json", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	if id == "" || body.RequestID != id || body.Type != "urn:gateway:error:"+config.ErrorBackendDown || body.Instance != "/orders" {
+		t.Errorf("problem = %+v with request ID %q", body, id)
+	}
+}
+
+func TestHostHTMLTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "404.html")
+	os.WriteFile(path, []byte(`<p>Lost on {{.Host}} ({{.RequestID}})</p>`), 0o644)
+	setupUnmanagedRoute(t, "127.0.0.1:1", config.ErrorPagesConfig{
+		Format: config.ErrorFormatHTML,
+		HTML:   map[string]string{config.ErrorNotFound: path},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://errors.example.com/missing", nil)
+	req.Header.Set(RequestIDHeader, "abc123")
+	recorder := httptest.NewRecorder()
+	WithRequestID(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, "errors.example.com", http.StatusNotFound, config.ErrorNotFound, "No route matches the request.")
+	})(recorder, req)
+
+	if body := recorder.Body.String(); body != "<p>Lost on errors.example.com (abc123)</p>" {
+		t.Errorf("body = %q, want the host template", body)
+	}
+	if recorder.Header().Get(RequestIDHeader) != "abc123" {
+		t.Errorf("request ID of the client was not kept: %q", recorder.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestUpstreamErrorIsReplaced(t *testing.T) {
+	var forwardedID string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedID = r.Header.Get(RequestIDHeader)
+		http.Error(w, "panic: stack trace", http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	backendURL, _ := url.Parse(backend.URL)
+	route := setupUnmanagedRoute(t, backendURL.Host, config.ErrorPagesConfig{Format: config.ErrorFormatJSON, Upstream: true})
+
+	req := httptest.NewRequest(http.MethodGet, "http://errors.example.com/", nil)
+	recorder := httptest.NewRecorder()
+	WithRequestID(HandleRequest(route))(recorder, req)
+
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusInternalServerError || strings.Contains(body, "stack trace") {
+		t.Errorf("response = %d %q, want 500 without the backend body", recorder.Code, body)
+	}
+	if forwardedID == "" || !strings.Contains(body, forwardedID) {
+		t.Errorf("request ID %q was not forwarded to the backend and answered in %q", forwardedID, body)
+	}
+}
+
+func TestHostJSONTemplateEncodesRequestData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "404.json")
+	os.WriteFile(path, []byte(`{"path": {{json .Path}}, "requestId": {{json .RequestID}}}`), 0o644)
+	setupUnmanagedRoute(t, "127.0.0.1:1", config.ErrorPagesConfig{
+		Format: config.ErrorFormatJSON,
+		JSON:   map[string]string{config.ErrorNotFound: path},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, `http://errors.example.com/a%22,%22admin%22:true`, nil)
+	req.Header.Set(RequestIDHeader, "abc123")
+	recorder := httptest.NewRecorder()
+	WithRequestID(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, "errors.example.com", http.StatusNotFound, config.ErrorNotFound, "No route matches the request.")
+	})(recorder, req)
+
+	var body map[string]string
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %q is not JSON: %v", recorder.Body.String(), err)
+	}
+	if len(body) != 2 || body["path"] != `/a","admin":true` || body["requestId"] != "abc123" {
+		t.Errorf("body = %v, want the path and request ID as strings", body)
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{id: "abc123", want: true},
+		{id: "2f1c:req-7_a.b", want: true},
+		{id: "", want: false},
+		{id: strings.Repeat("a", 129), want: false},
+		{id: `abc"}`, want: false},
+		{id: "abc def", want: false},
+	}
+
+	for _, test := range tests {
+		if got := validRequestID(test.id); got != test.want {
+			t.Errorf("validRequestID(%q) = %t, want %t", test.id, got, test.want)
+		}
+	}
+}
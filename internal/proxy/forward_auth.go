@@ -0,0 +1,190 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+)
+
+// hopHeaders are the hop-by-hop headers, which are not copied between the client, the
+// authorization service and the backend.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Content-Length",
+}
+
+// forwardAuthClient calls the authorization services not served by the gateway. Redirects, to a
+// login page for instance, are returned to the client.
+var forwardAuthClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// forwardAuth asks the authorization service of a route whether a request is allowed. A 2xx
+// answer lets the request through, with the chosen headers of the answer. Any other answer is
+// returned to the client as it is, and false is returned.
+func forwardAuth(w http.ResponseWriter, r *http.Request, route config.RouteConfig) bool {
+	settings := route.ForwardAuth
+	if !settings.Enabled() {
+		return true
+	}
+
+	// Clients cannot send the headers the authorization service sets.
+	for _, header := range settings.AuthResponseHeaders {
+		r.Header.Del(header)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(settings.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	authReq, err := newAuthRequest(ctx, r, settings.URL)
+	if err != nil {
+		log.Printf("Error creating the authorization request of %s%s: %v", route.Host, route.Path, err)
+		WriteError(w, r, route.Host, http.StatusBadGateway, config.ErrorBackendDown, "The authorization service is not reachable.")
+		return false
+	}
+
+	resp, err := sendAuthRequest(authReq)
+	if err != nil {
+		log.Printf("Error calling the authorization service %s: %v", settings.URL, err)
+		WriteError(w, r, route.Host, http.StatusBadGateway, config.ErrorBackendDown, "The authorization service is not reachable.")
+		return false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		for _, header := range settings.AuthResponseHeaders {
+			if values := resp.Header.Values(header); len(values) > 0 {
+				r.Header[http.CanonicalHeaderKey(header)] = values
+			}
+		}
+		return true
+	}
+
+	log.Printf("Request %s to %s%s refused by the authorization service with status %d",
+		r.Header.Get(RequestIDHeader), route.Host, r.URL.Path, resp.StatusCode)
+
+	copyHeaders(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+	return false
+}
+
+// newAuthRequest creates the GET request sent to the authorization service, with the headers of
+// the original request and its method and URI in the X-Forwarded headers.
+func newAuthRequest(ctx context.Context, r *http.Request, authURL string) (*http.Request, error) {
+	authReq, err := http.NewRequestWithContext(ctx, http.MethodGet, authURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	copyHeaders(authReq.Header, r.Header)
+	// A route served in-process identifies the client, for its rate limits, by the address of
+	// the original connection.
+	authReq.RemoteAddr = r.RemoteAddr
+
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	authReq.Header.Set("X-Forwarded-Method", r.Method)
+	authReq.Header.Set("X-Forwarded-Proto", proto)
+	authReq.Header.Set("X-Forwarded-Host", r.Host)
+	authReq.Header.Set("X-Forwarded-Uri", r.URL.RequestURI())
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		authReq.Header.Set("X-Forwarded-For", ip)
+	}
+	return authReq, nil
+}
+
+// sendAuthRequest sends a request to the authorization service. A service behind a route of the
+// gateway is called through that route, so its container is started on demand like any other.
+// The wait ends with the context of the request; a cold start still running then goes on in the
+// background, as it may be shared with other requests.
+func sendAuthRequest(authReq *http.Request) (*http.Response, error) {
+	authRoute, exists := config.GetHostStore().GetRoute(authReq.URL.Host, authReq.URL.Path)
+	if !exists {
+		return forwardAuthClient.Do(authReq)
+	}
+
+	authRoute.ForwardAuth = config.ForwardAuthConfig{}
+	buffer := &responseBuffer{header: make(http.Header)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		HandleRequest(authRoute)(buffer, authReq)
+	}()
+
+	select {
+	case <-done:
+		return buffer.response(), nil
+	case <-authReq.Context().Done():
+		return nil, authReq.Context().Err()
+	}
+}
+
+// copyHeaders copies the end-to-end headers of src to dst, replacing the values dst already has,
+// so headers such as X-Request-ID are not sent twice.
+func copyHeaders(dst, src http.Header) {
+	for name, values := range src {
+		dst[name] = append([]string(nil), values...)
+	}
+	for _, header := range hopHeaders {
+		dst.Del(header)
+	}
+}
+
+// responseBuffer is an http.ResponseWriter keeping the response of a route served in-process.
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rb *responseBuffer) Header() http.Header {
+	return rb.header
+}
+
+func (rb *responseBuffer) Write(content []byte) (int, error) {
+	rb.WriteHeader(http.StatusOK)
+	return rb.body.Write(content)
+}
+
+func (rb *responseBuffer) WriteHeader(status int) {
+	if rb.status == 0 {
+		rb.status = status
+	}
+}
+
+// response returns the buffered response.
+func (rb *responseBuffer) response() *http.Response {
+	if rb.status == 0 {
+		rb.status = http.StatusOK
+	}
+	return &http.Response{
+		StatusCode: rb.status,
+		Header:     rb.header,
+		Body:       io.NopCloser(&rb.body),
+	}
+}
@@ -0,0 +1,230 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+// setupForwardAuthRoute registers a route whose backend echoes the X-User-ID header it receives,
+// authorized by the service at authURL.
+func setupForwardAuthRoute(t *testing.T, authURL string) (config.RouteConfig, *int) {
+	t.Helper()
+
+	calls := 0
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(r.Header.Get("X-User-ID")))
+	}))
+	t.Cleanup(backend.Close)
+
+	backendURL, _ := url.Parse(backend.URL)
+	route := setupUnmanagedRoute(t, backendURL.Host, config.ErrorPagesConfig{Format: config.ErrorFormatJSON})
+	route.ForwardAuth = config.ForwardAuthConfig{URL: authURL, AuthResponseHeaders: []string{"X-User-ID"}, TimeoutSeconds: 5}
+	return route, &calls
+}
+
+// registerAuthRoute registers the host of an authorization service served by the gateway, with
+// the policy of its container, until the test ends.
+func registerAuthRoute(t *testing.T, authRoute config.RouteConfig) {
+	t.Helper()
+
+	policies := make(map[string]config.ContainerPolicy)
+	for _, policy := range config.GetHostStore().ListContainerPolicies() {
+		policies[policy.Key()] = policy
+	}
+	t.Cleanup(func() {
+		config.GetHostStore().RemoveHost(authRoute.Host)
+		config.GetHostStore().SetContainerPolicies(policies)
+	})
+
+	config.GetHostStore().AddHost(config.HostConfig{Host: authRoute.Host, Routes: []config.RouteConfig{authRoute}})
+	config.GetHostStore().SetContainerPolicies(map[string]config.ContainerPolicy{
+		authRoute.Backend.ContainerKey(): config.NewRoutePolicy(authRoute),
+	})
+}
+
+func TestForwardAuthAllowsWithResponseHeaders(t *testing.T) {
+	var forwardedURI, forwardedMethod string
+	authService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedURI, forwardedMethod = r.Header.Get("X-Forwarded-Uri"), r.Header.Get("X-Forwarded-Method")
+		if r.Header.Get("Cookie") != "session=valid" {
+			http.Redirect(w, r, "https://login.example.com/", http.StatusFound)
+			return
+		}
+		w.Header().Set("X-User-ID", "user-7")
+	}))
+	defer authService.Close()
+
+	route, calls := setupForwardAuthRoute(t, authService.URL+"/verify")
+
+	req := httptest.NewRequest(http.MethodPost, "http://errors.example.com/orders?page=2", nil)
+	req.Header.Set("Cookie", "session=valid")
+	req.Header.Set("X-User-ID", "spoofed")
+	recorder := httptest.NewRecorder()
+	HandleRequest(route)(recorder, req)
+
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "user-7" {
+		t.Errorf("response = %d %q, want 200 with the user of the authorization service", recorder.Code, recorder.Body.String())
+	}
+	if forwardedURI != "/orders?page=2" || forwardedMethod != http.MethodPost {
+		t.Errorf("authorization service got %s %s, want POST /orders?page=2", forwardedMethod, forwardedURI)
+	}
+
+	denied := httptest.NewRequest(http.MethodGet, "http://errors.example.com/orders", nil)
+	recorder = httptest.NewRecorder()
+	HandleRequest(route)(recorder, denied)
+
+	if recorder.Code != http.StatusFound || recorder.Header().Get("Location") != "https://login.example.com/" {
+		t.Errorf("denied response = %d to %q, want the redirect of the authorization service",
+			recorder.Code, recorder.Header().Get("Location"))
+	}
+	if *calls != 1 {
+		t.Errorf("backend called %d times, want once", *calls)
+	}
+}
+
+func TestForwardAuthWakesServiceBehindGateway(t *testing.T) {
+	authService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-User-ID", "user-9")
+	}))
+	defer authService.Close()
+
+	fake := container_runtime.NewFakeRuntime()
+	docker.SetRuntime(fake)
+	id := fake.AddContainer("auth", "exited")
+	fake.SetStartDelay(id, 0)
+	container_store.Add(container_store.Container{ID: id, ContainerName: "auth", State: container_store.StateExited})
+	t.Cleanup(func() { container_store.Remove(id) })
+
+	authURL, _ := url.Parse(authService.URL)
+	host, port, _ := strings.Cut(authURL.Host, ":")
+	portNumber, _ := strconv.Atoi(port)
+	authRoute := config.RouteConfig{
+		Host:          "auth.example.com",
+		Path:          "/",
+		IdleAction:    config.IdleActionStop,
+		Backend:       config.Backend{Protocol: "http", Host: host, Port: portNumber, ContainerName: "auth"},
+		Retry:         config.RetryConfig{Attempts: 1, Period: 1},
+		LivenessProbe: config.LivenessProbeConfig{Path: "health"},
+	}
+	registerAuthRoute(t, authRoute)
+
+	route, calls := setupForwardAuthRoute(t, "http://auth.example.com/")
+
+	req := httptest.NewRequest(http.MethodGet, "http://errors.example.com/", nil)
+	recorder := httptest.NewRecorder()
+	HandleRequest(route)(recorder, req)
+
+	if fake.Starts(id) != 1 {
+		t.Errorf("container of the authorization service started %d times, want once", fake.Starts(id))
+	}
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "user-9" || *calls != 1 {
+		t.Errorf("response = %d %q with %d backend calls, want 200 with the user of the woken service",
+			recorder.Code, recorder.Body.String(), *calls)
+	}
+}
+
+func TestForwardAuthBoundsColdStartOfService(t *testing.T) {
+	authService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(RequestIDHeader, r.Header.Get(RequestIDHeader))
+		if r.URL.Path == "/verify" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer authService.Close()
+
+	fake := container_runtime.NewFakeRuntime()
+	docker.SetRuntime(fake)
+	id := fake.AddContainer("slow-auth", "exited")
+	fake.SetStartDelay(id, 1500*time.Millisecond)
+	container_store.Add(container_store.Container{ID: id, ContainerName: "slow-auth", State: container_store.StateExited})
+	t.Cleanup(func() { container_store.Remove(id) })
+
+	authURL, _ := url.Parse(authService.URL)
+	host, port, _ := strings.Cut(authURL.Host, ":")
+	portNumber, _ := strconv.Atoi(port)
+	registerAuthRoute(t, config.RouteConfig{
+		Host:          "slow-auth.example.com",
+		Path:          "/verify",
+		IdleAction:    config.IdleActionStop,
+		Backend:       config.Backend{Protocol: "http", Host: host, Port: portNumber, ContainerName: "slow-auth"},
+		Retry:         config.RetryConfig{Attempts: 1, Period: 1},
+		LivenessProbe: config.LivenessProbeConfig{Path: "health"},
+	})
+
+	route, calls := setupForwardAuthRoute(t, "http://slow-auth.example.com/verify")
+	route.ForwardAuth.TimeoutSeconds = 1
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://errors.example.com/", nil)
+		recorder := httptest.NewRecorder()
+		WithRequestID(HandleRequest(route))(recorder, req)
+		return recorder
+	}
+
+	startedAt := time.Now()
+	if recorder := send(); recorder.Code != http.StatusBadGateway || *calls != 0 {
+		t.Errorf("response = %d with %d backend calls, want 502 once the timeout expired", recorder.Code, *calls)
+	}
+	if elapsed := time.Since(startedAt); elapsed > 1400*time.Millisecond {
+		t.Errorf("forward authentication waited %s, want at most the 1s timeout", elapsed)
+	}
+
+	// The cold start goes on in the background, so the next request finds the service running.
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if stored, _ := container_store.GetByID(id); stored.IsActive {
+			break
+		}
+	}
+
+	recorder := send()
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("response = %d once the service is running, want the 401 of the service", recorder.Code)
+	}
+	if ids := recorder.Header().Values(RequestIDHeader); len(ids) != 1 {
+		t.Errorf("X-Request-ID = %q, want a single value", ids)
+	}
+}
+
+func TestAuthRequestKeepsClientAddress(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://errors.example.com/orders", nil)
+	req.RemoteAddr = "203.0.113.9:4000"
+	req.Header.Set(RequestIDHeader, "abc123")
+
+	authReq, err := newAuthRequest(req.Context(), req, "http://auth.example.com/verify")
+	if err != nil {
+		t.Fatalf("newAuthRequest returned error: %v", err)
+	}
+	if authReq.RemoteAddr != req.RemoteAddr || authReq.Header.Get("X-Forwarded-For") != "203.0.113.9" {
+		t.Errorf("auth request from %q with X-Forwarded-For %q, want the client 203.0.113.9",
+			authReq.RemoteAddr, authReq.Header.Get("X-Forwarded-For"))
+	}
+	if ids := authReq.Header.Values(RequestIDHeader); len(ids) != 1 {
+		t.Errorf("X-Request-ID = %q, want a single value", ids)
+	}
+}
@@ -16,14 +16,27 @@
 package proxy
 
 import (
+	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
 )
 
-// proxyToService handles requests and proxies them to the specified service URL.
-func proxyToService(serviceURL *url.URL) http.HandlerFunc {
+// proxyToService handles requests and proxies them to the specified service URL. Backends that
+// cannot be reached are answered with the backend down error of the route's host.
+func proxyToService(serviceURL *url.URL, route config.RouteConfig) http.HandlerFunc {
 	proxy := httputil.NewSingleHostReverseProxy(serviceURL)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("Error proxying request %s to %s: %v", r.Header.Get(RequestIDHeader), serviceURL, err)
+		WriteError(w, r, route.Host, http.StatusBadGateway, config.ErrorBackendDown, "The service is not reachable.")
+	}
+
+	if errorPages, _ := config.GetHostStore().GetErrorPages(route.Host); errorPages.Upstream {
+		proxy.ModifyResponse = replaceUpstreamError(route)
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		proxy.ServeHTTP(w, r)
 	}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/ratelimit"
+)
+
+// allowRequest applies the rate limits of the route's host and of the route to a request. When
+// one is exceeded, the request is answered with the rate limited error and false is returned.
+// The RateLimit headers describe the limit with the fewest requests left.
+func allowRequest(w http.ResponseWriter, r *http.Request, route config.RouteConfig) bool {
+	hostLimit, _ := config.GetHostStore().GetRateLimit(route.Host)
+
+	scopes := []struct {
+		key   string
+		limit config.RateLimitConfig
+	}{
+		{"host:" + route.Host, hostLimit},
+		{"route:" + route.Host + route.Path, route.RateLimit},
+	}
+
+	var tightest ratelimit.Result
+	var tightestLimit config.RateLimitConfig
+
+	for _, scope := range scopes {
+		if !scope.limit.Enabled() {
+			continue
+		}
+
+		key := scope.key + ":" + clientKey(r, route, scope.limit)
+		result := ratelimit.Take(key, ratelimit.Limit{Rate: scope.limit.Rate(), Burst: scope.limit.Burst})
+
+		if !tightestLimit.Enabled() || !result.Allowed || result.Remaining < tightest.Remaining {
+			tightest, tightestLimit = result, scope.limit
+		}
+		if !result.Allowed {
+			break
+		}
+	}
+
+	if !tightestLimit.Enabled() {
+		return true
+	}
+
+	header := w.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(tightestLimit.Burst))
+	header.Set("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+	header.Set("RateLimit-Reset", ceilSeconds(tightest.Reset))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", tightestLimit.Requests, tightestLimit.Period, tightestLimit.Burst))
+
+	if tightest.Allowed {
+		return true
+	}
+
+	header.Set("Retry-After", ceilSeconds(tightest.RetryAfter))
+	WriteError(w, r, route.Host, http.StatusTooManyRequests, config.ErrorRateLimited, "Too many requests; retry later.")
+	return false
+}
+
+// clientKey returns what identifies the client of a request for a rate limit. Rate limits run
+// before authentication, so only values the client cannot make up get a bucket of their own: the
+// header when a trusted proxy forwarded the request, and API keys of the route's authentication.
+// Other requests, with a made-up key or header, are identified by their IP address.
+func clientKey(r *http.Request, route config.RouteConfig, rateLimit config.RateLimitConfig) string {
+	switch rateLimit.Key {
+	case config.RateLimitByHeader:
+		if value := r.Header.Get(rateLimit.Header); value != "" && fromTrustedProxy(r, rateLimit) {
+			return "header:" + value
+		}
+	case config.RateLimitByAPIKey:
+		if digest, valid := validAPIKey(r, route); valid {
+			// Keys are hashed so they are not kept in the shared store.
+			return "key:" + digest
+		}
+	}
+	return "ip:" + clientIP(r, rateLimit)
+}
+
+// validAPIKey returns the digest of the API key of a request when the authentication of its route
+// accepts it.
+func validAPIKey(r *http.Request, route config.RouteConfig) (string, bool) {
+	settings := routeAuth(route)
+	if !settings.APIKeys.Enabled() {
+		return "", false
+	}
+
+	authenticator, err := getAuthenticator(settings, route.Host)
+	if err != nil {
+		return "", false
+	}
+	return authenticator.APIKey(r)
+}
+
+// fromTrustedProxy reports whether the connection of a request comes from a trusted proxy.
+func fromTrustedProxy(r *http.Request, rateLimit config.RateLimitConfig) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	return err == nil && rateLimit.TrustsProxy(addr)
+}
+
+// clientIP returns the IP address of the client of a request: the address of the connection,
+// unless forwardedFor is enabled and the connection comes from a trusted proxy. X-Forwarded-For is
+// then read from the right, as each proxy appends the address it received the request from, and
+// the first address that is not a trusted proxy is the client. The entries left of it are chosen
+// by the client and ignored.
+func clientIP(r *http.Request, rateLimit config.RateLimitConfig) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	client, err := netip.ParseAddr(host)
+	if err != nil || !rateLimit.ForwardedFor || !rateLimit.TrustsProxy(client) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		client = hop
+		if !rateLimit.TrustsProxy(hop) {
+			break
+		}
+	}
+	return client.Unmap().String()
+}
+
+// ceilSeconds formats a duration as a whole number of seconds, rounded up.
+func ceilSeconds(duration time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(duration.Seconds())))
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/ratelimit"
+)
+
+func TestRateLimitPerClientIP(t *testing.T) {
+	ratelimit.SetStore(ratelimit.NewMemoryStore())
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	backendURL, _ := url.Parse(backend.URL)
+	route := setupUnmanagedRoute(t, backendURL.Host, config.ErrorPagesConfig{Format: config.ErrorFormatJSON})
+	route.RateLimit = config.RateLimitConfig{Requests: 2, Period: 60, Burst: 2, Key: config.RateLimitByIP}
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://errors.example.com/", nil)
+		req.RemoteAddr = remoteAddr
+		recorder := httptest.NewRecorder()
+		HandleRequest(route)(recorder, req)
+		return recorder
+	}
+
+	send("10.0.0.1:1000")
+	second := send("10.0.0.1:1001")
+	if second.Code != http.StatusOK || second.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("second request = %d with RateLimit-Remaining %q, want 200 and 0",
+			second.Code, second.Header().Get("RateLimit-Remaining"))
+	}
+
+	third := send("10.0.0.1:1002")
+	if third.Code != http.StatusTooManyRequests || third.Header().Get("Retry-After") != "30" {
+		t.Errorf("third request = %d with Retry-After %q, want 429 and 30", third.Code, third.Header().Get("Retry-After"))
+	}
+	if third.Header().Get("RateLimit-Limit") != "2" || third.Header().Get("RateLimit-Policy") != "2;w=60;burst=2" {
+		t.Errorf("RateLimit headers = %v", third.Header())
+	}
+
+	if other := send("10.0.0.2:1000"); other.Code != http.StatusOK {
+		t.Errorf("request of another client = %d, want 200", other.Code)
+	}
+}
+
+func TestRateLimitByAPIKey(t *testing.T) {
+	t.Setenv("TEST_GATEWAY_KEYS", "key-a,key-b")
+	route := config.RouteConfig{
+		Host: "errors.example.com",
+		Path: "/",
+		Auth: config.AuthConfig{APIKeys: config.APIKeysConfig{Env: "TEST_GATEWAY_KEYS", Header: "X-API-Key"}},
+	}
+	rateLimit := config.RateLimitConfig{Requests: 1, Period: 1, Burst: 1, Key: config.RateLimitByAPIKey}
+
+	withKey := func(apiKey, remoteAddr string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://errors.example.com/", nil)
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		req.RemoteAddr = remoteAddr
+		return req
+	}
+
+	key := clientKey(withKey("key-a", "192.0.2.1:1234"), route, rateLimit)
+	if key == "ip:192.0.2.1" || key == clientKey(withKey("key-b", "192.0.2.1:1234"), route, rateLimit) {
+		t.Errorf("clientKey() = %q for a valid key, want a bucket per key", key)
+	}
+	if other := clientKey(withKey("key-a", "198.51.100.7:1234"), route, rateLimit); other != key {
+		t.Errorf("clientKey() = %q for the same key from another IP, want %q", other, key)
+	}
+	if unknown := clientKey(withKey("made-up", "192.0.2.1:1234"), route, rateLimit); unknown != "ip:192.0.2.1" {
+		t.Errorf("clientKey() = %q for an unknown key, want the client IP", unknown)
+	}
+
+	route.Auth = config.AuthConfig{}
+	if public := clientKey(withKey("key-a", "192.0.2.1:1234"), route, rateLimit); public != "ip:192.0.2.1" {
+		t.Errorf("clientKey() = %q on a route without API keys, want the client IP", public)
+	}
+}
+
+func TestRateLimitByTrustedHeader(t *testing.T) {
+	rateLimit := config.RateLimitConfig{Requests: 1, Period: 1, Burst: 1, Key: config.RateLimitByHeader,
+		Header: "X-User", TrustedProxies: []string{"10.0.0.0/8"}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		user       string
+		want       string
+	}{
+		{name: "trusted proxy", remoteAddr: "10.0.0.1:1000", user: "alice", want: "header:alice"},
+		{name: "untrusted connection", remoteAddr: "192.0.2.1:1000", user: "alice", want: "ip:192.0.2.1"},
+		{name: "no header", remoteAddr: "10.0.0.1:1000", want: "ip:10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://errors.example.com/", nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.user != "" {
+				req.Header.Set("X-User", test.user)
+			}
+
+			if got := clientKey(req, config.RouteConfig{Host: "errors.example.com"}, rateLimit); got != test.want {
+				t.Errorf("clientKey() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitIgnoresMadeUpKeys(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+	t.Setenv("TEST_GATEWAY_KEYS", "valid-key")
+
+	tests := []struct {
+		name      string
+		rateLimit config.RateLimitConfig
+		auth      config.AuthConfig
+		setKey    func(req *http.Request, key string)
+	}{
+		{
+			name:      "api key",
+			rateLimit: config.RateLimitConfig{Requests: 3, Period: 60, Burst: 3, Key: config.RateLimitByAPIKey},
+			auth:      config.AuthConfig{APIKeys: config.APIKeysConfig{Env: "TEST_GATEWAY_KEYS", Header: "X-API-Key"}},
+			setKey:    func(req *http.Request, key string) { req.Header.Set("X-API-Key", key) },
+		},
+		{
+			name: "header",
+			rateLimit: config.RateLimitConfig{Requests: 3, Period: 60, Burst: 3, Key: config.RateLimitByHeader,
+				Header: "X-User", TrustedProxies: []string{"10.0.0.0/8"}},
+			setKey: func(req *http.Request, key string) { req.Header.Set("X-User", key) },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ratelimit.SetStore(ratelimit.NewMemoryStore())
+			route := setupUnmanagedRoute(t, backendURL.Host, config.ErrorPagesConfig{Format: config.ErrorFormatJSON})
+			route.RateLimit = test.rateLimit
+			route.Auth = test.auth
+
+			var last *httptest.ResponseRecorder
+			for i := 0; i <= test.rateLimit.Burst; i++ {
+				req := httptest.NewRequest(http.MethodGet, "http://errors.example.com/", nil)
+				req.RemoteAddr = "192.0.2.1:1000"
+				test.setKey(req, fmt.Sprintf("made-up-%d", i))
+				last = httptest.NewRecorder()
+				HandleRequest(route)(last, req)
+			}
+
+			if last.Code != http.StatusTooManyRequests {
+				t.Errorf("request %d with a new key = %d, want 429", test.rateLimit.Burst+1, last.Code)
+			}
+		})
+	}
+}
+
+func TestClientIPFromTrustedProxies(t *testing.T) {
+	trusted := config.RateLimitConfig{ForwardedFor: true, TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"}}
+
+	tests := []struct {
+		name       string
+		rateLimit  config.RateLimitConfig
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{name: "forwardedFor disabled", remoteAddr: "10.0.0.1:1000", forwarded: "203.0.113.9", want: "10.0.0.1"},
+		{name: "untrusted proxy", rateLimit: trusted, remoteAddr: "198.51.100.7:1000", forwarded: "203.0.113.9", want: "198.51.100.7"},
+		{name: "trusted proxy", rateLimit: trusted, remoteAddr: "10.0.0.1:1000", forwarded: "203.0.113.9", want: "203.0.113.9"},
+		{name: "spoofed entries ignored", rateLimit: trusted, remoteAddr: "10.0.0.1:1000",
+			forwarded: "1.2.3.4, 203.0.113.9", want: "203.0.113.9"},
+		{name: "chain of trusted proxies", rateLimit: trusted, remoteAddr: "192.0.2.1:1000",
+			forwarded: "1.2.3.4, 203.0.113.9, 10.1.1.1", want: "203.0.113.9"},
+		{name: "invalid entry", rateLimit: trusted, remoteAddr: "10.0.0.1:1000", forwarded: "1.2.3.4, bogus", want: "10.0.0.1"},
+		{name: "no header", rateLimit: trusted, remoteAddr: "10.0.0.1:1000", want: "10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://errors.example.com/", nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", test.forwarded)
+			}
+
+			if got := clientIP(req, test.rateLimit); got != test.want {
+				t.Errorf("clientIP() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
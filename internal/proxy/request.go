@@ -16,7 +16,7 @@
 package proxy
 
 import (
-	"fmt"
+	"errors"
 	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
 	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker"
 	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
@@ -30,7 +30,7 @@ import (
 func HandleRequest(route config.RouteConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if route.Backend.Protocol == "" {
-			w.WriteHeader(http.StatusNotFound)
+			WriteError(w, r, route.Host, http.StatusNotFound, config.ErrorNotFound, "No service is configured for this route.")
 			return
 		}
 		// Check if it's just a preflight (OPTIONS) request
@@ -39,29 +39,65 @@ func HandleRequest(route config.RouteConfig) http.HandlerFunc {
 			return
 		}
 
-		if route.Backend.ContainerName != "" {
-			containerService, exists := container_store.GetByContainerName(route.Backend.ContainerName)
+		if !allowRequest(w, r, route) {
+			return
+		}
+
+		if !authenticate(w, r, route) {
+			return
+		}
+
+		if !forwardAuth(w, r, route) {
+			return
+		}
+
+		if route.Backend.Managed() {
+			containerService, exists := container_store.GetByContainerName(route.Backend.ContainerKey())
 
 			if !exists {
-				w.WriteHeader(http.StatusNotFound)
+				WriteError(w, r, route.Host, http.StatusNotFound, config.ErrorNotFound, "The service of this route does not exist.")
+				return
+			}
+
+			if !containerService.IsActive && answerWhileWaking(w, r, route) {
 				return
 			}
 
 			if !containerService.IsActive {
 				_, err := docker.StartContainer(route)
+				var limitErr *docker.ColdStartLimitError
+				if errors.As(err, &limitErr) {
+					w.Header().Set("Retry-After", ceilSeconds(limitErr.RetryAfter))
+					WriteError(w, r, route.Host, http.StatusTooManyRequests, config.ErrorRateLimited, "The service was started too often; retry later.")
+					return
+				}
+				if errors.Is(err, docker.ErrStartTimeout) {
+					WriteError(w, r, route.Host, http.StatusGatewayTimeout, config.ErrorStartTimeout, "The service did not start in time.")
+					return
+				}
 				if err != nil {
-					http.Error(w, "Error starting container", http.StatusInternalServerError)
+					WriteError(w, r, route.Host, http.StatusServiceUnavailable, config.ErrorBackendDown, "The service could not be started.")
 					return
 				}
 			}
 
-			log.Printf("Last access to the service container %s updated.", route.Backend.ContainerName)
+			log.Printf("Last access to the service container %s updated.", route.Backend.ContainerKey())
 			container_store.UpdateAccessTime(containerService.ID)
+
+			docker.BeginRequest(route.Backend.ContainerKey())
+			defer docker.EndRequest(route.Backend.ContainerKey())
+		}
+
+		address, err := docker.BackendAddress(route)
+		if err != nil {
+			log.Printf("Error resolving the backend address of %s: %v", route.Backend.ContainerKey(), err)
+			WriteError(w, r, route.Host, http.StatusBadGateway, config.ErrorBackendDown, "The service is not reachable.")
+			return
 		}
 
 		serviceURL := &url.URL{
 			Scheme: route.Backend.Protocol,
-			Host:   fmt.Sprintf("%s:%d", route.Backend.Host, route.Backend.Port),
+			Host:   address,
 		}
 
 		// Strip the route path from the request
@@ -69,7 +105,7 @@ func HandleRequest(route config.RouteConfig) http.HandlerFunc {
 			r.URL.Path = stripRoutePath(r.URL.Path, route.Path)
 		}
 
-		proxyToService(serviceURL)(w, r)
+		proxyToService(serviceURL, route)(w, r)
 	}
 }
 
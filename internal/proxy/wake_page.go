@@ -0,0 +1,127 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	_ "embed"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker"
+)
+
+//go:embed templates/wake.html
+var defaultWakePage string
+
+var (
+	defaultWakeTemplate = template.Must(template.New("wake").Parse(defaultWakePage))
+
+	wakeTemplates      = make(map[string]*template.Template)
+	wakeTemplatesGuard = &sync.Mutex{}
+)
+
+// wakePageData is the data rendered by a holding page template.
+type wakePageData struct {
+	Host           string
+	Path           string
+	Container      string
+	RefreshSeconds int
+	StartFailed    bool // Whether the last start attempt failed; its error is only logged and shown by the admin API
+}
+
+// answerWhileWaking wakes the container of a route up in the background and answers at once,
+// with the holding page for browsers or a 503 with Retry-After for API clients, when the host of
+// the route is configured to. It reports false when the request must wait for the start instead.
+func answerWhileWaking(w http.ResponseWriter, r *http.Request, route config.RouteConfig) bool {
+	wakePage, _ := config.GetHostStore().GetWakePage(route.Host)
+
+	browser := wakePage.Enabled && acceptsHTML(r)
+	if !browser && wakePage.RetryAfter == 0 {
+		return false
+	}
+
+	key := route.Backend.ContainerKey()
+	if !docker.WakeContainer(key) {
+		return false
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !browser {
+		w.Header().Set("Retry-After", strconv.Itoa(wakePage.RetryAfter))
+		WriteError(w, r, route.Host, http.StatusServiceUnavailable, config.ErrorStarting, "The service is starting; retry later.")
+		return true
+	}
+
+	data := wakePageData{
+		Host:           route.Host,
+		Path:           r.URL.Path,
+		Container:      key,
+		RefreshSeconds: wakePage.RefreshSeconds,
+		StartFailed:    lastStartFailed(key),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Retry-After", strconv.Itoa(wakePage.RefreshSeconds))
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	if err := wakeTemplate(wakePage.Template).Execute(w, data); err != nil {
+		log.Printf("Error rendering the holding page of %s: %v", route.Host, err)
+	}
+	return true
+}
+
+// acceptsHTML reports whether a request comes from a browser navigating to a page.
+func acceptsHTML(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// lastStartFailed reports whether the last start attempt of a container failed.
+func lastStartFailed(containerKey string) bool {
+	coldStarts := docker.GetColdStarts(containerKey)
+	return len(coldStarts) > 0 && coldStarts[len(coldStarts)-1].Error != ""
+}
+
+// wakeTemplate returns the parsed holding page template at path, or the built-in one when path
+// is empty or cannot be parsed. Templates are parsed once.
+func wakeTemplate(path string) *template.Template {
+	if path == "" {
+		return defaultWakeTemplate
+	}
+
+	wakeTemplatesGuard.Lock()
+	defer wakeTemplatesGuard.Unlock()
+
+	if parsed, exists := wakeTemplates[path]; exists {
+		return parsed
+	}
+
+	parsed, err := template.ParseFiles(path)
+	if err != nil {
+		log.Printf("Error parsing holding page template %s, using the built-in page: %v", path, err)
+		parsed = defaultWakeTemplate
+	}
+	wakeTemplates[path] = parsed
+	return parsed
+}
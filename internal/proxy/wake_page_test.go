@@ -0,0 +1,136 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/config"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_runtime"
+	"github.com/caiomarcatti12/api-gateway-auto-scale-docker/internal/docker/container_store"
+)
+
+// setupSleepingRoute registers a host whose route is backed by a stopped container. The host
+// and the previous container policies are restored when the test ends.
+func setupSleepingRoute(t *testing.T, wakePage config.WakePageConfig) (config.RouteConfig, *container_runtime.FakeRuntime, string) {
+	t.Helper()
+
+	fake := container_runtime.NewFakeRuntime()
+	docker.SetRuntime(fake)
+	id := fake.AddContainer("app", "exited")
+	fake.SetStartDelay(id, 0)
+	container_store.Add(container_store.Container{ID: id, ContainerName: "app", State: container_store.StateExited})
+	t.Cleanup(func() { container_store.Remove(id) })
+
+	route := config.RouteConfig{
+		Host:       "example.com",
+		Path:       "/",
+		IdleAction: config.IdleActionStop,
+		Backend:    config.Backend{Protocol: "http", Host: "127.0.0.1", Port: 1, ContainerName: "app"},
+	}
+	policies := make(map[string]config.ContainerPolicy)
+	for _, policy := range config.GetHostStore().ListContainerPolicies() {
+		policies[policy.Key()] = policy
+	}
+	t.Cleanup(func() {
+		config.GetHostStore().RemoveHost("example.com")
+		config.GetHostStore().SetContainerPolicies(policies)
+	})
+
+	config.GetHostStore().AddHost(config.HostConfig{Host: "example.com", WakePage: wakePage, Routes: []config.RouteConfig{route}})
+	config.GetHostStore().SetContainerPolicies(map[string]config.ContainerPolicy{"app": config.NewRoutePolicy(route)})
+
+	return route, fake, id
+}
+
+func TestBrowserGetsHoldingPage(t *testing.T) {
+	route, fake, id := setupSleepingRoute(t, config.WakePageConfig{Enabled: true, RefreshSeconds: 5})
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	recorder := httptest.NewRecorder()
+	HandleRequest(route)(recorder, req)
+
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusServiceUnavailable || !strings.Contains(body, "Waking up example.com") {
+		t.Fatalf("response = %d %q, want 503 with the holding page", recorder.Code, body)
+	}
+	if !strings.Contains(body, `content="5"`) || recorder.Header().Get("Retry-After") != "5" {
+		t.Errorf("holding page does not refresh after 5 seconds: %q", body)
+	}
+
+	for deadline := time.Now().Add(time.Second); fake.Starts(id) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if fake.Starts(id) == 0 {
+		t.Errorf("container was not started in the background")
+	}
+}
+
+func TestHoldingPageHidesStartError(t *testing.T) {
+	route, fake, id := setupSleepingRoute(t, config.WakePageConfig{Enabled: true, RefreshSeconds: 5})
+	fake.SetStartError(id, errors.New("pull access denied for registry.internal/app"))
+
+	serve := func() string {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.Header.Set("Accept", "text/html")
+		recorder := httptest.NewRecorder()
+		HandleRequest(route)(recorder, req)
+		return recorder.Body.String()
+	}
+
+	serve()
+	for deadline := time.Now().Add(time.Second); !lastStartFailed("app") && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !lastStartFailed("app") {
+		t.Fatalf("the failed start was not recorded")
+	}
+
+	body := serve()
+	if !strings.Contains(body, "The last start attempt failed.") {
+		t.Errorf("holding page does not report the failed start: %q", body)
+	}
+	if strings.Contains(body, "registry.internal") {
+		t.Errorf("holding page shows the start error: %q", body)
+	}
+}
+
+func TestAPIClientGetsRetryAfter(t *testing.T) {
+	route, _, _ := setupSleepingRoute(t, config.WakePageConfig{Enabled: true, RefreshSeconds: 3, RetryAfter: 10})
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/", nil)
+	req.Header.Set("Accept", "application/json")
+	recorder := httptest.NewRecorder()
+	HandleRequest(route)(recorder, req)
+
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Retry-After") != "10" {
+		t.Errorf("response = %d with Retry-After %q, want 503 with Retry-After 10",
+			recorder.Code, recorder.Header().Get("Retry-After"))
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Errorf("content type = %q, want the problem+json error body", contentType)
+	}
+	if !strings.Contains(recorder.Body.String(), "starting") {
+		t.Errorf("body = %q, want the starting error", recorder.Body.String())
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is the configuration of a token bucket: Burst tokens at most, refilled at Rate tokens
+// per second. Every request takes a token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed    bool
+	Remaining  int           // Tokens left in the bucket
+	Reset      time.Duration // Time until the bucket is full again
+	RetryAfter time.Duration // Time until a token is available, when the request was refused
+}
+
+// Store keeps the token buckets, keyed by client and limit.
+type Store interface {
+	// Take takes a token from the bucket at key, creating it full when it does not exist.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Bucket is the state of a token bucket.
+type Bucket struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+	Full    time.Time `json:"full"` // When the bucket is full again; it can be forgotten after that
+}
+
+// Take refills the bucket for the time elapsed since its last update and takes a token from it.
+// A zero bucket is full.
+func (b *Bucket) Take(limit Limit, now time.Time) Result {
+	burst := float64(limit.Burst)
+
+	if b.Updated.IsZero() || !now.Before(b.Full) {
+		b.Tokens = burst
+	} else if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(burst, b.Tokens+elapsed*limit.Rate)
+	}
+	b.Updated = now
+
+	allowed := b.Tokens >= 1
+	if allowed {
+		b.Tokens--
+	}
+
+	result := limit.Result(allowed, b.Tokens)
+	b.Full = now.Add(result.Reset)
+	return result
+}
+
+// Result returns the outcome of a take that left tokens in the bucket.
+func (l Limit) Result(allowed bool, tokens float64) Result {
+	result := Result{
+		Allowed:   allowed,
+		Remaining: int(tokens),
+		Reset:     seconds((float64(l.Burst) - tokens) / l.Rate),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / l.Rate)
+	}
+	return result
+}
+
+// seconds converts a number of seconds to a duration.
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
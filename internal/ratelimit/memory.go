@@ -0,0 +1,64 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// pruneInterval is how often a MemoryStore forgets the buckets that are full again.
+const pruneInterval = time.Minute
+
+// MemoryStore is a Store held in memory, private to the gateway instance.
+type MemoryStore struct {
+	mutex   sync.Mutex
+	buckets map[string]Bucket
+	pruned  time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]Bucket)}
+}
+
+func (ms *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(ms.pruned) > pruneInterval {
+		pruneBuckets(ms.buckets, now)
+		ms.pruned = now
+	}
+
+	bucket := ms.buckets[key]
+	result := bucket.Take(limit, now)
+	ms.buckets[key] = bucket
+	return result, nil
+}
+
+// pruneBuckets removes the buckets that are full again, which behave as missing ones.
+func pruneBuckets(buckets map[string]Bucket, now time.Time) {
+	for key, bucket := range buckets {
+		if !now.Before(bucket.Full) {
+			delete(buckets, key)
+		}
+	}
+}
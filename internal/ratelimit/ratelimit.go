@@ -0,0 +1,52 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	store      Store = NewMemoryStore()
+	storeGuard       = &sync.Mutex{}
+)
+
+// SetStore replaces the store of the token buckets, to share them between gateway instances.
+func SetStore(s Store) {
+	storeGuard.Lock()
+	defer storeGuard.Unlock()
+	store = s
+}
+
+// Take takes a token from the bucket at key. When the store fails, the request is allowed.
+func Take(key string, limit Limit) Result {
+	storeGuard.Lock()
+	current := store
+	storeGuard.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	result, err := current.Take(ctx, key, limit)
+	if err != nil {
+		log.Printf("Error taking a token of rate limit %s, allowing the request: %v", key, err)
+		return Result{Allowed: true, Remaining: limit.Burst}
+	}
+	return result
+}
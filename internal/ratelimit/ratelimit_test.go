@@ -0,0 +1,56 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBucketRefills(t *testing.T) {
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Now()
+	bucket := Bucket{}
+
+	for i, want := range []bool{true, true, false} {
+		if result := bucket.Take(limit, now); result.Allowed != want {
+			t.Fatalf("take %d allowed = %v, want %v", i, result.Allowed, want)
+		}
+	}
+
+	result := bucket.Take(limit, now)
+	if result.RetryAfter != time.Second || result.Remaining != 0 || result.Reset != 2*time.Second {
+		t.Errorf("refused take = %+v, want retry after 1s and reset after 2s", result)
+	}
+
+	if result := bucket.Take(limit, now.Add(1500*time.Millisecond)); !result.Allowed {
+		t.Errorf("bucket was not refilled after 1.5s: %+v", result)
+	}
+}
+
+func TestMemoryStoreKeepsBucketsApart(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 0.1, Burst: 1}
+
+	first, _ := store.Take(context.Background(), "a", limit)
+	second, _ := store.Take(context.Background(), "a", limit)
+	other, _ := store.Take(context.Background(), "b", limit)
+
+	if !first.Allowed || second.Allowed || !other.Allowed {
+		t.Errorf("takes = %v %v %v, want true false true", first.Allowed, second.Allowed, other.Allowed)
+	}
+}
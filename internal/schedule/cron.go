@@ -0,0 +1,218 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed five-field cron expression: minute, hour, day of month, month and day of week.
+type Schedule struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+
+	anyDay     bool
+	anyWeekday bool
+}
+
+// field describes the accepted range of a cron field.
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse parses a cron expression such as "0 9 * * 1-5". Each field accepts "*", single
+// values, ranges ("1-5"), lists ("1,3,5") and steps ("*/15", "0-30/10").
+func Parse(expression string) (*Schedule, error) {
+	parts := strings.Fields(expression)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields", expression, len(fields))
+	}
+
+	values := make([]map[int]bool, len(fields))
+	for i, part := range parts {
+		parsed, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %s", expression, err.Error())
+		}
+		values[i] = parsed
+	}
+
+	// Sunday can be written as 0 or 7.
+	if values[4][7] {
+		values[4][0] = true
+	}
+
+	return &Schedule{
+		minutes:    values[0],
+		hours:      values[1],
+		days:       values[2],
+		months:     values[3],
+		weekdays:   values[4],
+		anyDay:     parts[2] == "*",
+		anyWeekday: parts[4] == "*",
+	}, nil
+}
+
+// maxLookupDays bounds how many days Next and Previous search, enough for a schedule that only
+// fires on February 29.
+const maxLookupDays = 8 * 366
+
+// Matches reports whether the schedule fires at the minute of the given time.
+func (s *Schedule) Matches(t time.Time) bool {
+	return s.minutes[t.Minute()] && s.hours[t.Hour()] && s.matchesDay(t)
+}
+
+// Next returns the first time, after t, at which the schedule fires. Times are computed in the
+// location of t.
+func (s *Schedule) Next(t time.Time) (time.Time, bool) {
+	year, month, day := t.Date()
+	for i := 0; i <= maxLookupDays; i++ {
+		date := time.Date(year, month, day+i, 0, 0, 0, 0, t.Location())
+		if !s.matchesDay(date) {
+			continue
+		}
+
+		for hour := 0; hour < 24; hour++ {
+			if !s.hours[hour] {
+				continue
+			}
+			for minute := 0; minute < 60; minute++ {
+				if start, ok := s.fire(date, hour, minute); ok && start.After(t) {
+					return start, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Previous returns the last time, not after t, at which the schedule fires. Times are computed
+// in the location of t.
+func (s *Schedule) Previous(t time.Time) (time.Time, bool) {
+	year, month, day := t.Date()
+	for i := 0; i <= maxLookupDays; i++ {
+		date := time.Date(year, month, day-i, 0, 0, 0, 0, t.Location())
+		if !s.matchesDay(date) {
+			continue
+		}
+
+		for hour := 23; hour >= 0; hour-- {
+			if !s.hours[hour] {
+				continue
+			}
+			for minute := 59; minute >= 0; minute-- {
+				if start, ok := s.fire(date, hour, minute); ok && !start.After(t) {
+					return start, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// fire returns the time at which the schedule fires at the given minute of a date, if it does. A
+// minute skipped by a daylight saving time change does not fire.
+func (s *Schedule) fire(date time.Time, hour, minute int) (time.Time, bool) {
+	if !s.minutes[minute] {
+		return time.Time{}, false
+	}
+
+	start := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, date.Location())
+	return start, start.Hour() == hour && start.Minute() == minute
+}
+
+// matchesDay reports whether the schedule fires on the day of the given time.
+func (s *Schedule) matchesDay(t time.Time) bool {
+	if !s.months[int(t.Month())] {
+		return false
+	}
+
+	day := s.days[t.Day()]
+	weekday := s.weekdays[int(t.Weekday())]
+
+	// As in standard cron, a restricted day of month and day of week match when either does.
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// parseField parses a single comma separated cron field.
+func parseField(value string, f field) (map[int]bool, error) {
+	result := make(map[int]bool)
+
+	for _, item := range strings.Split(value, ",") {
+		rangePart, step := item, 1
+
+		if index := strings.Index(item, "/"); index >= 0 {
+			parsedStep, err := strconv.Atoi(item[index+1:])
+			if err != nil || parsedStep <= 0 {
+				return nil, fmt.Errorf("invalid step in %s field %q", f.name, item)
+			}
+			rangePart, step = item[:index], parsedStep
+		}
+
+		start, end := f.min, f.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid %s field %q", f.name, item)
+			}
+			end = start
+			if step > 1 && len(bounds) == 1 {
+				end = f.max
+			}
+			if len(bounds) == 2 {
+				if end, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid %s field %q", f.name, item)
+				}
+			}
+		}
+
+		if start < f.min || end > f.max || start > end {
+			return nil, fmt.Errorf("%s field %q out of range %d-%d", f.name, item, f.min, f.max)
+		}
+
+		for v := start; v <= end; v += step {
+			result[v] = true
+		}
+	}
+
+	return result, nil
+}
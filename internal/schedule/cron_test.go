@@ -0,0 +1,129 @@
+/*
+ * Copyright 2023 Caio Matheus Marcatti Calimério
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRejectsInvalidExpressions(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+	}
+
+	for _, expression := range tests {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("Parse(%q) returned no error", expression)
+		}
+	}
+}
+
+func TestScheduleMatches(t *testing.T) {
+	tests := []struct {
+		expression string
+		time       string
+		want       bool
+	}{
+		{"0 9 * * 1-5", "2026-10-19T09:00:00Z", true},  // Monday
+		{"0 9 * * 1-5", "2026-10-19T09:01:00Z", false}, // Wrong minute
+		{"0 9 * * 1-5", "2026-10-24T09:00:00Z", false}, // Saturday
+		{"*/15 * * * *", "2026-10-19T10:45:00Z", true},
+		{"*/15 * * * *", "2026-10-19T10:50:00Z", false},
+		{"0-30/10 * * * *", "2026-10-19T10:20:00Z", true},
+		{"0-30/10 * * * *", "2026-10-19T10:40:00Z", false},
+		{"5/20 * * * *", "2026-10-19T10:45:00Z", true},
+		{"0 0,12 * * *", "2026-10-19T12:00:00Z", true},
+		{"0 0 1 11 *", "2026-11-01T00:00:00Z", true},
+		{"0 0 1 11 *", "2026-10-01T00:00:00Z", false}, // Wrong month
+		// Sunday can be written as 0 or 7.
+		{"0 0 * * 7", "2026-10-18T00:00:00Z", true},
+		{"0 0 * * 0", "2026-10-18T00:00:00Z", true},
+		{"0 0 * * 6-7", "2026-10-18T00:00:00Z", true},
+		// A restricted day of month and day of week match when either does.
+		{"0 0 13 * 5", "2026-11-13T00:00:00Z", true},  // Both
+		{"0 0 13 * 5", "2026-10-13T00:00:00Z", true},  // Day of month only
+		{"0 0 13 * 5", "2026-10-23T00:00:00Z", true},  // Friday only
+		{"0 0 13 * 5", "2026-10-19T00:00:00Z", false}, // Neither
+		// With the other field unrestricted, only the restricted one counts.
+		{"0 0 13 * *", "2026-10-23T00:00:00Z", false},
+		{"0 0 * * 5", "2026-10-13T00:00:00Z", false},
+	}
+
+	for _, test := range tests {
+		schedule, err := Parse(test.expression)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", test.expression, err)
+		}
+
+		at, _ := time.Parse(time.RFC3339, test.time)
+		if got := schedule.Matches(at); got != test.want {
+			t.Errorf("Parse(%q).Matches(%s) = %t, want %t", test.expression, test.time, got, test.want)
+		}
+	}
+}
+
+func TestScheduleNextAndPrevious(t *testing.T) {
+	tests := []struct {
+		expression string
+		timezone   string
+		time       string
+		next       string
+		previous   string
+	}{
+		{"0 9 * * 1-5", "UTC", "2026-10-19T09:00:00Z", "2026-10-20T09:00:00Z", "2026-10-19T09:00:00Z"},
+		{"0 9 * * 1-5", "UTC", "2026-10-23T09:00:30Z", "2026-10-26T09:00:00Z", "2026-10-23T09:00:00Z"}, // Friday
+		{"*/15 * * * *", "UTC", "2026-10-19T10:50:00Z", "2026-10-19T11:00:00Z", "2026-10-19T10:45:00Z"},
+		{"30 22 * * 5", "UTC", "2026-10-19T00:00:00Z", "2026-10-23T22:30:00Z", "2026-10-16T22:30:00Z"},
+		{"0 0 13 * 5", "UTC", "2026-10-14T00:00:00Z", "2026-10-16T00:00:00Z", "2026-10-13T00:00:00Z"},
+		{"0 0 1 1 *", "UTC", "2026-10-19T00:00:00Z", "2027-01-01T00:00:00Z", "2026-01-01T00:00:00Z"},
+		{"0 0 29 2 *", "UTC", "2026-10-19T00:00:00Z", "2028-02-29T00:00:00Z", "2024-02-29T00:00:00Z"},
+		// 02:30 does not exist on 2026-03-08 in New York, when clocks move from 02:00 to 03:00.
+		{"30 2 * * *", "America/New_York", "2026-03-08T10:00:00Z", "2026-03-09T06:30:00Z", "2026-03-07T07:30:00Z"},
+	}
+
+	for _, test := range tests {
+		schedule, err := Parse(test.expression)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", test.expression, err)
+		}
+		location, err := time.LoadLocation(test.timezone)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		at, _ := time.Parse(time.RFC3339, test.time)
+		next, ok := schedule.Next(at.In(location))
+		if want, _ := time.Parse(time.RFC3339, test.next); !ok || !next.Equal(want) {
+			t.Errorf("Parse(%q).Next(%s) = %s, %t, want %s", test.expression, test.time, next, ok, test.next)
+		}
+		previous, ok := schedule.Previous(at.In(location))
+		if want, _ := time.Parse(time.RFC3339, test.previous); !ok || !previous.Equal(want) {
+			t.Errorf("Parse(%q).Previous(%s) = %s, %t, want %s", test.expression, test.time, previous, ok, test.previous)
+		}
+	}
+}